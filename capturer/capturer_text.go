@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgb/xtest"
+)
+
+// X11 Shift_L 的硬件扫描码 (Evdev 42 + 8)
+const keycodeShiftL = 50
+
+// 字符的 keysym 不在键盘映射中时，临时把它映射到一个空闲的 keycode 上
+// 修改映射后需要等应用处理 MappingNotify，否则会按出旧的字符
+const remapDelay = 20 * time.Millisecond
+
+// keyboardMapping 是当前键盘映射的快照
+type keyboardMapping struct {
+	minKeycode xproto.Keycode
+	perKeycode int
+	keysyms    []xproto.Keysym
+	// 所有 keysym 都为空的 keycode，用于临时映射
+	scratch xproto.Keycode
+}
+
+func (ic *InputController) loadKeyboardMapping() (*keyboardMapping, error) {
+	setup := xproto.Setup(ic.conn)
+	count := int(setup.MaxKeycode) - int(setup.MinKeycode) + 1
+	reply, err := xproto.GetKeyboardMapping(ic.conn, setup.MinKeycode, byte(count)).Reply()
+	if err != nil {
+		return nil, err
+	}
+	m := &keyboardMapping{
+		minKeycode: setup.MinKeycode,
+		perKeycode: int(reply.KeysymsPerKeycode),
+		keysyms:    reply.Keysyms,
+	}
+	// 从高位开始找空闲的 keycode，低位通常是实体按键
+	for i := count - 1; i >= 0 && m.scratch == 0; i-- {
+		empty := true
+		for _, ks := range m.keysyms[i*m.perKeycode : (i+1)*m.perKeycode] {
+			if ks != 0 {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			m.scratch = m.minKeycode + xproto.Keycode(i)
+		}
+	}
+	if m.scratch == 0 {
+		m.scratch = setup.MaxKeycode
+	}
+	return m, nil
+}
+
+// lookup 返回 keysym 对应的 keycode，以及是否需要按住 Shift
+func (m *keyboardMapping) lookup(ks xproto.Keysym) (xproto.Keycode, bool, bool) {
+	for i := 0; i*m.perKeycode < len(m.keysyms); i++ {
+		code := m.minKeycode + xproto.Keycode(i)
+		if code == m.scratch {
+			continue
+		}
+		for col := 0; col < 2 && col < m.perKeycode; col++ {
+			if m.keysyms[i*m.perKeycode+col] == ks {
+				return code, col == 1, true
+			}
+		}
+	}
+	return 0, false, false
+}
+
+// runeToKeysym 把 Unicode 字符转换为 X11 keysym
+func runeToKeysym(r rune) xproto.Keysym {
+	switch r {
+	case '\n', '\r':
+		return 0xff0d // Return
+	case '\t':
+		return 0xff09 // Tab
+	case '\b':
+		return 0xff08 // BackSpace
+	}
+	// Latin-1 的 keysym 与码点相同，其余字符使用 0x01000000 + 码点
+	if (r >= 0x20 && r <= 0x7e) || (r >= 0xa0 && r <= 0xff) {
+		return xproto.Keysym(r)
+	}
+	return xproto.Keysym(0x01000000 + r)
+}
+
+// TypeText 通过 XTest 逐个字符输入文本，支持中文和 emoji 等任意 Unicode 字符
+func (ic *InputController) TypeText(text string) error {
+	m, err := ic.loadKeyboardMapping()
+	if err != nil {
+		return fmt.Errorf("读取键盘映射失败: %v", err)
+	}
+	remapped := false
+	for _, r := range text {
+		if r == '\r' {
+			continue
+		}
+		if r < 0x20 && r != '\n' && r != '\t' && r != '\b' {
+			continue
+		}
+		ks := runeToKeysym(r)
+		code, shift, ok := m.lookup(ks)
+		if !ok {
+			// 同时设置两列，不需要 Shift
+			keysyms := make([]xproto.Keysym, m.perKeycode)
+			keysyms[0] = ks
+			if m.perKeycode > 1 {
+				keysyms[1] = ks
+			}
+			if err := xproto.ChangeKeyboardMappingChecked(ic.conn, 1, m.scratch, byte(m.perKeycode), keysyms).Check(); err != nil {
+				log.Printf("修改键盘映射失败: %v", err)
+				continue
+			}
+			remapped = true
+			time.Sleep(remapDelay)
+			code, shift = m.scratch, false
+		}
+		ic.tapKey(byte(code), shift)
+		if !ok {
+			// 换下一个字符前确保按键已经处理完
+			ic.conn.Sync()
+			time.Sleep(remapDelay)
+		}
+	}
+	if remapped {
+		xproto.ChangeKeyboardMapping(ic.conn, 1, m.scratch, byte(m.perKeycode), make([]xproto.Keysym, m.perKeycode))
+	}
+	ic.conn.Sync()
+	return nil
+}
+
+// tapKey 按下并抬起一个键
+func (ic *InputController) tapKey(keycode byte, shift bool) {
+	if shift {
+		xtest.FakeInput(ic.conn, xproto.KeyPress, keycodeShiftL, 0, ic.root, 0, 0, 0)
+	}
+	xtest.FakeInput(ic.conn, xproto.KeyPress, keycode, 0, ic.root, 0, 0, 0)
+	xtest.FakeInput(ic.conn, xproto.KeyRelease, keycode, 0, ic.root, 0, 0, 0)
+	if shift {
+		xtest.FakeInput(ic.conn, xproto.KeyRelease, keycodeShiftL, 0, ic.root, 0, 0, 0)
+	}
+}
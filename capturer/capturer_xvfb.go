@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"flag"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -26,7 +28,25 @@ func main() {
 	bitRate := flag.String("bitrate", "8M", "streaming bitrate in Mbps")
 	frameRate := flag.String("framerate", "60", "frame rate for capturing")
 	codec := flag.String("codec", "h264", "video codec: h264 or hevc")
+	screenshot := flag.Bool("screenshot", false, "write a PNG screenshot of -display to stdout and exit")
+	display := flag.String("display", ":99", "X display to stream or take screenshots of")
 	flag.Parse()
+
+	// 截图模式不启动 Xvfb，连接推流中的虚拟显示器或 -display 指定的已有显示器
+	if *screenshot {
+		if err := writeScreenshot(*display, os.Stdout); err != nil {
+			log.Printf("截图失败: %v", err)
+			if errors.Is(err, errDisplayNotRunning) {
+				os.Exit(SCREENSHOT_EXIT_NO_DISPLAY)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	displayNum, err := strconv.Atoi(strings.TrimPrefix(*display, ":"))
+	if err != nil {
+		log.Fatalf("无效的 display: %s", *display)
+	}
 	log.Printf("Starting Xvfb capturer with resolution %s, bitrate %s, framerate %s, codec %s\n", *resolution, *bitRate, *frameRate, *codec)
 
 	// 启动 Xvfb 虚拟显示器
@@ -34,7 +54,7 @@ func main() {
 	// 定义清理函数：用于杀死 Xvfb 进程
 	width, err := strconv.Atoi(_width)
 	height, err := strconv.Atoi(_height)
-	session, err := NewXvfbSession(*tcpPort, width, height, 99, 24)
+	session, err := NewXvfbSession(*tcpPort, width, height, displayNum, 24)
 	if err != nil {
 		log.Printf("无法启动 Xvfb: %v", err)
 		return
@@ -56,14 +76,29 @@ func main() {
 	// FFmpeg 抓取该虚拟屏幕
 	session.StartFFmpeg(*codec, *resolution, *bitRate, *frameRate)
 
-	// 数据发送循环
-	scanner := bufio.NewScanner(session.ffmpegOutput)
+	// 数据发送循环，调整码率或暂停恢复时 ffmpeg 会被替换，切换到新的输出继续发送
+	header := make([]byte, 12)
+	for {
+		if err := sendNALUs(session.FFmpegOutput(), session.Conn, header); err != nil {
+			log.Println("网络发送错误:", err)
+			break
+		}
+		if !session.waitFFmpeg() {
+			log.Println("FFmpeg 输出结束")
+			break
+		}
+	}
+
+	// 循环结束后（通常是 FFmpeg 退出或网络断开），由 defer cleanup() 负责收尾
+}
+
+// sendNALUs 把 ffmpeg 输出的 NALU 加上头部发送出去，直到输出结束，只有网络错误才返回 error
+func sendNALUs(output io.Reader, conn io.Writer, header []byte) error {
+	scanner := bufio.NewScanner(output)
 	buf := make([]byte, 1024*1024)
 	scanner.Buffer(buf, 10*1024*1024)
 	scanner.Split(splitNALU)
 
-	header := make([]byte, 12)
-
 	for scanner.Scan() {
 		nalData := scanner.Bytes()
 		if len(nalData) == 0 {
@@ -74,15 +109,12 @@ func main() {
 		binary.BigEndian.PutUint64(header[0:8], pts)
 		binary.BigEndian.PutUint32(header[8:12], uint32(len(nalData)))
 
-		if _, err := session.Conn.Write(header); err != nil {
-			log.Println("网络发送错误:", err)
-			break
+		if _, err := conn.Write(header); err != nil {
+			return err
 		}
-		if _, err := session.Conn.Write(nalData); err != nil {
-			log.Println("网络发送错误:", err)
-			break
+		if _, err := conn.Write(nalData); err != nil {
+			return err
 		}
 	}
-
-	// 循环结束后（通常是 FFmpeg 退出或网络断开），由 defer cleanup() 负责收尾
+	return nil
 }
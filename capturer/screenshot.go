@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// 截图时 display 上没有 X server，进程以这个退出码结束，服务端据此返回明确的错误
+const SCREENSHOT_EXIT_NO_DISPLAY = 3
+
+var errDisplayNotRunning = errors.New("display is not running")
+
+// writeScreenshot 抓取 display 的根窗口，编码为 PNG 写入 w
+// 只连接已经存在的显示器，不会为截图单独启动 Xvfb
+func writeScreenshot(display string, w io.Writer) error {
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", errDisplayNotRunning, display, err)
+	}
+	defer conn.Close()
+
+	setup := xproto.Setup(conn)
+	screen := setup.DefaultScreen(conn)
+	width, height := int(screen.WidthInPixels), int(screen.HeightInPixels)
+	reply, err := xproto.GetImage(conn, xproto.ImageFormatZPixmap, xproto.Drawable(screen.Root),
+		0, 0, uint16(width), uint16(height), 0xffffffff).Reply()
+	if err != nil {
+		return fmt.Errorf("GetImage 失败: %v", err)
+	}
+	// Xvfb 使用 24 位色深，每个像素 4 字节
+	if len(reply.Data) < width*height*4 {
+		return fmt.Errorf("不支持的像素格式: depth %d, %d 字节", reply.Depth, len(reply.Data))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	lsb := setup.ImageByteOrder == xproto.ImageOrderLSBFirst
+	for i := 0; i < width*height; i++ {
+		p := reply.Data[i*4 : i*4+4]
+		if lsb {
+			// BGRX
+			img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2] = p[2], p[1], p[0]
+		} else {
+			// XRGB
+			img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2] = p[1], p[2], p[3]
+		}
+		img.Pix[i*4+3] = 0xff
+	}
+	return png.Encode(w, img)
+}
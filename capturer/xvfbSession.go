@@ -9,15 +9,33 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 )
 
 type XvfbSession struct {
 	Display int
-	Cmd     *os.Process
-	Conn    net.Conn
+	// Xvfb 屏幕大小，输出分辨率不同时 ffmpeg 会缩放，鼠标坐标按比例换算回屏幕坐标
+	screenWidth  int
+	screenHeight int
+	Cmd          *os.Process
+	Conn         net.Conn
 
+	ffmpegMu     sync.Mutex
+	ffmpegCmd    *exec.Cmd
 	ffmpegOutput io.ReadCloser
+	// 当前 ffmpeg 的编码参数，调整码率时用它们重启 ffmpeg，resolution 是输出分辨率
+	codec      string
+	resolution string
+	bitRate    string
+	frameRate  string
+	// 暂停时 ffmpeg 被停止，恢复时重新启动
+	paused bool
+	// 调整码率或恢复后通知发送循环切换到新的 ffmpeg 输出
+	restarted chan struct{}
+	// 控制连接断开时关闭
+	done chan struct{}
 
 	controller *InputController
 }
@@ -34,8 +52,12 @@ func NewXvfbSession(tcpPort string, width int, height int, DisplayNum int, depth
 		return nil, err
 	}
 	session := &XvfbSession{
-		Display: DisplayNum,
-		Cmd:     xvfbCmd.Process,
+		Display:      DisplayNum,
+		screenWidth:  width,
+		screenHeight: height,
+		Cmd:          xvfbCmd.Process,
+		restarted:    make(chan struct{}, 1),
+		done:         make(chan struct{}),
 	}
 	err := session.waitLaunchFinished()
 	if err != nil {
@@ -114,11 +136,20 @@ func (s *XvfbSession) waitLaunchFinished() error {
 	return nil
 }
 
+// 单次输入文本的最大长度
+const maxTextLen = 64 * 1024
+
 func (s *XvfbSession) HandleEvent() {
 	const (
 		eventTypeKeyboard = 0x00
 		EventTypeMouse    = 0x01
+		eventTypeBitrate  = 0x02
+		eventTypePause    = 0x03
+		eventTypeResume   = 0x04
+		eventTypeConfig   = 0x05
+		eventTypeText     = 0x06
 	)
+	defer close(s.done)
 
 	// 预分配一个小 buffer 用于读取头部或完整包
 
@@ -156,6 +187,7 @@ func (s *XvfbSession) HandleEvent() {
 
 			// 4. 执行控制逻辑
 			// 注意：这里需要把 uint32 转为 int16 传给 InputController
+			x, y = s.toScreen(x, y)
 			s.controller.HandleMouseEvent(action, int16(x), int16(y), buttons, deltaX, deltaY)
 		case eventTypeKeyboard:
 			payload := make([]byte, 5)
@@ -176,6 +208,55 @@ func (s *XvfbSession) HandleEvent() {
 			if s.controller != nil {
 				s.controller.HandleKeyboardEvent(action, x11Code)
 			}
+		case eventTypeBitrate:
+			payload := make([]byte, 4)
+			if _, err := io.ReadFull(s.Conn, payload); err != nil {
+				return
+			}
+			bitRate := binary.BigEndian.Uint32(payload)
+			if err := s.SetBitRate(strconv.FormatUint(uint64(bitRate), 10)); err != nil {
+				log.Printf("调整码率失败: %v", err)
+			}
+		case eventTypeConfig:
+			payload := make([]byte, 10)
+			if _, err := io.ReadFull(s.Conn, payload); err != nil {
+				return
+			}
+			width := binary.BigEndian.Uint16(payload[0:2])
+			height := binary.BigEndian.Uint16(payload[2:4])
+			frameRate := binary.BigEndian.Uint16(payload[4:6])
+			bitRate := binary.BigEndian.Uint32(payload[6:10])
+			err := s.Reconfigure(fmt.Sprintf("%dx%d", width, height), strconv.Itoa(int(frameRate)), strconv.FormatUint(uint64(bitRate), 10))
+			if err != nil {
+				log.Printf("修改编码参数失败: %v", err)
+			}
+		case eventTypeText:
+			// [Len 4][UTF-8 N]
+			lenBuf := make([]byte, 4)
+			if _, err := io.ReadFull(s.Conn, lenBuf); err != nil {
+				return
+			}
+			textLen := binary.BigEndian.Uint32(lenBuf)
+			if textLen > maxTextLen {
+				log.Printf("文本过长: %d", textLen)
+				return
+			}
+			text := make([]byte, textLen)
+			if _, err := io.ReadFull(s.Conn, text); err != nil {
+				return
+			}
+			if s.controller == nil {
+				continue
+			}
+			if err := s.controller.TypeText(string(text)); err != nil {
+				log.Printf("输入文本失败: %v", err)
+			}
+		case eventTypePause:
+			s.Pause()
+		case eventTypeResume:
+			if err := s.Resume(); err != nil {
+				log.Printf("恢复推流失败: %v", err)
+			}
 		default:
 			log.Printf("收到未知事件类型: 0x%X", eventType)
 			// 如果有变长包，这里如果不处理会导致后续数据错乱
@@ -184,7 +265,131 @@ func (s *XvfbSession) HandleEvent() {
 	}
 }
 
+// FFmpegOutput 返回当前 ffmpeg 的输出
+func (s *XvfbSession) FFmpegOutput() io.ReadCloser {
+	s.ffmpegMu.Lock()
+	defer s.ffmpegMu.Unlock()
+	return s.ffmpegOutput
+}
+
+// SetBitRate 只修改码率，见 Reconfigure
+func (s *XvfbSession) SetBitRate(bitRate string) error {
+	s.ffmpegMu.Lock()
+	resolution, frameRate := s.resolution, s.frameRate
+	s.ffmpegMu.Unlock()
+	return s.Reconfigure(resolution, frameRate, bitRate)
+}
+
+// Reconfigure 用新的参数启动一个 ffmpeg 替换当前的，发送循环读到旧输出结束后切换到新输出
+func (s *XvfbSession) Reconfigure(resolution string, frameRate string, bitRate string) error {
+	s.ffmpegMu.Lock()
+	defer s.ffmpegMu.Unlock()
+	if resolution == s.resolution && frameRate == s.frameRate && bitRate == s.bitRate {
+		return nil
+	}
+	log.Printf("修改编码参数: %s@%sfps %s -> %s@%sfps %s", s.resolution, s.frameRate, s.bitRate, resolution, frameRate, bitRate)
+	if s.paused {
+		// 恢复时使用新的参数
+		s.resolution, s.frameRate, s.bitRate = resolution, frameRate, bitRate
+		return nil
+	}
+	old := s.ffmpegCmd
+	if err := s.startFFmpeg(s.codec, resolution, bitRate, frameRate); err != nil {
+		return err
+	}
+	// 先通知再结束旧进程，发送循环读到 EOF 时一定能看到通知
+	s.notifyRestarted()
+	stopFFmpeg(old)
+	return nil
+}
+
+// toScreen 把视频画面中的坐标换算为 Xvfb 屏幕坐标
+func (s *XvfbSession) toScreen(x, y uint32) (uint32, uint32) {
+	s.ffmpegMu.Lock()
+	resolution := s.resolution
+	s.ffmpegMu.Unlock()
+	var width, height int
+	if _, err := fmt.Sscanf(resolution, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return x, y
+	}
+	if width == s.screenWidth && height == s.screenHeight {
+		return x, y
+	}
+	return uint32(uint64(x) * uint64(s.screenWidth) / uint64(width)), uint32(uint64(y) * uint64(s.screenHeight) / uint64(height))
+}
+
+// Pause 停止 ffmpeg，X 会话和输入控制不受影响
+func (s *XvfbSession) Pause() {
+	s.ffmpegMu.Lock()
+	defer s.ffmpegMu.Unlock()
+	if s.paused {
+		return
+	}
+	log.Println("暂停推流")
+	s.paused = true
+	stopFFmpeg(s.ffmpegCmd)
+	s.ffmpegCmd = nil
+}
+
+// Resume 用原来的参数重新启动 ffmpeg
+func (s *XvfbSession) Resume() error {
+	s.ffmpegMu.Lock()
+	defer s.ffmpegMu.Unlock()
+	if !s.paused {
+		return nil
+	}
+	log.Println("恢复推流")
+	if err := s.startFFmpeg(s.codec, s.resolution, s.bitRate, s.frameRate); err != nil {
+		return err
+	}
+	s.paused = false
+	s.notifyRestarted()
+	return nil
+}
+
+// waitFFmpeg 在 ffmpeg 输出结束后调用，有新的 ffmpeg 接替时返回 true
+// 暂停期间会一直等到恢复或控制连接断开
+func (s *XvfbSession) waitFFmpeg() bool {
+	select {
+	case <-s.restarted:
+		return true
+	default:
+	}
+	s.ffmpegMu.Lock()
+	paused := s.paused
+	s.ffmpegMu.Unlock()
+	if !paused {
+		return false
+	}
+	select {
+	case <-s.restarted:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *XvfbSession) notifyRestarted() {
+	select {
+	case s.restarted <- struct{}{}:
+	default:
+	}
+}
+
+func stopFFmpeg(cmd *exec.Cmd) {
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Kill()
+		go cmd.Wait()
+	}
+}
+
 func (s *XvfbSession) StartFFmpeg(codec string, resolution string, bitRate string, frameRate string) error {
+	s.ffmpegMu.Lock()
+	defer s.ffmpegMu.Unlock()
+	return s.startFFmpeg(codec, resolution, bitRate, frameRate)
+}
+
+func (s *XvfbSession) startFFmpeg(codec string, resolution string, bitRate string, frameRate string) error {
 
 	var bestEncoder string
 	switch codec {
@@ -197,12 +402,22 @@ func (s *XvfbSession) StartFFmpeg(codec string, resolution string, bitRate strin
 	}
 
 	log.Printf("使用的 H.264 编码器: %s\n", bestEncoder)
-	ffmpegCmd := exec.Command("ffmpeg",
+	screenSize := fmt.Sprintf("%dx%d", s.screenWidth, s.screenHeight)
+	args := []string{
 		"-f", "x11grab",
 		"-framerate", frameRate,
-		"-video_size", resolution, // 使用定义的变量
+		"-video_size", screenSize, // 总是抓取整个屏幕
 		"-i", fmt.Sprintf(":%d", s.Display), // 连到我们刚创建的 :99
-
+	}
+	// 输出分辨率与屏幕不同时缩放
+	if resolution != screenSize {
+		var width, height int
+		if _, err := fmt.Sscanf(resolution, "%dx%d", &width, &height); err != nil {
+			return fmt.Errorf("无效的分辨率: %s", resolution)
+		}
+		args = append(args, "-vf", fmt.Sprintf("scale=%d:%d", width, height))
+	}
+	args = append(args,
 		// 编码参数
 		"-c:v", bestEncoder, // 如果在 PC 上跑，改成 libx264
 		"-b:v", bitRate,
@@ -215,12 +430,12 @@ func (s *XvfbSession) StartFFmpeg(codec string, resolution string, bitRate strin
 		"-f", "h264",
 		"-",
 	)
+	ffmpegCmd := exec.Command("ffmpeg", args...)
 	// 注入 DISPLAY 变量
 	ffmpegCmd.Env = append(os.Environ(), fmt.Sprintf("DISPLAY=:%d", s.Display))
 	ffmpegCmd.Stderr = os.Stderr // 错误日志打印出来
 
-	var err error
-	s.ffmpegOutput, err = ffmpegCmd.StdoutPipe()
+	output, err := ffmpegCmd.StdoutPipe()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -229,5 +444,8 @@ func (s *XvfbSession) StartFFmpeg(codec string, resolution string, bitRate strin
 		log.Printf("FFmpeg 启动失败: %v", err)
 		return err
 	}
+	s.ffmpegCmd = ffmpegCmd
+	s.ffmpegOutput = output
+	s.codec, s.resolution, s.bitRate, s.frameRate = codec, resolution, bitRate, frameRate
 	return nil
 }
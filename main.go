@@ -4,10 +4,15 @@ import (
 	"context"
 	"embed"
 	"flag"
+	"fmt"
 	"io/fs"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	sagent "webscreen/streamAgent"
+	"webscreen/turnserver"
 	"webscreen/webservice"
 )
 
@@ -17,6 +22,21 @@ var publicFS embed.FS
 func main() {
 	port := flag.String("port", "8079", "server port")
 	pin := flag.String("pin", "123456", "initial PIN for web access")
+	recordDir := flag.String("record_dir", "recordings", "directory for server-side recordings")
+	registryFile := flag.String("registry", "devices.json", "JSON file with device names, tags and default driver options")
+	hostsFile := flag.String("hosts", "hosts.json", "JSON file with remote Linux hosts for xvfb streaming")
+	discover := flag.Bool("discover", true, "discover Android wireless debugging devices on the LAN with mDNS")
+	webrtcConfigFile := flag.String("webrtc_config", "", "JSON file with ICE servers, NAT 1:1 IPs and UDP port range (overrides the other WebRTC flags)")
+	stunServers := flag.String("stun", sagent.DEFAULT_STUN_SERVER, "comma separated STUN server URLs, empty to disable")
+	turnServers := flag.String("turn", "", "comma separated TURN server URLs, e.g. turn:turn.example.com:3478")
+	turnUser := flag.String("turn_user", "", "TURN username")
+	turnPass := flag.String("turn_pass", "", "TURN password")
+	natIPs := flag.String("nat_ip", "", "comma separated public IPs for NAT 1:1 mapping")
+	udpPorts := flag.String("udp_ports", "", "UDP port range for WebRTC, e.g. 50000-50100")
+	udpMuxPort := flag.Int("udp_mux_port", 0, "serve all WebRTC media on this single UDP port, 0 to disable")
+	tcpMuxPort := flag.Int("tcp_mux_port", 0, "ICE-TCP port for networks that block UDP, 0 to disable")
+	turnServerPort := flag.Int("turn_server_port", 0, "run an embedded TURN server on this UDP port, 0 to disable")
+	turnServerIP := flag.String("turn_server_ip", "", "public IP of the embedded TURN server (default: first -nat_ip or the outbound IP)")
 	flag.Parse()
 	// pin should be 6 digits and only digits
 	if *pin == "DISABLED" {
@@ -38,6 +58,46 @@ func main() {
 	pub, _ := fs.Sub(publicFS, "public")
 	webMaster := webservice.Default(pub)
 	webMaster.SetPIN(*pin)
+	webMaster.SetRecordDir(*recordDir)
+	webMaster.SetRegistryFile(*registryFile)
+	webMaster.SetHostsFile(*hostsFile)
+	webMaster.SetAndroidDiscover(*discover)
+
+	var webrtcConfig sagent.WebRTCConfig
+	var err error
+	if *webrtcConfigFile != "" {
+		webrtcConfig, err = sagent.LoadWebRTCConfig(*webrtcConfigFile)
+	} else {
+		webrtcConfig, err = webrtcConfigFromFlags(*stunServers, *turnServers, *turnUser, *turnPass, *natIPs, *udpPorts)
+		webrtcConfig.UDPMuxPort = *udpMuxPort
+		webrtcConfig.TCPMuxPort = *tcpMuxPort
+	}
+	if err == nil {
+		err = webrtcConfig.Validate()
+	}
+	if err != nil {
+		log.Fatal("Invalid WebRTC config: ", err)
+	}
+	if err := webrtcConfig.Listen(); err != nil {
+		log.Fatal(err)
+	}
+	webMaster.SetWebRTCConfig(webrtcConfig)
+
+	if *turnServerPort != 0 {
+		// 没有 PIN 时无法确认谁在使用中继
+		if *pin == "" {
+			log.Fatal("The embedded TURN server requires a PIN, it cannot be used with -pin DISABLED")
+		}
+		publicIP := *turnServerIP
+		if publicIP == "" && len(webrtcConfig.NAT1To1IPs) > 0 {
+			publicIP = webrtcConfig.NAT1To1IPs[0]
+		}
+		turnServer, err := turnserver.Start(turnserver.Config{Port: *turnServerPort, PublicIP: publicIP})
+		if err != nil {
+			log.Fatal("Failed to start TURN server: ", err)
+		}
+		webMaster.SetTURNServer(turnServer)
+	}
 
 	go webMaster.Serve(*port)
 
@@ -46,3 +106,40 @@ func main() {
 	webMaster.Close()
 
 }
+
+func webrtcConfigFromFlags(stun, turn, turnUser, turnPass, natIPs, udpPorts string) (sagent.WebRTCConfig, error) {
+	config := sagent.WebRTCConfig{}
+	if urls := splitList(stun); len(urls) > 0 {
+		config.ICEServers = append(config.ICEServers, sagent.ICEServer{URLs: urls})
+	}
+	if urls := splitList(turn); len(urls) > 0 {
+		config.ICEServers = append(config.ICEServers, sagent.ICEServer{URLs: urls, Username: turnUser, Credential: turnPass})
+	}
+	config.NAT1To1IPs = splitList(natIPs)
+	if udpPorts != "" {
+		minStr, maxStr, ok := strings.Cut(udpPorts, "-")
+		if !ok {
+			return config, fmt.Errorf("udp port range must look like 50000-50100")
+		}
+		portMin, err := strconv.ParseUint(minStr, 10, 16)
+		if err != nil {
+			return config, err
+		}
+		portMax, err := strconv.ParseUint(maxStr, 10, 16)
+		if err != nil {
+			return config, err
+		}
+		config.UDPPortMin, config.UDPPortMax = uint16(portMin), uint16(portMax)
+	}
+	return config, config.Validate()
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -45,12 +45,10 @@ function releaseButton(keyCode) {
     sendButtonEvent(p);
 }
 
+// Back when the screen is on, turns the screen on when it is off
 function backButton() {
-    const KEYCODE_BACK = 4;
-    let p = createKeyPacket(TYPE_KEY_ACTION_DOWN, KEYCODE_BACK);
-    sendButtonEvent(p);
-    p = createKeyPacket(TYPE_KEY_ACTION_UP, KEYCODE_BACK);
-    sendButtonEvent(p);
+    sendButtonEvent(new Uint8Array([TYPE_BACK_OR_SCREEN_ON, TYPE_KEY_ACTION_DOWN]));
+    sendButtonEvent(new Uint8Array([TYPE_BACK_OR_SCREEN_ON, TYPE_KEY_ACTION_UP]));
 }
 
 function menuButton() {
@@ -84,4 +82,90 @@ function createRotatePacket() {
     const view = new DataView(buffer);
     view.setUint8(0, TYPE_ROTATE);
     return buffer;
-}
\ No newline at end of file
+}
+
+const TYPE_START_APP = 0x10; // start app event
+// name is a package name, prefix with "+" to force-stop the app first
+function startApp(name) {
+    const bytes = new TextEncoder().encode(name);
+    if (bytes.length === 0 || bytes.length > 255) {
+        console.warn("Invalid app name:", name);
+        return;
+    }
+    const buffer = new ArrayBuffer(2 + bytes.length);
+    const view = new Uint8Array(buffer);
+    view[0] = TYPE_START_APP;
+    view[1] = bytes.length;
+    view.set(bytes, 2);
+    sendButtonEvent(buffer);
+}
+
+const TYPE_BACK_OR_SCREEN_ON = 0x04;
+const TYPE_EXPAND_NOTIFICATION_PANEL = 0x05;
+const TYPE_EXPAND_SETTINGS_PANEL = 0x06;
+const TYPE_COLLAPSE_PANELS = 0x07;
+const TYPE_DISPLAY_POWER = 0x0A;
+
+// The panel that was opened last, pressing its button again collapses it
+let expandedPanel = null;
+
+function togglePanel(panelType) {
+    if (expandedPanel === panelType) {
+        sendButtonEvent(new Uint8Array([TYPE_COLLAPSE_PANELS]));
+        expandedPanel = null;
+        return;
+    }
+    sendButtonEvent(new Uint8Array([panelType]));
+    expandedPanel = panelType;
+}
+
+function toggleNotificationPanel() {
+    togglePanel(TYPE_EXPAND_NOTIFICATION_PANEL);
+}
+
+function toggleSettingsPanel() {
+    togglePanel(TYPE_EXPAND_SETTINGS_PANEL);
+}
+
+function collapsePanels() {
+    sendButtonEvent(new Uint8Array([TYPE_COLLAPSE_PANELS]));
+    expandedPanel = null;
+}
+
+// Turns the physical screen off while mirroring continues
+let displayOn = true;
+function toggleDisplayPower() {
+    displayOn = !displayOn;
+    sendButtonEvent(new Uint8Array([TYPE_DISPLAY_POWER, displayOn ? 1 : 0]));
+    const btn = document.getElementById('displayPowerBtn');
+    if (btn) btn.classList.toggle('active', !displayOn);
+    showToast(i18n.t(displayOn ? 'display_on' : 'display_off'), 2000);
+}
+
+// Uploads files to the device, APKs are installed and everything else lands in /sdcard/Download.
+// Progress and install results arrive over the websocket as the file_transfer stage.
+async function uploadFiles(files) {
+    for (const file of files) {
+        const form = new FormData();
+        // size must come before file so the server knows the total while receiving it
+        form.append('size', file.size);
+        form.append('file', file);
+        const url = `/api/device/${encodeURIComponent(CONFIG.device_type)}/${encodeURIComponent(CONFIG.device_id)}/upload`;
+        try {
+            const resp = await fetch(url, { method: 'POST', body: form });
+            if (!resp.ok) {
+                const body = await resp.json().catch(() => ({}));
+                console.error("Upload failed:", file.name, body.error || resp.status);
+            }
+        } catch (e) {
+            showToast(i18n.t('upload_failed', { name: file.name, msg: e.message }), 3000);
+        }
+    }
+}
+
+// Files dropped onto the video are uploaded as well
+remoteVideo.addEventListener('dragover', (e) => e.preventDefault());
+remoteVideo.addEventListener('drop', (e) => {
+    e.preventDefault();
+    if (e.dataTransfer.files.length) uploadFiles(e.dataTransfer.files);
+});
@@ -1,3 +1,22 @@
+// Sequences are shared by all viewers of a device, start from a time-based value
+// so that acknowledgements for other browsers are not mistaken for ours.
+let clipboardSequence = BigInt(Date.now()) << 16n;
+const pendingClipboardSequences = new Set();
+
+function nextClipboardSequence() {
+    clipboardSequence += 1n;
+    return clipboardSequence;
+}
+
+// packet: [Type 1][Sequence 8][OK 1]
+function receiveClipboardAck(packet) {
+    const view = new DataView(packet.buffer, packet.byteOffset, packet.byteLength);
+    const sequence = view.getBigUint64(1, false);
+    if (!pendingClipboardSequences.delete(sequence)) return;
+    const ok = packet[9] !== 0;
+    showToast(i18n.t(ok ? 'paste_done' : 'paste_failed'), 2000);
+}
+
 function setClipboard(text) {
     if (!window.ws || window.ws.readyState !== WebSocket.OPEN) return;
     const encoder = new TextEncoder();
@@ -15,8 +34,10 @@ function setClipboard(text) {
     
     packet[0] = 9; // WS_TYPE_SET_CLIPBOARD
     
-    // Sequence (8 bytes) - using 0 for now
-    view.setBigUint64(1, 0n, false); // false for BigEndian (network byte order)
+    // Sequence (8 bytes), the device acknowledges it once the clipboard is set
+    const sequence = nextClipboardSequence();
+    view.setBigUint64(1, sequence, false); // false for BigEndian (network byte order)
+    pendingClipboardSequences.add(sequence);
     
     // Paste (1 byte) - true/false
     packet[9] = 1; // paste = true
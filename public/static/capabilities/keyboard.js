@@ -85,6 +85,11 @@ document.addEventListener('keydown', (e) => {
         return;
     }
 
+    // Ctrl+V / Cmd+V: let the browser fire a paste event, the text is injected from there
+    if ((e.ctrlKey || e.metaKey) && e.code === 'KeyV') {
+        return;
+    }
+
     const keyCode = getAndroidKeyCode(e);
     if (keyCode !== null) {
         // Prevent default behavior for some keys to avoid browser scrolling/shortcuts
@@ -103,6 +108,9 @@ document.addEventListener('keyup', (e) => {
     if (typeof uhidKeyboardEnabled !== 'undefined' && uhidKeyboardEnabled) {
         return;
     }
+    if ((e.ctrlKey || e.metaKey) && e.code === 'KeyV') {
+        return;
+    }
 
     const keyCode = getAndroidKeyCode(e);
     if (keyCode !== null) {
@@ -126,3 +134,31 @@ function createKeyPacket(action, keyCode) {
     view.setUint16(2, keyCode);
     return buffer;
 }
+
+const TYPE_TEXT = 0x11; // text inject event
+// Text Packet Structure:
+// 0,1,uint8,Type,0x11
+// 1,4,uint32,Length,UTF-8 byte length
+// 5,N,bytes,Text
+function sendText(text) {
+    if (!text || !window.ws || window.ws.readyState !== WebSocket.OPEN) return;
+    const data = new TextEncoder().encode(text);
+    const packet = new Uint8Array(5 + data.length);
+    const view = new DataView(packet.buffer);
+    view.setUint8(0, TYPE_TEXT);
+    view.setUint32(1, data.length, false);
+    packet.set(data, 5);
+    window.ws.send(packet);
+}
+
+// Paste browser text as text input, works for any language and emoji
+document.addEventListener('paste', (e) => {
+    if (e.target.tagName === 'INPUT' || e.target.tagName === 'TEXTAREA') {
+        return;
+    }
+    const text = e.clipboardData && e.clipboardData.getData('text/plain');
+    if (text) {
+        e.preventDefault();
+        sendText(text);
+    }
+});
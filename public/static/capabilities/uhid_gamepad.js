@@ -126,6 +126,13 @@
         }
     };
 
+    // 设备发来的 output report 视为震动强度，全为 0 时停止震动
+    onUHIDOutput(UHID_GAMEPAD_ID, (data) => {
+        if (!uhidGamepadEnabled || !navigator.vibrate) return;
+        const rumble = data.some(b => b !== 0);
+        navigator.vibrate(rumble ? 200 : 0);
+    });
+
     function sendGamepadReport() {
         if (!uhidGamepadEnabled || !uhidGamepadInitialized) return;
 
@@ -121,6 +121,26 @@ function toggleUHIDKeyboard() {
     }
 }
 
+// LED output report: bit 0 Num Lock, bit 1 Caps Lock, bit 2 Scroll Lock
+const LED_NUM_LOCK = 1 << 0;
+const LED_CAPS_LOCK = 1 << 1;
+let keyboardLEDs = 0;
+
+onUHIDOutput(UHID_KEYBOARD_ID, (data) => {
+    if (data.length < 1) return;
+    const leds = data[data.length - 1];
+    const changed = leds ^ keyboardLEDs;
+    keyboardLEDs = leds;
+    const btn = document.getElementById('uhidKeyboardToggleBtn');
+    if (btn) btn.classList.toggle('caps-lock', (leds & LED_CAPS_LOCK) !== 0);
+    if (changed & LED_CAPS_LOCK) {
+        showToast(i18n.t((leds & LED_CAPS_LOCK) ? 'caps_lock_on' : 'caps_lock_off'), 1000);
+    }
+    if (changed & LED_NUM_LOCK) {
+        showToast(i18n.t((leds & LED_NUM_LOCK) ? 'num_lock_on' : 'num_lock_off'), 1000);
+    }
+});
+
 function sendKeyboardReport() {
     if (!uhidKeyboardEnabled || !uhidKeyboardInitialized) return;
 
@@ -64,11 +64,44 @@ var CONFIG = (function () {
     };
 })();
 
+// ?role=viewer 以只读身份加入；?role=controller 只是请求，已有控制者时仍然只读
+(function () {
+    const role = new URLSearchParams(window.location.search).get('role');
+    if (role) CONFIG.role = role;
+})();
+
+// The server sends STUN servers, and short-lived credentials for the embedded TURN server
+// when logged in with a PIN, as the first message on the screen websocket.
+function waitIceServers(ws) {
+    return new Promise((resolve) => {
+        ws.onmessage = (event) => {
+            const message = typeof event.data === 'string' ? JSON.parse(event.data) : null;
+            if (message && message.stage === 'ice_servers') {
+                console.log("ICE servers:", message.ice_servers.map(s => s.urls));
+                resolve(message.ice_servers);
+            }
+        };
+        ws.onclose = () => resolve(null);
+    });
+}
+
 async function start() {
     console.log("Starting WebRTC connection...");
-    const pc = new RTCPeerConnection();
+    // 1. Establish WebSocket connection, ICE servers come first
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const wsUrl = `${protocol}//${window.location.host}/screen/ws`;
 
-    // 1. Listen for remote tracks
+    window.ws = new WebSocket(wsUrl);
+    window.ws.binaryType = "arraybuffer";
+    const iceServers = await waitIceServers(window.ws);
+    if (iceServers === null) {
+        console.error("WebSocket closed before ICE servers were received");
+        return;
+    }
+    console.log('WebSocket connected');
+    const pc = new RTCPeerConnection({ iceServers: iceServers });
+
+    // 2. Listen for remote tracks
     pc.ontrack = function (event) {
         if (event.track.kind === 'video') {
             const el = document.getElementById('remoteVideo');
@@ -84,39 +117,47 @@ async function start() {
         }
     };
 
-    // 2. Add a recvonly Transceiver
+    // 3. Add a recvonly Transceiver
     pc.addTransceiver('video', { direction: 'recvonly' });
     pc.addTransceiver('audio', { direction: 'recvonly' });
 
-    // 3. Create Offer
+    // 4. Trickle ICE: local candidates are sent over the websocket as they are gathered.
+    // Candidates gathered before the offer is sent are queued.
+    let offerSent = false;
+    const pendingLocalCandidates = [];
+    const sendCandidate = (candidate) => {
+        window.ws.send(JSON.stringify({ stage: 'webrtc_candidate', candidate: candidate }));
+    };
+    pc.onicecandidate = e => {
+        const candidate = e.candidate ? e.candidate.toJSON() : null;
+        if (offerSent) sendCandidate(candidate);
+        else pendingLocalCandidates.push(candidate);
+    };
+    // Remote candidates may arrive before the answer has been applied
+    let remoteDescriptionSet = false;
+    const pendingRemoteCandidates = [];
+    const addRemoteCandidate = (candidate) => {
+        pc.addIceCandidate(candidate).catch(err => console.warn("Failed to add ICE candidate:", err));
+    };
+
+    // 5. Create Offer
     const offer = await pc.createOffer();
     await pc.setLocalDescription(offer);
 
-    // 4. Wait ICE
-    await new Promise(resolve => {
-        if (pc.iceGatheringState === 'complete') resolve();
-        else pc.onicecandidate = e => { if (!e.candidate) resolve(); }
-    });
-
-    // 5. Establish WebSocket connection
-    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    // Construct URL matching the hardcoded config to satisfy backend check
-    const wsUrl = `${protocol}//${window.location.host}/screen/ws`;
-
-    window.ws = new WebSocket(wsUrl);
-    window.ws.binaryType = "arraybuffer";
-
-    window.ws.onopen = () => {
-        console.log('WebSocket connected');
-        // 发送 Config 和 SDP
-        console.log("config:", CONFIG);
-        const config = {
-            ...CONFIG,
-            sdp: pc.localDescription.sdp
-        };
-        console.log(CONFIG.driver_config)
-        window.ws.send(JSON.stringify(config));
+    // 6. Send config and SDP
+    console.log("config:", CONFIG);
+    const config = {
+        ...CONFIG,
+        sdp: pc.localDescription.sdp,
+        trickle_ice: true
     };
+    console.log(CONFIG.driver_config)
+    window.ws.send(JSON.stringify(config));
+    offerSent = true;
+    pendingLocalCandidates.forEach(sendCandidate);
+    pendingLocalCandidates.length = 0;
+    watchVisibility();
+    window.ws.onclose = null;
     window.ws.onmessage = async (event) => {
         if (typeof event.data === 'string') {
             const message = JSON.parse(event.data);
@@ -149,13 +190,27 @@ async function start() {
                                 type: 'answer',
                                 sdp: formattedSdp
                             }));
+                            remoteDescriptionSet = true;
+                            pendingRemoteCandidates.forEach(addRemoteCandidate);
+                            pendingRemoteCandidates.length = 0;
                             console.log("WebRTC connection established");
                             break;
+                        case 'webrtc_candidate':
+                            // null means the server finished gathering
+                            if (!message.candidate) break;
+                            if (remoteDescriptionSet) addRemoteCandidate(message.candidate);
+                            else pendingRemoteCandidates.push(message.candidate);
+                            break;
                         case 'webrtc_metainfo':
                             const capabilities = message.capabilities;
                             const media_meta = message.media_meta;
                             console.log("Driver Capabilities:", capabilities);
                             console.log("Media Meta:", media_meta);
+                            console.log("Viewer role:", message.role);
+                            if (message.role === 'viewer') {
+                                showToast(i18n.t('view_only_mode'), 3000);
+                            }
+                            updateRoleUI(message.role);
                             // Update UI based on capabilities
                             await updateUIBasedOnCapabilities(capabilities);
                             setInterval(() => force_sync(pc), 1000);
@@ -178,12 +233,35 @@ async function start() {
                                 }
                             });
                             break;
+                        case 'config_updated':
+                            console.log("Driver config updated, media meta:", message.media_meta);
+                            showToast(i18n.t('quality_changed', { name: qualityPresetName() }), 2000);
+                            break;
+                        case 'file_transfer':
+                            showFileTransfer(message);
+                            break;
+                        case 'role_changed':
+                            console.log("Viewer role changed:", message.role);
+                            showToast(i18n.t(message.role === 'controller' ? 'control_granted' : 'view_only_mode'), 3000);
+                            updateRoleUI(message.role);
+                            await updateUIBasedOnCapabilities(message.capabilities);
+                            break;
+                        case 'control_requested':
+                            // 其他 Viewer 请求控制权，由当前控制者确认后交接
+                            if (confirm(i18n.t('control_request_confirm'))) {
+                                window.ws.send(JSON.stringify({ stage: 'handover', viewer_id: message.viewer_id }));
+                            }
+                            break;
                         default:
                             break;
                     }
                     break;
                 case 'error':
                     console.error("Error from server:", message);
+                    if (message.stage === 'file_transfer') {
+                        showToast(i18n.t('upload_failed', { name: message.name, msg: message.message }), 3000);
+                        break;
+                    }
                     showToast(i18n.t('error_from_server', { msg: message.message }), 2000);
                     break;
                 default:
@@ -207,6 +285,18 @@ async function start() {
                         console.log("HTTPS is required for clipboard access.");
                     }
                     break;
+                case 0x18: // TYPE_CLIPBOARD_ACK
+                    if (typeof receiveClipboardAck === 'function') {
+                        receiveClipboardAck(view);
+                    }
+                    break;
+                case 0x0F: { // TYPE_UHID_OUTPUT: [ID 2][Size 2][Data N]
+                    const dv = new DataView(view.buffer, view.byteOffset, view.byteLength);
+                    const id = dv.getUint16(1);
+                    const size = dv.getUint16(3);
+                    dispatchUHIDOutput(id, view.slice(5, 5 + size));
+                    break;
+                }
                 case 0x64: // TYPE_TEXT_MSG
                     const textMsg = decoder.decode(view.slice(1));
                     console.log("Text message from agent:", textMsg);
@@ -219,6 +309,106 @@ async function start() {
     };
 }
 
+// UHID output reports (keyboard LEDs, gamepad rumble) are routed to the script
+// that created the device, keyed by UHID device ID.
+const uhidOutputHandlers = {};
+function onUHIDOutput(id, handler) {
+    uhidOutputHandlers[id] = handler;
+}
+function dispatchUHIDOutput(id, data) {
+    const handler = uhidOutputHandlers[id];
+    if (handler) {
+        handler(data);
+    } else {
+        console.log("Unhandled UHID output for device", id, data);
+    }
+}
+
+// Pause streaming while the tab is hidden so the device can stop encoding.
+// Short tab switches are ignored, resuming Android streams restarts the encoder.
+const PAUSE_DELAY_MS = 5000;
+function watchVisibility() {
+    let pauseTimer = null;
+    let paused = false;
+    const send = (stage) => {
+        if (window.ws && window.ws.readyState === WebSocket.OPEN) {
+            window.ws.send(JSON.stringify({ stage: stage }));
+        }
+    };
+    const update = () => {
+        if (document.hidden) {
+            if (pauseTimer || paused) return;
+            pauseTimer = setTimeout(() => {
+                pauseTimer = null;
+                paused = true;
+                send('pause');
+            }, PAUSE_DELAY_MS);
+        } else {
+            clearTimeout(pauseTimer);
+            pauseTimer = null;
+            if (paused) {
+                paused = false;
+                send('resume');
+            }
+        }
+    };
+    document.addEventListener('visibilitychange', update);
+    update();
+}
+
+// Quality presets cycled by the quality button. The driver restarts its encoder
+// with the new settings while the WebRTC connection stays up.
+const QUALITY_PRESETS = {
+    android: [
+        { name: '720p', config: { max_size: '1280', max_fps: '30', video_bit_rate: '2M' } },
+        { name: '900p', config: { max_size: '1600', max_fps: '60', video_bit_rate: '4M' } },
+        { name: '1080p', config: { max_size: '1920', max_fps: '60', video_bit_rate: '8M' } },
+    ],
+    linux: [
+        { name: '720p', config: { resolution: '1280x720', frameRate: '30', bitRate: '2000000' } },
+        { name: '900p', config: { resolution: '1600x900', frameRate: '60', bitRate: '4000000' } },
+        { name: '1080p', config: { resolution: '1920x1080', frameRate: '60', bitRate: '8000000' } },
+    ],
+};
+let qualityPresets = [];
+let qualityIndex = -1;
+
+function qualityPresetName() {
+    const preset = qualityPresets[qualityIndex];
+    return preset ? preset.name : '';
+}
+
+// 文件上传进度: uploading -> pushing / installing -> done
+function showFileTransfer(message) {
+    switch (message.phase) {
+        case 'uploading':
+            showToast(i18n.t('upload_progress', { name: message.name, progress: message.progress }), 1500);
+            break;
+        case 'pushing':
+            if (message.progress > 0) {
+                showToast(i18n.t('upload_pushing_progress', { name: message.name, progress: message.progress }), 1500);
+                break;
+            }
+            showToast(i18n.t('upload_pushing', { name: message.name }), 3000);
+            break;
+        case 'installing':
+            showToast(i18n.t('upload_installing', { name: message.name }), 3000);
+            break;
+        case 'done':
+            showToast(i18n.t('upload_done', { name: message.name, msg: message.message }), 3000);
+            break;
+    }
+}
+
+function cycleQuality() {
+    if (!qualityPresets.length || !window.ws || window.ws.readyState !== WebSocket.OPEN) return;
+    qualityIndex = (qualityIndex + 1) % qualityPresets.length;
+    window.ws.send(JSON.stringify({
+        stage: 'update_config',
+        driver_config: qualityPresets[qualityIndex].config,
+    }));
+}
+
 let lastJitterDelay = 0;
 let lastEmittedCount = 0;
 
@@ -291,6 +481,22 @@ function loadScript(src) {
     });
 }
 
+// 只读时隐藏控制按钮并显示申请控制权按钮
+function updateRoleUI(role) {
+    const viewOnly = role !== 'controller';
+    document.querySelectorAll('.feature-request-control').forEach(el => el.style.display = viewOnly ? '' : 'none');
+    if (viewOnly) {
+        document.querySelectorAll('.control-btn[class*="feature-"]:not(.feature-request-control), .separator[class*="feature-"]')
+            .forEach(el => el.style.display = 'none');
+    }
+}
+
+function requestControl() {
+    if (!window.ws || window.ws.readyState !== WebSocket.OPEN) return;
+    window.ws.send(JSON.stringify({ stage: 'request_control' }));
+    showToast(i18n.t('control_requested'), 2000);
+}
+
 async function updateUIBasedOnCapabilities(caps) {
     if (!caps) return;
 
@@ -316,12 +522,21 @@ async function updateUIBasedOnCapabilities(caps) {
                 show('.feature-android-buttons');
                 show('.feature-control');
             }
+            qualityPresets = caps.is_linux ? QUALITY_PRESETS.linux : caps.is_android ? QUALITY_PRESETS.android : [];
+            if (qualityPresets.length) show('.feature-quality');
 
             console.log("Control scripts loaded");
         } catch (e) {
             console.error("Failed to load control scripts", e);
         }
 
+        if (caps.can_system_panels) {
+            show('.feature-system-panels');
+        }
+        if (caps.can_display_power) {
+            show('.feature-display-power');
+        }
+
         // Handle Clipboard
         if (caps.can_clipboard) {
             try {
@@ -5,6 +5,8 @@
  */
 const STORAGE_KEY = 'webscreen_device_configs';
 let knownDevices = [];
+// Devices remembered by the server (/api/registry), keyed by serial
+let registeredDevices = {};
 let activeConfigSerial = null;
 
 // Refactored structure to match new requirements (all in driver_config)
@@ -19,7 +21,13 @@ const defaultScrcpyConfig = {
         audio_codec: 'opus',
         video_bit_rate: 8000000,
         video_codec_options: '',
-        new_display: ''
+        new_display: '',
+        display_id: '',
+        video_source: 'display',
+        camera_id: '',
+        camera_facing: '',
+        camera_size: '',
+        text_via_clipboard: 'false'
     }
 };
 
@@ -101,6 +109,17 @@ function pruneDeviceConfigs(activeDevices) {
 
 // --- Formatting Helpers ---
 
+function escapeHTML(str) {
+    return String(str ?? '').replace(/[&<>"']/g, c => ({
+        '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'
+    })[c]);
+}
+
+function registryTagsHtml(entry) {
+    if (!entry || !entry.tags || !entry.tags.length) return '';
+    return entry.tags.map(tag => `<span class="px-2 py-0.5 rounded-md bg-[var(--md-sys-color-secondary-container)] text-xs text-[var(--md-sys-color-on-secondary-container)]">${escapeHTML(tag)}</span>`).join('');
+}
+
 function formatBitrate(value) {
     if (!value) return '';
     if (value >= 1000000000) return `${(value / 1000000000).toFixed(1)}G`;
@@ -123,11 +142,16 @@ function parseBitrate(str) {
 
 // --- UI Rendering ---
 
+function offlineDevices() {
+    return Object.values(registeredDevices).filter(e => !knownDevices.some(d => d.device_id === e.device_id));
+}
+
 function renderDeviceList() {
     const grid = document.getElementById('deviceGrid');
     grid.innerHTML = '';
+    const offline = offlineDevices();
 
-    if (!knownDevices.length) {
+    if (!knownDevices.length && !offline.length) {
         grid.innerHTML = `
                     <div class="col-span-full flex flex-col items-center justify-center py-20 text-gray-500 bg-[#1e1f20]/50 rounded-3xl border border-dashed border-gray-700">
                         <span class="material-symbols-rounded text-5xl mb-4 opacity-50">phonelink_off</span>
@@ -141,6 +165,7 @@ function renderDeviceList() {
     knownDevices.forEach(device => {
         const serial = typeof device === 'string' ? device : device.device_id;
         const config = ensureDeviceConfig(device);
+        const entry = registeredDevices[serial];
         // Access nested driver_config now
         const drv = config.driver_config || {};
 
@@ -153,6 +178,8 @@ function renderDeviceList() {
             if (drv.max_fps) tagsHtml += `<span class="px-2 py-0.5 rounded-md bg-[#333] text-xs text-gray-300 font-mono">${drv.max_fps}FPS</span>`;
             if (drv.video_bit_rate) tagsHtml += `<span class="px-2 py-0.5 rounded-md bg-[#333] text-xs text-gray-300 font-mono">${formatBitrate(drv.video_bit_rate)}</span>`;
             if (drv.video_codec) tagsHtml += `<span class="px-2 py-0.5 rounded-md bg-[#333] text-xs text-gray-300 font-mono uppercase">${drv.video_codec}</span>`;
+            if (drv.display_id) tagsHtml += `<span class="px-2 py-0.5 rounded-md bg-[#333] text-xs text-gray-300 font-mono">${i18n.t('display_id')} ${drv.display_id}</span>`;
+            if (drv.video_source === 'camera') tagsHtml += `<span class="px-2 py-0.5 rounded-md bg-[#333] text-xs text-gray-300 font-mono">${i18n.t('video_source_camera')}</span>`;
             if (drv.audio === 'true') {
                 tagsHtml += `<span class="px-2 py-0.5 rounded-md bg-[#333] text-xs text-gray-300 font-mono">Audio</span>`;
             } else {
@@ -160,6 +187,13 @@ function renderDeviceList() {
             }
         }
 
+        // unauthorized, offline (adb), unreachable or password_required (SSH host)
+        const status = device.status || '';
+        const statusHtml = status && status !== 'connected' && status !== 'active'
+            ? `<p class="text-xs text-[var(--md-sys-color-error,#f2b8b5)]">${i18n.t('status_' + status)}</p>`
+            : '';
+        const isRemoteHost = config.device_type === 'xvfb' && serial !== 'local_xvfb';
+
         const card = document.createElement('div');
         card.className = 'card rounded-[24px] p-5 flex flex-col justify-between h-full border border-transparent hover:border-[#444] group';
 
@@ -168,18 +202,26 @@ function renderDeviceList() {
                         <div class="flex justify-between items-start mb-4">
                             <div class="flex items-center gap-3">
                                 <div class="w-10 h-10 rounded-full bg-[var(--md-sys-color-secondary-container)] flex items-center justify-center text-[var(--md-sys-color-on-secondary-container)]">
-                                    <span class="material-symbols-rounded">smartphone</span>
+                                    <span class="material-symbols-rounded">${config.device_type === 'xvfb' ? 'desktop_windows' : 'smartphone'}</span>
                                 </div>
                                 <div>
-                                    <h3 class="font-medium text-lg leading-tight text-[#e3e3e3] truncate max-w-[140px] md:max-w-[180px]" title="${serial}">${serial}</h3>
+                                    <h3 class="font-medium text-lg leading-tight text-[#e3e3e3] truncate max-w-[140px] md:max-w-[180px]" title="${serial}">${escapeHTML(entry && entry.name || serial)}</h3>
+                                    ${entry && entry.name ? `<p class="text-xs text-gray-500 font-mono truncate max-w-[140px] md:max-w-[180px]">${serial}</p>` : ''}
+                                    ${statusHtml}
                                 </div>
                             </div>
-                            <button onclick="showConfigModal('${serial}')" class="p-2 rounded-full hover:bg-white/10 text-gray-400 transition-colors" title="Settings">
-                                <span class="material-symbols-rounded">settings</span>
-                            </button>
+                            <div class="flex">
+                                ${isRemoteHost ? `<button onclick="removeHost('${serial}')" class="p-2 rounded-full hover:bg-white/10 text-gray-400 transition-colors" title="${i18n.t('remove_host')}">
+                                    <span class="material-symbols-rounded">delete</span>
+                                </button>` : ''}
+                                <button onclick="showConfigModal('${serial}')" class="p-2 rounded-full hover:bg-white/10 text-gray-400 transition-colors" title="Settings">
+                                    <span class="material-symbols-rounded">settings</span>
+                                </button>
+                            </div>
                         </div>
 
                         <div class="flex flex-wrap gap-2 mb-6">
+                            ${registryTagsHtml(entry)}
                             ${tagsHtml || `<span class="text-xs text-gray-500 italic">${i18n.t('default_config')}</span>`}
                         </div>
                     </div>
@@ -191,6 +233,46 @@ function renderDeviceList() {
                 `;
         grid.appendChild(card);
     });
+
+    offline.forEach(entry => grid.appendChild(renderOfflineCard(entry)));
+}
+
+// Registered device that adb does not report right now
+function renderOfflineCard(entry) {
+    const serial = entry.device_id;
+    const lastSeen = new Date(entry.last_seen);
+    const lastSeenHtml = lastSeen.getFullYear() > 2000
+        ? `<p class="text-xs text-gray-500 mb-6">${i18n.t('last_seen', {time: lastSeen.toLocaleString(i18n.lang)})}</p>`
+        : '<div class="mb-6"></div>';
+
+    const card = document.createElement('div');
+    card.className = 'card rounded-[24px] p-5 flex flex-col justify-between h-full border border-dashed border-[#333] opacity-60 hover:opacity-100 transition-opacity';
+    card.innerHTML = `
+                <div>
+                    <div class="flex justify-between items-start mb-4">
+                        <div class="flex items-center gap-3">
+                            <div class="w-10 h-10 rounded-full bg-[#2a2b2c] flex items-center justify-center text-gray-500">
+                                <span class="material-symbols-rounded">phonelink_off</span>
+                            </div>
+                            <div>
+                                <h3 class="font-medium text-lg leading-tight text-[#e3e3e3] truncate max-w-[140px] md:max-w-[180px]" title="${serial}">${escapeHTML(entry.name || serial)}</h3>
+                                <p class="text-xs text-gray-500 font-mono truncate max-w-[140px] md:max-w-[180px]">${entry.name ? serial : i18n.t('device_offline')}</p>
+                            </div>
+                        </div>
+                        <button onclick="forgetDevice('${entry.device_type}', '${serial}')" class="p-2 rounded-full hover:bg-white/10 text-gray-400 transition-colors" title="${i18n.t('forget_device')}">
+                            <span class="material-symbols-rounded">delete</span>
+                        </button>
+                    </div>
+                    <div class="flex flex-wrap gap-2 mb-2">${registryTagsHtml(entry)}</div>
+                    ${lastSeenHtml}
+                </div>
+                ${entry.device_type === 'android' ? `
+                <button onclick="reconnectDevice('${serial}')" class="w-full py-3 rounded-full bg-[#2a2b2c] hover:bg-[#333] text-[var(--md-sys-color-primary)] font-medium transition-all flex items-center justify-center gap-2">
+                    <span class="material-symbols-rounded">refresh</span>
+                    ${i18n.t('reconnect')}
+                </button>` : ''}
+            `;
+    return card;
 }
 
 // --- Actions ---
@@ -219,6 +301,10 @@ async function fetchDevices() {
 
         renderDeviceList();
         showToast(i18n.t('refreshed_found', {n: devices.length}));
+        // A failing adb server or xvfb host is reported per device type
+        Object.entries(data.errors || {}).forEach(([type, msg]) => {
+            showToast(i18n.t('device_list_error', {type, msg}), 'error');
+        });
 
     } catch (error) {
         console.warn('Using mock data because fetch failed:', error);
@@ -233,7 +319,230 @@ async function fetchDevices() {
     }
 }
 
+// Live device list pushed by /api/device/events (Server-Sent Events)
+function watchDevices() {
+    if (!window.EventSource) return;
+    const source = new EventSource('/api/device/events');
+
+    // Sent on every (re)connect with the full list
+    source.addEventListener('snapshot', (e) => {
+        const data = JSON.parse(e.data);
+        knownDevices = Array.isArray(data.devices) ? data.devices : [];
+        knownDevices.forEach(d => ensureDeviceConfig(d));
+        renderDeviceList();
+        fetchRegistry();
+    });
+
+    source.addEventListener('device', (e) => {
+        const ev = JSON.parse(e.data);
+        const device = ev.device;
+        const index = knownDevices.findIndex(d => d.device_id === device.device_id);
+        if (ev.event === 'removed') {
+            if (index !== -1) knownDevices.splice(index, 1);
+            // Keep it as an offline card, the server records the same time
+            registeredDevices[device.device_id] = {
+                device_type: device.device_type, device_id: device.device_id, name: '', tags: [],
+                ...registeredDevices[device.device_id],
+                last_seen: new Date().toISOString()
+            };
+            showToast(i18n.t('device_removed', {id: device.device_id}), 'info');
+        } else {
+            if (index === -1) {
+                knownDevices.push(device);
+            } else {
+                knownDevices[index] = device;
+            }
+            ensureDeviceConfig(device);
+            if (!registeredDevices[device.device_id]) fetchRegistry();
+            if (ev.event === 'added') {
+                showToast(i18n.t(device.status === 'unauthorized' ? 'device_added_unauthorized' : 'device_added', {id: device.device_id}));
+            } else if (ev.previous_status === 'unauthorized' && device.status === 'connected') {
+                showToast(i18n.t('device_authorized', {id: device.device_id}));
+            }
+        }
+        renderDeviceList();
+    });
+
+    source.onerror = () => {
+        // EventSource reconnects by itself and receives a new snapshot
+        console.warn('Device event stream interrupted, reconnecting');
+    };
+}
+
+// --- Device Registry ---
+
+async function fetchRegistry() {
+    try {
+        const response = await fetch('/api/registry');
+        if (!response.ok) throw new Error('API Error');
+        const data = await response.json();
+        registeredDevices = {};
+        (data.devices || []).forEach(entry => {
+            registeredDevices[entry.device_id] = entry;
+            // Defaults saved on the server are shared by everyone and win over this browser's copy
+            if (entry.driver_config && Object.keys(entry.driver_config).length) {
+                const config = ensureDeviceConfig(entry);
+                config.driver_config = { ...config.driver_config, ...entry.driver_config };
+            }
+        });
+        saveDeviceConfigs(deviceConfigs);
+        renderDeviceList();
+    } catch (error) {
+        console.warn('Failed to fetch device registry:', error);
+    }
+}
+
+async function saveRegistryEntry(config, name, tags) {
+    // The registry stores driver options as strings, like the websocket connect message
+    const driverConfig = Object.fromEntries(
+        Object.entries(config.driver_config || {}).map(([k, v]) => [k, String(v ?? '')])
+    );
+    try {
+        const response = await fetch(`/api/registry/${config.device_type}/${encodeURIComponent(config.device_id)}`, {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name, tags, driver_config: driverConfig })
+        });
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.error || 'API Error');
+        registeredDevices[config.device_id] = { ...registeredDevices[config.device_id], ...data.device };
+        renderDeviceList();
+    } catch (error) {
+        console.error(error);
+        showToast(i18n.t('registry_save_failed'), 'error');
+    }
+}
+
+async function reconnectDevice(serial) {
+    showToast(i18n.t('reconnecting', {id: serial}), 'info');
+    try {
+        const response = await fetch(`/api/device/android/${encodeURIComponent(serial)}/reconnect`, { method: 'POST' });
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.error || i18n.t('connection_failed'));
+        // The device event stream adds the card back
+        showToast(i18n.t('connected_success'));
+    } catch (error) {
+        console.error(error);
+        showToast(error.message, 'error');
+    }
+}
+
+async function forgetDevice(type, serial) {
+    if (!confirm(i18n.t('forget_device_confirm', {id: serial}))) return;
+    try {
+        const response = await fetch(`/api/registry/${type}/${encodeURIComponent(serial)}`, { method: 'DELETE' });
+        if (!response.ok && response.status !== 404) throw new Error('API Error');
+        delete registeredDevices[serial];
+        renderDeviceList();
+    } catch (error) {
+        console.error(error);
+        showToast(i18n.t('call_api_failed'), 'error');
+    }
+}
+
+// --- Nearby devices (mDNS) ---
+
+const DISCOVERY_POLL_MS = 5000;
+let discoveryTimer = null;
+
+async function fetchDiscovered() {
+    if (document.hidden) return;
+    try {
+        const response = await fetch('/api/device/discovery');
+        if (!response.ok) throw new Error('API Error');
+        const data = await response.json();
+        if (!data.enabled) {
+            // Disabled on the server (-discover=false)
+            clearInterval(discoveryTimer);
+            document.getElementById('discoverySection').classList.add('hidden');
+            return;
+        }
+        renderDiscoveredList(Array.isArray(data.devices) ? data.devices : []);
+    } catch (error) {
+        console.warn('Failed to fetch discovered devices:', error);
+    }
+}
+
+function renderDiscoveredList(devices) {
+    const section = document.getElementById('discoverySection');
+    const list = document.getElementById('discoveryList');
+    section.classList.toggle('hidden', !devices.length);
+    list.innerHTML = '';
+
+    devices.forEach(d => {
+        let action;
+        if (d.service === 'pairing') {
+            action = `<button onclick="pairDiscovered('${d.ip}', ${d.port})" class="px-4 py-2 rounded-full bg-[#2a2b2c] hover:bg-[#333] text-[var(--md-sys-color-primary)] text-sm font-medium">${i18n.t('pair')}</button>`;
+        } else if (d.connected) {
+            action = `<span class="text-xs text-gray-500">${i18n.t('discovered_connected')}</span>`;
+        } else {
+            action = `<button onclick="connectDiscovered('${d.ip}', ${d.port})" class="px-4 py-2 rounded-full bg-[var(--md-sys-color-primary)] text-[var(--md-sys-color-on-primary)] text-sm font-medium hover:opacity-90">${i18n.t('connect')}</button>`;
+        }
+
+        const item = document.createElement('div');
+        item.className = 'card rounded-[20px] p-4 flex items-center justify-between gap-3';
+        item.innerHTML = `
+                    <div class="flex items-center gap-3 min-w-0">
+                        <span class="material-symbols-rounded text-gray-400">${d.service === 'pairing' ? 'qr_code_scanner' : 'wifi'}</span>
+                        <div class="min-w-0">
+                            <p class="font-medium text-sm text-[#e3e3e3] truncate" title="${d.name}">${d.name}</p>
+                            <p class="text-xs text-gray-500 font-mono">${d.ip}:${d.port} · ${i18n.t(d.service === 'pairing' ? 'discovered_pairing' : 'discovered_connect')}</p>
+                        </div>
+                    </div>
+                    ${action}
+                `;
+        list.appendChild(item);
+    });
+}
+
+async function connectDiscovered(ip, port) {
+    try {
+        const response = await fetch('/api/device/connect', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ device_type: 'android', ip, port: String(port) })
+        });
+        if (!response.ok) {
+            const data = await response.json();
+            throw new Error(data.error || i18n.t('connection_failed'));
+        }
+        showToast(i18n.t('connected_success'));
+        fetchDiscovered();
+    } catch (error) {
+        console.error(error);
+        showToast(error.message, 'error');
+    }
+}
+
+// The pairing code is only shown on the phone, so prefill the rest
+function pairDiscovered(ip, port) {
+    document.getElementById('pairIP').value = ip;
+    document.getElementById('pairPort').value = port;
+    document.getElementById('pairCode').value = '';
+    openModal('pairModal');
+    document.getElementById('pairCode').focus();
+}
+
+function watchDiscovered() {
+    fetchDiscovered();
+    discoveryTimer = setInterval(fetchDiscovered, DISCOVERY_POLL_MS);
+}
+
+function updateConnectFields() {
+    const isHost = document.getElementById('connectType').value === 'xvfb';
+    const auth = document.getElementById('connectAuth').value;
+    const port = document.getElementById('connectPort');
+    // Switch the port default along with the type, keep anything the user typed
+    if (port.value === (isHost ? '5555' : '22')) port.value = isHost ? '22' : '5555';
+    port.placeholder = isHost ? '22' : '5555';
+    document.getElementById('connectPortLabel').textContent = i18n.t(isHost ? 'port_ssh' : 'port_default');
+    document.getElementById('connectSSHFields').classList.toggle('hidden', !isHost);
+    document.getElementById('connectKeyField').classList.toggle('hidden', auth !== 'key');
+    document.getElementById('connectPasswordField').classList.toggle('hidden', auth !== 'password');
+}
+
 async function connectDevice() {
+    const type = document.getElementById('connectType').value;
     const ip = document.getElementById('connectIP').value;
     const port = document.getElementById('connectPort').value;
 
@@ -242,21 +551,53 @@ async function connectDevice() {
         return;
     }
 
+    const body = { device_type: type, ip, port };
+    if (type === 'xvfb') {
+        Object.assign(body, {
+            user: document.getElementById('connectUser').value.trim(),
+            auth: document.getElementById('connectAuth').value,
+            key_file: document.getElementById('connectKeyFile').value.trim(),
+            password: document.getElementById('connectPassword').value
+        });
+        if (!body.user) {
+            showToast(i18n.t('fill_all_fields'), 'error');
+            return;
+        }
+        // Logging in over SSH can take a few seconds
+        showToast(i18n.t('checking_host'), 'info');
+    }
+
     try {
         const response = await fetch('/api/device/connect', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
-            body: JSON.stringify({ device_type: 'android', ip, port })
+            body: JSON.stringify(body)
         });
 
         if (response.ok) {
             showToast(i18n.t('connected_success'));
             closeModal('connectModal');
+            document.getElementById('connectPassword').value = '';
             fetchDevices();
         } else {
             const data = await response.json();
             throw new Error(data.error || i18n.t('connection_failed'));
         }
+    } catch (error) {
+        console.error(error);
+        showToast(type === 'xvfb' ? error.message : i18n.t('call_api_failed'), 'error');
+    }
+}
+
+async function removeHost(id) {
+    if (!confirm(i18n.t('remove_host_confirm', {id}))) return;
+    try {
+        const response = await fetch(`/api/hosts/${encodeURIComponent(id)}`, { method: 'DELETE' });
+        if (!response.ok && response.status !== 404) throw new Error('API Error');
+        // The device event stream removes the card
+        await fetch(`/api/registry/xvfb/${encodeURIComponent(id)}`, { method: 'DELETE' });
+        delete registeredDevices[id];
+        renderDeviceList();
     } catch (error) {
         console.error(error);
         showToast(i18n.t('call_api_failed'), 'error');
@@ -335,9 +676,19 @@ function startStream(serial) {
                 video_bit_rate: String(drv.video_bit_rate || 8000000),
                 video_codec_options: drv.video_codec_options || '',
                 new_display: drv.new_display || '',
-                max_size: drv.max_size || ''
+                display_id: drv.display_id || '',
+                max_size: drv.max_size || '',
+                text_via_clipboard: drv.text_via_clipboard || 'false'
             }
         };
+        if (drv.video_source === 'camera') {
+            Object.assign(finalConfig.driver_config, {
+                video_source: 'camera',
+                camera_id: drv.camera_id || '',
+                camera_facing: drv.camera_facing || '',
+                camera_size: drv.camera_size || ''
+            });
+        }
     }
 
     console.log('Starting stream with config:', finalConfig);
@@ -385,6 +736,9 @@ function showConfigModal(serial) {
     const xvfbSettings = document.getElementById('xvfbSettings');
 
     document.getElementById('configAVSync').checked = config.av_sync || false;
+    const entry = registeredDevices[serial] || {};
+    document.getElementById('configName').value = entry.name || '';
+    document.getElementById('configTags').value = (entry.tags || []).join(', ');
     console.log('Configuring modal for', serial, 'of type', config.device_type);
     if (config.device_type === 'xvfb') {
         console.log('Showing XVFB settings for', serial);
@@ -408,13 +762,86 @@ function showConfigModal(serial) {
         document.getElementById('configVideoCodec').value = drv.video_codec || 'h264';
         // document.getElementById('configVideoCodecOptions').value = drv.video_codec_options || '';
         document.getElementById('configAudio').checked = drv.audio === 'true';
+        document.getElementById('configTextViaClipboard').checked = drv.text_via_clipboard === 'true';
         document.getElementById('configNewDisplay').value = drv.new_display || '';
+        document.getElementById('configVideoSource').value = drv.video_source || 'display';
+        document.getElementById('configCameraFacing').value = drv.camera_facing || '';
+        document.getElementById('configCameraSize').value = drv.camera_size || '';
+        resetDisplayList(drv.display_id || '');
+        loadDisplays(serial);
+        resetCameraList(drv.camera_id || '');
+        updateCameraSettingsVisibility();
     }
 
     document.getElementById('configModalTitle').textContent = i18n.t('config_device_title', {serial: serial});
     openModal('configModal');
 }
 
+// --- Display Selection ---
+
+function resetDisplayList(selectedId) {
+    const select = document.getElementById('configDisplayId');
+    select.length = 1;
+    if (selectedId) select.add(new Option(selectedId, selectedId));
+    select.value = selectedId;
+}
+
+async function loadDisplays(serial) {
+    const select = document.getElementById('configDisplayId');
+    try {
+        const response = await fetch(`/api/device/android/${encodeURIComponent(serial)}/displays`);
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.error || response.status);
+        // The modal may have been closed or reopened for another device meanwhile
+        if (activeConfigSerial !== serial) return;
+        const selected = select.value;
+        select.length = 1;
+        data.displays.forEach(d => select.add(new Option(`${d.id} (${d.size})`, d.id)));
+        select.value = selected;
+    } catch (e) {
+        console.error('Failed to list displays:', e);
+        showToast(i18n.t('display_list_failed', { msg: e.message }));
+    }
+}
+
+// --- Camera Source ---
+
+// Keeps only the "auto" entry plus the saved id until the device's cameras are fetched
+function resetCameraList(selectedId) {
+    const select = document.getElementById('configCameraId');
+    select.length = 1;
+    select.dataset.loaded = '';
+    if (selectedId) select.add(new Option(selectedId, selectedId));
+    select.value = selectedId;
+}
+
+function updateCameraSettingsVisibility() {
+    const isCamera = document.getElementById('configVideoSource').value === 'camera';
+    document.getElementById('cameraSettings').classList.toggle('hidden', !isCamera);
+    if (isCamera) loadCameras(activeConfigSerial);
+}
+
+async function loadCameras(serial) {
+    const select = document.getElementById('configCameraId');
+    if (!serial || select.dataset.loaded) return;
+    select.dataset.loaded = 'true';
+    try {
+        const response = await fetch(`/api/device/android/${encodeURIComponent(serial)}/cameras`);
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.error || response.status);
+        const selected = select.value;
+        select.length = 1;
+        data.cameras.forEach(cam => {
+            const label = `${cam.id} (${i18n.t('camera_facing_' + cam.facing)}, ${cam.size})`;
+            select.add(new Option(label, cam.id));
+        });
+        select.value = selected;
+    } catch (e) {
+        console.error('Failed to list cameras:', e);
+        showToast(i18n.t('camera_list_failed', { msg: e.message }));
+    }
+}
+
 function saveDeviceConfig() {
     if (!activeConfigSerial) return;
 
@@ -447,11 +874,20 @@ function saveDeviceConfig() {
         drv.video_codec = document.getElementById('configVideoCodec').value;
         // drv.video_codec_options = document.getElementById('configVideoCodecOptions').value.trim();
         drv.new_display = document.getElementById('configNewDisplay').value.trim();
+        drv.display_id = document.getElementById('configDisplayId').value;
+        drv.video_source = document.getElementById('configVideoSource').value;
+        drv.camera_facing = document.getElementById('configCameraFacing').value;
+        drv.camera_id = document.getElementById('configCameraId').value;
+        drv.camera_size = document.getElementById('configCameraSize').value.trim();
         drv.audio = document.getElementById('configAudio').checked ? 'true' : 'false';
+        drv.text_via_clipboard = document.getElementById('configTextViaClipboard').checked ? 'true' : 'false';
         drv.audio_codec = 'opus'; // Hardcoded default for now
     }
 
     saveDeviceConfigs(deviceConfigs);
+    const name = document.getElementById('configName').value.trim();
+    const tags = document.getElementById('configTags').value.split(',').map(t => t.trim()).filter(Boolean);
+    saveRegistryEntry(config, name, tags);
     renderDeviceList();
     closeModal('configModal');
     showToast(i18n.t('config_saved'));
@@ -477,4 +913,8 @@ function showToast(message, type = 'success') {
 }
 
 // Initialize
-document.addEventListener('DOMContentLoaded', fetchDevices);
+document.addEventListener('DOMContentLoaded', () => {
+    fetchDevices();
+    watchDevices();
+    watchDiscovered();
+});
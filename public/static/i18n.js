@@ -32,6 +32,7 @@ const translations = {
         leave_empty_disable: "Leave empty to disable",
         save_settings: "Save Settings",
         refreshed_found: "Refreshed: Found {n} devices",
+        device_list_error: "Could not list {type} devices: {msg}",
         enter_ip: "Please enter IP address",
         connected_success: "Connected successfully!",
         connection_failed: "Connection failed",
@@ -78,6 +79,87 @@ const translations = {
         unlock_verify_success: "Verification successful",
 
         error_empty_sdp_answer: "Received empty SDP answer from server. WebRTC connection cannot be established.",
+        view_only_mode: "Joined as viewer (view only)",
+        quality: "Switch quality",
+        quality_changed: "Quality: {name}",
+        notification_panel: "Notifications",
+        settings_panel: "Quick settings",
+        display_power: "Turn screen off/on (keep mirroring)",
+        display_off: "Device screen off, mirroring continues",
+        display_on: "Device screen on",
+        caps_lock_on: "Caps Lock on",
+        caps_lock_off: "Caps Lock off",
+        num_lock_on: "Num Lock on",
+        num_lock_off: "Num Lock off",
+        paste_done: "Pasted on device",
+        paste_failed: "Device did not confirm the paste",
+        upload_file: "Upload file / install APK",
+        upload_progress: "Uploading {name}: {progress}%",
+        upload_pushing: "Copying {name} to the device...",
+        upload_pushing_progress: "Copying {name} to the device: {progress}%",
+        upload_installing: "Installing {name}...",
+        upload_done: "{name}: {msg}",
+        upload_failed: "Upload of {name} failed: {msg}",
+        video_source: "Video Source",
+        video_source_display: "Screen",
+        video_source_camera: "Camera",
+        camera_facing: "Facing",
+        camera_facing_any: "Any",
+        camera_facing_back: "Back",
+        camera_facing_front: "Front",
+        camera_facing_external: "External",
+        camera_id: "Camera ID",
+        camera_auto: "Auto",
+        camera_size: "Resolution",
+        camera_list_failed: "Failed to list cameras: {msg}",
+        display_id: "Display",
+        display_default: "Default display",
+        display_id_hint: "Cannot be used together with New Display",
+        display_list_failed: "Failed to list displays: {msg}",
+        device_added: "Device connected: {id}",
+        device_added_unauthorized: "Device {id} connected, allow USB debugging on the phone",
+        device_authorized: "USB debugging allowed: {id}",
+        device_removed: "Device disconnected: {id}",
+        nearby_devices: "Nearby devices",
+        nearby_devices_subtitle: "Android devices on your network with wireless debugging turned on",
+        discovered_connect: "Wireless debugging",
+        discovered_pairing: "Waiting for pairing",
+        discovered_connected: "Connected",
+        device_identity: "Device",
+        device_name: "Name",
+        device_name_placeholder: "e.g. Test phone 12",
+        device_tags: "Tags",
+        device_tags_hint: "Separate with commas",
+        last_seen: "Last seen {time}",
+        device_offline: "Offline",
+        forget_device: "Forget device",
+        forget_device_confirm: "Forget {id}? Its name, tags and defaults will be deleted.",
+        reconnect: "Reconnect",
+        reconnecting: "Reconnecting {id}...",
+        registry_save_failed: "Failed to save name and tags on the server",
+        connect_device_type: "Device type",
+        connect_type_android: "Android (wireless debugging)",
+        connect_type_xvfb: "Linux host (SSH)",
+        port_ssh: "SSH port (default 22)",
+        ssh_user: "User",
+        ssh_auth: "Authentication",
+        ssh_auth_agent: "SSH agent / default key",
+        ssh_auth_key: "Private key file",
+        ssh_auth_password: "Password",
+        ssh_key_file: "Private key path (on the server)",
+        ssh_password: "Password",
+        checking_host: "Logging in over SSH...",
+        remove_host: "Remove host",
+        remove_host_confirm: "Remove {id}? Its SSH login will be deleted from the server.",
+        status_unreachable: "Unreachable",
+        status_password_required: "Connect again to enter the ssh password",
+        status_unauthorized: "Allow USB debugging on the phone",
+        status_offline: "Offline",
+        request_control: "Request control",
+        control_requested: "Control requested, waiting for the controller",
+        control_request_confirm: "Another viewer asks to control this device. Hand over control?",
+        control_granted: "You now control this device",
+        text_via_clipboard: "Paste non-ASCII text through the clipboard (replaces the device clipboard)",
     },
     zh: {
         app_title: "WebScreen 控制台",
@@ -112,6 +194,7 @@ const translations = {
         leave_empty_disable: "留空以禁用此选项",
         save_settings: "保存设置",
         refreshed_found: "已刷新: 发现 {n} 台设备",
+        device_list_error: "无法获取 {type} 设备: {msg}",
         enter_ip: "请输入 IP 地址",
         connected_success: "连接成功!",
         connection_failed: "连接失败",
@@ -158,6 +241,87 @@ const translations = {
         unlock_verify_success: "验证成功",
 
         error_empty_sdp_answer: "从服务器收到空的 SDP 答案，无法建立 WebRTC 连接。",
+        view_only_mode: "已以观看者身份加入（仅观看）",
+        quality: "切换画质",
+        quality_changed: "画质: {name}",
+        notification_panel: "通知栏",
+        settings_panel: "快捷设置",
+        display_power: "关闭/打开屏幕 (继续投屏)",
+        display_off: "设备屏幕已关闭，投屏继续",
+        display_on: "设备屏幕已打开",
+        caps_lock_on: "大写锁定已开启",
+        caps_lock_off: "大写锁定已关闭",
+        num_lock_on: "数字锁定已开启",
+        num_lock_off: "数字锁定已关闭",
+        paste_done: "已粘贴到设备",
+        paste_failed: "设备未确认粘贴",
+        upload_file: "上传文件 / 安装 APK",
+        upload_progress: "正在上传 {name}: {progress}%",
+        upload_pushing: "正在把 {name} 复制到设备...",
+        upload_pushing_progress: "正在把 {name} 复制到设备: {progress}%",
+        upload_installing: "正在安装 {name}...",
+        upload_done: "{name}: {msg}",
+        upload_failed: "{name} 上传失败: {msg}",
+        video_source: "视频来源",
+        video_source_display: "屏幕",
+        video_source_camera: "摄像头",
+        camera_facing: "朝向",
+        camera_facing_any: "任意",
+        camera_facing_back: "后置",
+        camera_facing_front: "前置",
+        camera_facing_external: "外接",
+        camera_id: "摄像头 ID",
+        camera_auto: "自动",
+        camera_size: "分辨率",
+        camera_list_failed: "获取摄像头列表失败: {msg}",
+        display_id: "显示器",
+        display_default: "默认显示器",
+        display_id_hint: "与 New Display 不能同时使用",
+        display_list_failed: "获取显示器列表失败: {msg}",
+        device_added: "设备已连接: {id}",
+        device_added_unauthorized: "设备 {id} 已连接，请在手机上允许 USB 调试",
+        device_authorized: "已允许 USB 调试: {id}",
+        device_removed: "设备已断开: {id}",
+        nearby_devices: "附近的设备",
+        nearby_devices_subtitle: "局域网内开启了无线调试的 Android 设备",
+        discovered_connect: "无线调试",
+        discovered_pairing: "等待配对",
+        discovered_connected: "已连接",
+        device_identity: "设备",
+        device_name: "名称",
+        device_name_placeholder: "例如: 测试机 12 号",
+        device_tags: "标签",
+        device_tags_hint: "用逗号分隔",
+        last_seen: "最后在线 {time}",
+        device_offline: "离线",
+        forget_device: "删除设备",
+        forget_device_confirm: "删除 {id}？名称、标签和默认设置将被删除。",
+        reconnect: "重新连接",
+        reconnecting: "正在重新连接 {id}...",
+        registry_save_failed: "名称和标签保存到服务器失败",
+        connect_device_type: "设备类型",
+        connect_type_android: "Android (无线调试)",
+        connect_type_xvfb: "Linux 主机 (SSH)",
+        port_ssh: "SSH 端口 (默认 22)",
+        ssh_user: "用户名",
+        ssh_auth: "认证方式",
+        ssh_auth_agent: "SSH Agent / 默认密钥",
+        ssh_auth_key: "私钥文件",
+        ssh_auth_password: "密码",
+        ssh_key_file: "私钥路径 (服务器上)",
+        ssh_password: "密码",
+        checking_host: "正在通过 SSH 登录...",
+        remove_host: "删除主机",
+        remove_host_confirm: "删除 {id}？服务器上保存的 SSH 登录信息将被删除。",
+        status_unreachable: "无法访问",
+        status_password_required: "请重新连接并输入 ssh 密码",
+        status_unauthorized: "请在手机上允许 USB 调试",
+        status_offline: "离线",
+        request_control: "申请控制",
+        control_requested: "已申请控制，等待控制者确认",
+        control_request_confirm: "另一位观看者申请控制此设备，是否交出控制权？",
+        control_granted: "你现在可以控制此设备",
+        text_via_clipboard: "通过剪贴板粘贴非 ASCII 文本（会覆盖设备剪贴板）",
     },
     ja: {
         app_title: "WebScreen コンソール",
@@ -192,6 +356,7 @@ const translations = {
         leave_empty_disable: "無効にする場合は空欄",
         save_settings: "設定を保存",
         refreshed_found: "更新完了: {n} 台のデバイスが見つかりました",
+        device_list_error: "{type} デバイスを取得できません: {msg}",
         enter_ip: "IPアドレスを入力してください",
         connected_success: "接続成功!",
         connection_failed: "接続失敗",
@@ -238,6 +403,87 @@ const translations = {
         unlock_verify_success: "検証成功",
 
         error_empty_sdp_answer: "サーバーから空のSDPアンサーが受信されました。WebRTC接続を確立できません。",
+        view_only_mode: "視聴者として参加しました（閲覧のみ）",
+        quality: "画質を切り替え",
+        quality_changed: "画質: {name}",
+        notification_panel: "通知パネル",
+        settings_panel: "クイック設定",
+        display_power: "画面をオフ/オン（ミラーリングは継続）",
+        display_off: "端末の画面をオフにしました（ミラーリングは継続）",
+        display_on: "端末の画面をオンにしました",
+        caps_lock_on: "Caps Lock オン",
+        caps_lock_off: "Caps Lock オフ",
+        num_lock_on: "Num Lock オン",
+        num_lock_off: "Num Lock オフ",
+        paste_done: "端末に貼り付けました",
+        paste_failed: "端末が貼り付けを確認しませんでした",
+        upload_file: "ファイルをアップロード / APK をインストール",
+        upload_progress: "{name} をアップロード中: {progress}%",
+        upload_pushing: "{name} をデバイスにコピー中...",
+        upload_pushing_progress: "{name} をデバイスにコピー中: {progress}%",
+        upload_installing: "{name} をインストール中...",
+        upload_done: "{name}: {msg}",
+        upload_failed: "{name} のアップロードに失敗しました: {msg}",
+        video_source: "映像ソース",
+        video_source_display: "画面",
+        video_source_camera: "カメラ",
+        camera_facing: "向き",
+        camera_facing_any: "指定なし",
+        camera_facing_back: "背面",
+        camera_facing_front: "前面",
+        camera_facing_external: "外部",
+        camera_id: "カメラ ID",
+        camera_auto: "自動",
+        camera_size: "解像度",
+        camera_list_failed: "カメラ一覧の取得に失敗しました: {msg}",
+        display_id: "ディスプレイ",
+        display_default: "デフォルトのディスプレイ",
+        display_id_hint: "New Display と同時には使用できません",
+        display_list_failed: "ディスプレイ一覧の取得に失敗しました: {msg}",
+        device_added: "デバイスが接続されました: {id}",
+        device_added_unauthorized: "デバイス {id} が接続されました。スマートフォンで USB デバッグを許可してください",
+        device_authorized: "USB デバッグが許可されました: {id}",
+        device_removed: "デバイスが切断されました: {id}",
+        nearby_devices: "近くのデバイス",
+        nearby_devices_subtitle: "ネットワーク上でワイヤレスデバッグが有効な Android デバイス",
+        discovered_connect: "ワイヤレスデバッグ",
+        discovered_pairing: "ペア設定待ち",
+        discovered_connected: "接続済み",
+        device_identity: "デバイス",
+        device_name: "名前",
+        device_name_placeholder: "例: テスト端末 12",
+        device_tags: "タグ",
+        device_tags_hint: "カンマで区切ります",
+        last_seen: "最終接続 {time}",
+        device_offline: "オフライン",
+        forget_device: "デバイスを削除",
+        forget_device_confirm: "{id} を削除しますか？名前、タグ、既定の設定が削除されます。",
+        reconnect: "再接続",
+        reconnecting: "{id} に再接続しています...",
+        registry_save_failed: "名前とタグをサーバーに保存できませんでした",
+        connect_device_type: "デバイスの種類",
+        connect_type_android: "Android (ワイヤレスデバッグ)",
+        connect_type_xvfb: "Linux ホスト (SSH)",
+        port_ssh: "SSH ポート (既定 22)",
+        ssh_user: "ユーザー名",
+        ssh_auth: "認証方式",
+        ssh_auth_agent: "SSH エージェント / 既定の鍵",
+        ssh_auth_key: "秘密鍵ファイル",
+        ssh_auth_password: "パスワード",
+        ssh_key_file: "秘密鍵のパス (サーバー上)",
+        ssh_password: "パスワード",
+        checking_host: "SSH でログインしています...",
+        remove_host: "ホストを削除",
+        remove_host_confirm: "{id} を削除しますか？サーバーに保存された SSH ログイン情報が削除されます。",
+        status_unreachable: "到達できません",
+        status_password_required: "もう一度接続して ssh パスワードを入力してください",
+        status_unauthorized: "スマートフォンで USB デバッグを許可してください",
+        status_offline: "オフライン",
+        request_control: "操作権をリクエスト",
+        control_requested: "操作権をリクエストしました。操作者の承認を待っています",
+        control_request_confirm: "別の視聴者がこのデバイスの操作を求めています。操作権を渡しますか？",
+        control_granted: "このデバイスを操作できるようになりました",
+        text_via_clipboard: "ASCII 以外の文字をクリップボード経由で貼り付ける（デバイスのクリップボードを上書きします）",
     }
 };
 
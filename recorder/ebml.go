@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Matroska / EBML element IDs (https://www.matroska.org/technical/elements.html)
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment        = 0x18538067
+	idInfo           = 0x1549A966
+	idTimestampScale = 0x2AD7B1
+	idDuration       = 0x4489
+	idMuxingApp      = 0x4D80
+	idWritingApp     = 0x5741
+
+	idTracks       = 0x1654AE6B
+	idTrackEntry   = 0xAE
+	idTrackNumber  = 0xD7
+	idTrackUID     = 0x73C5
+	idTrackType    = 0x83
+	idCodecID      = 0x86
+	idCodecPrivate = 0x63A2
+	idVideo        = 0xE0
+	idPixelWidth   = 0xB0
+	idPixelHeight  = 0xBA
+	idAudio        = 0xE1
+	idSampling     = 0xB5
+	idChannels     = 0x9F
+
+	idCluster     = 0x1F43B675
+	idTimestamp   = 0xE7
+	idSimpleBlock = 0xA3
+)
+
+// unknownSize 是 8 字节长度的 "未知大小" 标记，用于先占位后回填
+var unknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+func ebmlID(id uint32) []byte {
+	switch {
+	case id >= 0x1000000:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 0x10000:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 0x100:
+		return []byte{byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id)}
+	}
+}
+
+// ebmlSize 编码 EBML 可变长度整数，选取能容纳 size 的最短长度
+func ebmlSize(size uint64) []byte {
+	for n := 1; n <= 8; n++ {
+		// 全 1 的值被保留为 "未知大小"，所以上限是 2^(7n) - 2
+		if size < (uint64(1)<<(7*uint(n)))-1 {
+			buf := make([]byte, n)
+			for i := n - 1; i >= 0; i-- {
+				buf[i] = byte(size)
+				size >>= 8
+			}
+			buf[0] |= 0x80 >> uint(n-1)
+			return buf
+		}
+	}
+	return fixedSize(size)
+}
+
+// fixedSize 固定使用 8 字节编码长度，用于回填占位
+func fixedSize(size uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, size)
+	buf[0] = 0x01
+	return buf
+}
+
+func ebmlBytes(id uint32, data []byte) []byte {
+	out := ebmlID(id)
+	out = append(out, ebmlSize(uint64(len(data)))...)
+	return append(out, data...)
+}
+
+func ebmlUint(id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && v>>(8*uint(n)) != 0 {
+		n++
+	}
+	data := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		data[i] = byte(v)
+		v >>= 8
+	}
+	return ebmlBytes(id, data)
+}
+
+func ebmlFloat(id uint32, v float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(v))
+	return ebmlBytes(id, data)
+}
+
+func ebmlString(id uint32, v string) []byte {
+	return ebmlBytes(id, []byte(v))
+}
+
+func ebmlMaster(id uint32, children ...[]byte) []byte {
+	var body []byte
+	for _, c := range children {
+		body = append(body, c...)
+	}
+	return ebmlBytes(id, body)
+}
@@ -0,0 +1,164 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+
+	// 时间戳精度为 1ms
+	timestampScale = 1_000_000
+)
+
+type TrackInfo struct {
+	Number       uint64
+	Type         uint64
+	CodecID      string
+	CodecPrivate []byte
+
+	// Video
+	Width  uint32
+	Height uint32
+
+	// Audio
+	SampleRate float64
+	Channels   uint64
+}
+
+// mkvWriter 以流式方式写 Matroska 文件:
+// Segment 大小和 Duration 先占位，Close 时再回填，所以中途崩溃的文件依然可以播放
+type mkvWriter struct {
+	f *os.File
+
+	segmentSizeOffset int64
+	segmentDataStart  int64
+	durationOffset    int64
+
+	cluster     []byte
+	clusterTime int64
+	clusterOpen bool
+	lastTime    int64
+}
+
+func newMKVWriter(f *os.File, tracks []TrackInfo) (*mkvWriter, error) {
+	w := &mkvWriter{f: f}
+
+	header := ebmlMaster(idEBML,
+		ebmlUint(idEBMLVersion, 1),
+		ebmlUint(idEBMLReadVersion, 1),
+		ebmlUint(idEBMLMaxIDLength, 4),
+		ebmlUint(idEBMLMaxSizeLength, 8),
+		ebmlString(idDocType, "matroska"),
+		ebmlUint(idDocTypeVersion, 4),
+		ebmlUint(idDocTypeReadVersion, 2),
+	)
+	header = append(header, ebmlID(idSegment)...)
+	w.segmentSizeOffset = int64(len(header))
+	header = append(header, unknownSize...)
+	w.segmentDataStart = int64(len(header))
+
+	info := ebmlMaster(idInfo,
+		ebmlUint(idTimestampScale, timestampScale),
+		ebmlString(idMuxingApp, "webscreen"),
+		ebmlString(idWritingApp, "webscreen"),
+		ebmlFloat(idDuration, 0),
+	)
+	// Duration 是 Info 的最后一个子元素，其 8 字节 float 位于末尾
+	w.durationOffset = int64(len(header) + len(info) - 8)
+	header = append(header, info...)
+
+	var entries [][]byte
+	for _, t := range tracks {
+		children := [][]byte{
+			ebmlUint(idTrackNumber, t.Number),
+			ebmlUint(idTrackUID, t.Number),
+			ebmlUint(idTrackType, t.Type),
+			ebmlString(idCodecID, t.CodecID),
+		}
+		if len(t.CodecPrivate) > 0 {
+			children = append(children, ebmlBytes(idCodecPrivate, t.CodecPrivate))
+		}
+		switch t.Type {
+		case trackTypeVideo:
+			children = append(children, ebmlMaster(idVideo,
+				ebmlUint(idPixelWidth, uint64(t.Width)),
+				ebmlUint(idPixelHeight, uint64(t.Height)),
+			))
+		case trackTypeAudio:
+			children = append(children, ebmlMaster(idAudio,
+				ebmlFloat(idSampling, t.SampleRate),
+				ebmlUint(idChannels, t.Channels),
+			))
+		}
+		entries = append(entries, ebmlMaster(idTrackEntry, children...))
+	}
+	header = append(header, ebmlMaster(idTracks, entries...)...)
+
+	if _, err := f.Write(header); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteBlock 写入一个 SimpleBlock，timeMs 为相对录制开始的毫秒数
+// 视频关键帧会开启新的 Cluster，方便播放器拖动
+func (w *mkvWriter) WriteBlock(track uint64, timeMs int64, keyFrame bool, startCluster bool, data []byte) error {
+	rel := timeMs - w.clusterTime
+	if !w.clusterOpen || rel > math.MaxInt16 || rel < math.MinInt16 || (startCluster && len(w.cluster) > 0) {
+		if err := w.flushCluster(); err != nil {
+			return err
+		}
+		w.clusterOpen = true
+		w.clusterTime = timeMs
+		w.cluster = append(w.cluster[:0], ebmlUint(idTimestamp, uint64(timeMs))...)
+		rel = 0
+	}
+
+	block := make([]byte, 4, 4+len(data))
+	block[0] = 0x80 | byte(track) // track number 作为 1 字节 vint
+	binary.BigEndian.PutUint16(block[1:3], uint16(int16(rel)))
+	if keyFrame {
+		block[3] = 0x80
+	}
+	block = append(block, data...)
+	w.cluster = append(w.cluster, ebmlBytes(idSimpleBlock, block)...)
+	if timeMs > w.lastTime {
+		w.lastTime = timeMs
+	}
+	return nil
+}
+
+func (w *mkvWriter) flushCluster() error {
+	if !w.clusterOpen || len(w.cluster) == 0 {
+		return nil
+	}
+	_, err := w.f.Write(ebmlBytes(idCluster, w.cluster))
+	w.cluster = w.cluster[:0]
+	w.clusterOpen = false
+	return err
+}
+
+// Close 写出最后一个 Cluster 并回填 Segment 大小和 Duration
+func (w *mkvWriter) Close() error {
+	if err := w.flushCluster(); err != nil {
+		return err
+	}
+	end, err := w.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.WriteAt(fixedSize(uint64(end-w.segmentDataStart)), w.segmentSizeOffset); err != nil {
+		return err
+	}
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(float64(w.lastTime)))
+	if _, err := w.f.WriteAt(duration, w.durationOffset); err != nil {
+		return err
+	}
+	return nil
+}
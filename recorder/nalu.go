@@ -0,0 +1,148 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"webscreen/sdriver/comm"
+)
+
+// splitNALUs 按 Annex B 起始码切分 NALU
+// 驱动送来的数据可能带起始码、也可能是去掉了首个起始码的裸 NALU，两种情况都需要处理
+func splitNALUs(data []byte) [][]byte {
+	var nalus [][]byte
+	pos := 0
+	for pos < len(data) {
+		idx := bytes.Index(data[pos:], []byte{0, 0, 1})
+		if idx == -1 {
+			nalus = appendNALU(nalus, data[pos:])
+			break
+		}
+		end := pos + idx
+		// 4 字节起始码的前导 0
+		if end > pos && data[end-1] == 0 {
+			end--
+		}
+		nalus = appendNALU(nalus, data[pos:end])
+		pos = pos + idx + 3
+	}
+	return nalus
+}
+
+func appendNALU(nalus [][]byte, nal []byte) [][]byte {
+	// 去掉尾部的 trailing zero
+	for len(nal) > 0 && nal[len(nal)-1] == 0 {
+		nal = nal[:len(nal)-1]
+	}
+	if len(nal) == 0 {
+		return nalus
+	}
+	return append(nalus, nal)
+}
+
+func nalType(nal []byte, codec string) byte {
+	if codec == "h265" {
+		return (nal[0] >> 1) & 0x3F
+	}
+	return nal[0] & 0x1F
+}
+
+const (
+	naluKindOther = iota
+	naluKindVPS
+	naluKindSPS
+	naluKindPPS
+	naluKindAUD
+	naluKindIDR
+)
+
+func classifyNALU(nal []byte, codec string) int {
+	t := nalType(nal, codec)
+	if codec == "h265" {
+		switch {
+		case t == 32:
+			return naluKindVPS
+		case t == 33:
+			return naluKindSPS
+		case t == 34:
+			return naluKindPPS
+		case t == 35:
+			return naluKindAUD
+		case t >= 16 && t <= 21: // BLA/IDR/CRA
+			return naluKindIDR
+		}
+		return naluKindOther
+	}
+	switch t {
+	case 7:
+		return naluKindSPS
+	case 8:
+		return naluKindPPS
+	case 9:
+		return naluKindAUD
+	case 5:
+		return naluKindIDR
+	}
+	return naluKindOther
+}
+
+// buildAVCC 生成 V_MPEG4/ISO/AVC 需要的 AVCDecoderConfigurationRecord
+func buildAVCC(sps, pps []byte) ([]byte, error) {
+	if len(sps) < 4 || len(pps) == 0 {
+		return nil, fmt.Errorf("invalid h264 parameter sets")
+	}
+	buf := []byte{
+		1,      // configurationVersion
+		sps[1], // AVCProfileIndication
+		sps[2], // profile_compatibility
+		sps[3], // AVCLevelIndication
+		0xFF,   // lengthSizeMinusOne = 3
+		0xE1,   // numOfSequenceParameterSets = 1
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(sps)))
+	buf = append(buf, sps...)
+	buf = append(buf, 1) // numOfPictureParameterSets
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(pps)))
+	buf = append(buf, pps...)
+	return buf, nil
+}
+
+// buildHVCC 生成 V_MPEGH/ISO/HEVC 需要的 HEVCDecoderConfigurationRecord
+// profile_tier_level 的前 12 字节直接取自 SPS
+func buildHVCC(vps, sps, pps []byte) ([]byte, error) {
+	rbsp := comm.RemoveEmulationPreventionBytes(sps)
+	// NAL header (2) + vps_id/max_sub_layers/nesting (1) + general PTL (12)
+	if len(rbsp) < 15 || len(vps) == 0 || len(pps) == 0 {
+		return nil, fmt.Errorf("invalid h265 parameter sets")
+	}
+	buf := []byte{1} // configurationVersion
+	buf = append(buf, rbsp[3:15]...)
+	buf = append(buf,
+		0xF0, 0x00, // min_spatial_segmentation_idc
+		0xFC,       // parallelismType
+		0xFD,       // chromaFormat = 4:2:0
+		0xF8,       // bitDepthLumaMinus8
+		0xF8,       // bitDepthChromaMinus8
+		0x00, 0x00, // avgFrameRate
+		0x0F, // numTemporalLayers=1, temporalIdNested=1, lengthSizeMinusOne=3
+		3,    // numOfArrays
+	)
+	for _, nal := range [][]byte{vps, sps, pps} {
+		buf = append(buf, 0x80|nalType(nal, "h265"))
+		buf = binary.BigEndian.AppendUint16(buf, 1)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(nal)))
+		buf = append(buf, nal...)
+	}
+	return buf, nil
+}
+
+// opusHead 在驱动没有提供 OpusHead 时使用的默认值 (48kHz 双声道)
+func opusHead(channels byte) []byte {
+	buf := []byte("OpusHead")
+	buf = append(buf, 1, channels)
+	buf = binary.LittleEndian.AppendUint16(buf, 0)     // pre-skip
+	buf = binary.LittleEndian.AppendUint32(buf, 48000) // input sample rate
+	buf = binary.LittleEndian.AppendUint16(buf, 0)     // output gain
+	buf = append(buf, 0)                               // channel mapping family
+	return buf
+}
@@ -0,0 +1,340 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webscreen/sdriver"
+	"webscreen/sdriver/comm"
+)
+
+const (
+	videoTrackNumber = 1
+	audioTrackNumber = 2
+)
+
+// Info 描述一次录制的状态
+type Info struct {
+	Path string `json:"path"`
+	File string `json:"file"`
+	// 分辨率或编码参数变化后录制会切换到新文件，Files 按顺序列出所有文件
+	Files       []string  `json:"files"`
+	StartedAt   time.Time `json:"started_at"`
+	VideoFrames uint64    `json:"video_frames"`
+	AudioFrames uint64    `json:"audio_frames"`
+}
+
+type sample struct {
+	video bool
+	box   sdriver.AVBox
+}
+
+// Recorder 把驱动输出的 AVBox 原样封装为 Matroska 文件，不经过浏览器，也不重新编码
+// 时间戳直接使用驱动提供的 PTS
+// 参数集 (VPS/SPS/PPS) 变化时，例如旋转屏幕或修改画质后 scrcpy 重启编码器，
+// 旧文件的 CodecPrivate 无法解码新画面，从下一个关键帧开始写入新的分段文件
+type Recorder struct {
+	path       string
+	videoCodec string
+	hasAudio   bool
+	width      uint32
+	height     uint32
+
+	file *os.File
+	mkv  *mkvWriter
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan sample
+	done   chan struct{}
+	err    error
+
+	startedAt   time.Time
+	videoFrames atomic.Uint64
+	audioFrames atomic.Uint64
+	files       []string
+
+	// 以下字段只在写入协程中访问
+	vps, sps, pps []byte
+	// 当前文件 CodecPrivate 使用的参数集
+	hdrVPS, hdrSPS, hdrPPS []byte
+	basePTS                time.Duration
+}
+
+func New(path string, meta sdriver.MediaMeta) (*Recorder, error) {
+	codec := strings.TrimSpace(meta.VideoCodec)
+	if codec != "h264" && codec != "h265" {
+		return nil, fmt.Errorf("recording is not supported for video codec %q", meta.VideoCodec)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		path:       path,
+		videoCodec: codec,
+		hasAudio:   strings.TrimSpace(meta.AudioCodec) == "opus",
+		width:      meta.Width,
+		height:     meta.Height,
+		file:       f,
+		queue:      make(chan sample, 256),
+		done:       make(chan struct{}),
+		startedAt:  time.Now(),
+		files:      []string{path},
+	}
+	log.Printf("[recorder] start recording to %s (video: %s, audio: %v)", path, codec, r.hasAudio)
+	go r.loop()
+	return r, nil
+}
+
+func (r *Recorder) WriteVideo(box sdriver.AVBox) {
+	r.enqueue(true, box)
+}
+
+func (r *Recorder) WriteAudio(box sdriver.AVBox) {
+	if !r.hasAudio {
+		return
+	}
+	r.enqueue(false, box)
+}
+
+func (r *Recorder) enqueue(video bool, box sdriver.AVBox) {
+	// 驱动的数据引用 LinearBuffer 的底层数组，必须先拷贝一份
+	data := make([]byte, len(box.Data))
+	copy(data, box.Data)
+	box.Data = data
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.queue <- sample{video: video, box: box}:
+	default:
+		log.Println("[recorder] write queue full, dropping frame")
+	}
+}
+
+// Stop 结束录制并等待文件写完
+func (r *Recorder) Stop() (Info, error) {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+	<-r.done
+	log.Printf("[recorder] recording saved to %s", r.path)
+	return r.Info(), r.err
+}
+
+func (r *Recorder) Info() Info {
+	r.mu.Lock()
+	files := make([]string, len(r.files))
+	for i, f := range r.files {
+		files[i] = filepath.Base(f)
+	}
+	r.mu.Unlock()
+	return Info{
+		Path:        r.path,
+		File:        filepath.Base(r.path),
+		Files:       files,
+		StartedAt:   r.startedAt,
+		VideoFrames: r.videoFrames.Load(),
+		AudioFrames: r.audioFrames.Load(),
+	}
+}
+
+func (r *Recorder) loop() {
+	defer close(r.done)
+	for s := range r.queue {
+		var err error
+		if s.video {
+			err = r.handleVideo(s.box)
+		} else {
+			err = r.handleAudio(s.box)
+		}
+		if err != nil && r.err == nil {
+			log.Printf("[recorder] write failed: %v", err)
+			r.err = err
+		}
+	}
+	if r.mkv == nil {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		if r.err == nil {
+			r.err = fmt.Errorf("no key frame received, nothing recorded")
+		}
+		return
+	}
+	if err := r.closeSegment(); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Recorder) closeSegment() error {
+	err := r.mkv.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.mkv = nil
+	return err
+}
+
+// segmentPath 返回第 n 个分段的文件名，第一个分段就是 path
+func segmentPath(path string, n int) string {
+	if n <= 1 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_part%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// nextSegment 结束当前文件并创建下一个分段，文件头在拿到关键帧后写入
+func (r *Recorder) nextSegment() error {
+	if err := r.closeSegment(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	path := segmentPath(r.path, len(r.files)+1)
+	r.mu.Unlock()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.mu.Lock()
+	r.files = append(r.files, path)
+	r.mu.Unlock()
+	log.Printf("[recorder] video parameters changed, continue recording in %s", path)
+	return nil
+}
+
+func (r *Recorder) handleVideo(box sdriver.AVBox) error {
+	var frame []byte
+	keyFrame := false
+	for _, nal := range splitNALUs(box.Data) {
+		switch classifyNALU(nal, r.videoCodec) {
+		case naluKindVPS:
+			r.vps = append(r.vps[:0], nal...)
+		case naluKindSPS:
+			r.sps = append(r.sps[:0], nal...)
+		case naluKindPPS:
+			r.pps = append(r.pps[:0], nal...)
+		case naluKindAUD:
+			// 参数集写在 CodecPrivate 里，AUD 直接丢弃
+		case naluKindIDR:
+			keyFrame = true
+			frame = appendLengthPrefixed(frame, nal)
+		default:
+			frame = appendLengthPrefixed(frame, nal)
+		}
+	}
+
+	if r.mkv != nil && !r.headerMatches() {
+		// 参数集变化后的帧不能写进旧文件，在新的关键帧处切换文件
+		if !keyFrame {
+			return nil
+		}
+		if err := r.nextSegment(); err != nil {
+			return err
+		}
+	}
+	if r.mkv == nil {
+		// 文件必须从带参数集的关键帧开始
+		if !keyFrame || !r.hasParameterSets() {
+			return nil
+		}
+		if err := r.writeHeader(); err != nil {
+			return err
+		}
+		r.basePTS = box.PTS
+	}
+	if len(frame) == 0 || box.PTS < r.basePTS {
+		return nil
+	}
+	r.videoFrames.Add(1)
+	return r.mkv.WriteBlock(videoTrackNumber, int64((box.PTS-r.basePTS)/time.Millisecond), keyFrame, keyFrame, frame)
+}
+
+func (r *Recorder) handleAudio(box sdriver.AVBox) error {
+	if box.IsConfig || r.mkv == nil || box.PTS < r.basePTS {
+		return nil
+	}
+	r.audioFrames.Add(1)
+	return r.mkv.WriteBlock(audioTrackNumber, int64((box.PTS-r.basePTS)/time.Millisecond), true, false, box.Data)
+}
+
+func (r *Recorder) hasParameterSets() bool {
+	if r.videoCodec == "h265" && len(r.vps) == 0 {
+		return false
+	}
+	return len(r.sps) > 0 && len(r.pps) > 0
+}
+
+// headerMatches 判断当前参数集是否与文件头中的一致
+func (r *Recorder) headerMatches() bool {
+	return bytes.Equal(r.vps, r.hdrVPS) && bytes.Equal(r.sps, r.hdrSPS) && bytes.Equal(r.pps, r.hdrPPS)
+}
+
+// writeHeader 在拿到参数集后写出文件头，画面尺寸优先取自 SPS
+func (r *Recorder) writeHeader() error {
+	video := TrackInfo{
+		Number: videoTrackNumber,
+		Type:   trackTypeVideo,
+		Width:  r.width,
+		Height: r.height,
+	}
+	var err error
+	var info comm.SPSInfo
+	var spsErr error
+	switch r.videoCodec {
+	case "h264":
+		video.CodecID = "V_MPEG4/ISO/AVC"
+		video.CodecPrivate, err = buildAVCC(r.sps, r.pps)
+		info, spsErr = comm.ParseSPS_H264(r.sps, true)
+	case "h265":
+		video.CodecID = "V_MPEGH/ISO/HEVC"
+		video.CodecPrivate, err = buildHVCC(r.vps, r.sps, r.pps)
+		info, spsErr = comm.ParseSPS_H265(r.sps)
+	}
+	if err != nil {
+		return err
+	}
+	if spsErr == nil && info.Width > 0 && info.Height > 0 {
+		video.Width, video.Height = info.Width, info.Height
+	}
+	r.hdrVPS = append(r.hdrVPS[:0], r.vps...)
+	r.hdrSPS = append(r.hdrSPS[:0], r.sps...)
+	r.hdrPPS = append(r.hdrPPS[:0], r.pps...)
+	tracks := []TrackInfo{video}
+	if r.hasAudio {
+		tracks = append(tracks, TrackInfo{
+			Number:       audioTrackNumber,
+			Type:         trackTypeAudio,
+			CodecID:      "A_OPUS",
+			CodecPrivate: opusHead(2),
+			SampleRate:   48000,
+			Channels:     2,
+		})
+	}
+	r.mkv, err = newMKVWriter(r.file, tracks)
+	return err
+}
+
+// appendLengthPrefixed 把 NALU 转为 Matroska 要求的 4 字节长度前缀格式
+func appendLengthPrefixed(frame []byte, nal []byte) []byte {
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(nal)))
+	return append(frame, nal...)
+}
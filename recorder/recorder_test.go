@@ -0,0 +1,323 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"webscreen/sdriver"
+	"webscreen/sdriver/comm"
+)
+
+// bitWriter 用于在测试中生成 SPS
+type bitWriter struct {
+	buf   []byte
+	nbits int
+}
+
+func (w *bitWriter) bit(b uint32) {
+	if w.nbits%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if b != 0 {
+		w.buf[len(w.buf)-1] |= 0x80 >> (w.nbits % 8)
+	}
+	w.nbits++
+}
+
+func (w *bitWriter) bits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bit((v >> i) & 1)
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	n := 0
+	for t := v; t > 1; t >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(v, n+1)
+}
+
+// testSPS 生成 Baseline Profile 的 H.264 SPS，宽高必须是 16 的倍数
+func testSPS(width, height uint32) []byte {
+	w := &bitWriter{}
+	w.bits(0x67, 8) // NAL header
+	w.bits(66, 8)   // profile_idc
+	w.bits(0xC0, 8) // constraint flags
+	w.bits(30, 8)   // level_idc
+	w.ue(0)         // seq_parameter_set_id
+	w.ue(0)         // log2_max_frame_num_minus4
+	w.ue(2)         // pic_order_cnt_type
+	w.ue(1)         // max_num_ref_frames
+	w.bit(0)        // gaps_in_frame_num_value_allowed_flag
+	w.ue(width/16 - 1)
+	w.ue(height/16 - 1)
+	w.bit(1) // frame_mbs_only_flag
+	w.bit(1) // direct_8x8_inference_flag
+	w.bit(0) // frame_cropping_flag
+	w.bit(0) // vui_parameters_present_flag
+	w.bit(1) // rbsp_stop_one_bit
+	return w.buf
+}
+
+func annexB(nalus ...[]byte) []byte {
+	var out []byte
+	for _, nal := range nalus {
+		out = append(out, 0, 0, 0, 1)
+		out = append(out, nal...)
+	}
+	return out
+}
+
+// ebmlElement 是测试中解析出的 EBML 元素
+type ebmlElement struct {
+	id       uint32
+	data     []byte
+	children []ebmlElement
+}
+
+var masterIDs = map[uint32]bool{
+	idEBML: true, idSegment: true, idInfo: true, idTracks: true, idTrackEntry: true,
+	idVideo: true, idAudio: true, idCluster: true,
+}
+
+func readVint(b []byte, keepMarker bool) (uint64, int, error) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, fmt.Errorf("invalid vint")
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if len(b) < n {
+		return 0, 0, fmt.Errorf("truncated vint")
+	}
+	v := uint64(b[0])
+	if !keepMarker {
+		v &= uint64(0xFF >> n)
+	}
+	for _, c := range b[1:n] {
+		v = v<<8 | uint64(c)
+	}
+	return v, n, nil
+}
+
+// parseEBML 严格解析 EBML，元素大小必须与数据长度一致
+func parseEBML(b []byte) ([]ebmlElement, error) {
+	var elems []ebmlElement
+	for len(b) > 0 {
+		id, n, err := readVint(b, true)
+		if err != nil {
+			return nil, err
+		}
+		size, m, err := readVint(b[n:], false)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n+m:]
+		if size > uint64(len(b)) {
+			return nil, fmt.Errorf("element %x size %d exceeds remaining %d bytes", id, size, len(b))
+		}
+		e := ebmlElement{id: uint32(id), data: b[:size]}
+		if masterIDs[e.id] {
+			if e.children, err = parseEBML(e.data); err != nil {
+				return nil, fmt.Errorf("in element %x: %w", id, err)
+			}
+		}
+		elems = append(elems, e)
+		b = b[size:]
+	}
+	return elems, nil
+}
+
+func find(elems []ebmlElement, id uint32) []ebmlElement {
+	var out []ebmlElement
+	for _, e := range elems {
+		if e.id == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func uintValue(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+type parsedBlock struct {
+	timeMs   int64
+	keyFrame bool
+	nalus    [][]byte
+}
+
+type parsedFile struct {
+	codecID string
+	sps     []byte
+	width   uint64
+	height  uint64
+	blocks  []parsedBlock
+}
+
+// parseRecording 解析录制文件，检查 avcC 和每个 SimpleBlock 的 NALU 长度前缀
+func parseRecording(t *testing.T, path string) parsedFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := parseEBML(data)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	if len(top) != 2 || top[0].id != idEBML || top[1].id != idSegment {
+		t.Fatalf("%s: unexpected top level layout", path)
+	}
+	if doc := find(top[0].children, idDocType); len(doc) != 1 || string(doc[0].data) != "matroska" {
+		t.Fatalf("%s: bad DocType", path)
+	}
+	segment := top[1].children
+	entries := find(find(segment, idTracks)[0].children, idTrackEntry)
+	if len(entries) != 1 {
+		t.Fatalf("%s: expected 1 track, got %d", path, len(entries))
+	}
+	var pf parsedFile
+	entry := entries[0].children
+	pf.codecID = string(find(entry, idCodecID)[0].data)
+	avcc := find(entry, idCodecPrivate)[0].data
+	if avcc[0] != 1 || avcc[4] != 0xFF || avcc[5] != 0xE1 {
+		t.Fatalf("%s: bad avcC header % x", path, avcc[:6])
+	}
+	spsLen := int(binary.BigEndian.Uint16(avcc[6:8]))
+	pf.sps = avcc[8 : 8+spsLen]
+	video := find(entry, idVideo)[0].children
+	pf.width = uintValue(find(video, idPixelWidth)[0].data)
+	pf.height = uintValue(find(video, idPixelHeight)[0].data)
+
+	for _, cluster := range find(segment, idCluster) {
+		base := int64(uintValue(find(cluster.children, idTimestamp)[0].data))
+		for _, sb := range find(cluster.children, idSimpleBlock) {
+			rel := int16(binary.BigEndian.Uint16(sb.data[1:3]))
+			blk := parsedBlock{timeMs: base + int64(rel), keyFrame: sb.data[3]&0x80 != 0}
+			for p := sb.data[4:]; len(p) > 0; {
+				n := int(binary.BigEndian.Uint32(p))
+				if 4+n > len(p) {
+					t.Fatalf("%s: NALU length %d exceeds block", path, n)
+				}
+				blk.nalus = append(blk.nalus, p[4:4+n])
+				p = p[4+n:]
+			}
+			pf.blocks = append(pf.blocks, blk)
+		}
+	}
+	return pf
+}
+
+func TestRecorderH264(t *testing.T) {
+	pps := []byte{0x68, 0xCE, 0x38, 0x80}
+	idr := []byte{0x65, 0x88, 0x84, 0x00, 0x33}
+	slice := []byte{0x41, 0x9A, 0x02, 0x04}
+	ms := time.Millisecond
+
+	tests := []struct {
+		name   string
+		boxes  []sdriver.AVBox
+		sizes  [][2]uint64
+		blocks [][]int64 // 每个文件中各个块的时间戳
+	}{
+		{
+			name: "single segment",
+			boxes: []sdriver.AVBox{
+				{Data: slice, PTS: 0}, // 关键帧之前的帧被丢弃
+				{Data: annexB(testSPS(640, 480), pps), IsConfig: true},
+				{Data: annexB(idr), PTS: 10 * ms, IsKeyFrame: true},
+				{Data: annexB(slice), PTS: 43 * ms},
+				{Data: annexB(slice), PTS: 76 * ms},
+			},
+			sizes:  [][2]uint64{{640, 480}},
+			blocks: [][]int64{{0, 33, 66}},
+		},
+		{
+			name: "parameter sets change",
+			boxes: []sdriver.AVBox{
+				{Data: annexB(testSPS(640, 480), pps, idr), PTS: 0, IsKeyFrame: true},
+				{Data: annexB(slice), PTS: 33 * ms},
+				{Data: annexB(testSPS(480, 640), pps), IsConfig: true},
+				{Data: annexB(slice), PTS: 50 * ms}, // 新参数集下的非关键帧被丢弃
+				{Data: annexB(idr), PTS: 100 * ms, IsKeyFrame: true},
+				{Data: annexB(slice), PTS: 133 * ms},
+			},
+			sizes:  [][2]uint64{{640, 480}, {480, 640}},
+			blocks: [][]int64{{0, 33}, {0, 33}},
+		},
+		{
+			name: "same parameter sets repeated on key frames",
+			boxes: []sdriver.AVBox{
+				{Data: annexB(testSPS(320, 240), pps, idr), PTS: 0, IsKeyFrame: true},
+				{Data: annexB(slice), PTS: 33 * ms},
+				{Data: annexB(testSPS(320, 240), pps, idr), PTS: 66 * ms, IsKeyFrame: true},
+			},
+			sizes:  [][2]uint64{{320, 240}},
+			blocks: [][]int64{{0, 33, 66}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rec.mkv")
+			r, err := New(path, sdriver.MediaMeta{VideoCodec: "h264", Width: 1, Height: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, box := range tt.boxes {
+				r.WriteVideo(box)
+			}
+			info, err := r.Stop()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(info.Files) != len(tt.sizes) {
+				t.Fatalf("files = %v, want %d files", info.Files, len(tt.sizes))
+			}
+			for i, name := range info.Files {
+				pf := parseRecording(t, filepath.Join(filepath.Dir(path), name))
+				if pf.codecID != "V_MPEG4/ISO/AVC" {
+					t.Errorf("%s: codec %q", name, pf.codecID)
+				}
+				sps, err := comm.ParseSPS_H264(pf.sps, true)
+				if err != nil {
+					t.Fatalf("%s: parse SPS from avcC: %v", name, err)
+				}
+				want := tt.sizes[i]
+				if uint64(sps.Width) != want[0] || uint64(sps.Height) != want[1] || pf.width != want[0] || pf.height != want[1] {
+					t.Errorf("%s: SPS %dx%d, track %dx%d, want %dx%d", name, sps.Width, sps.Height, pf.width, pf.height, want[0], want[1])
+				}
+				if len(pf.blocks) != len(tt.blocks[i]) {
+					t.Fatalf("%s: %d blocks, want %d", name, len(pf.blocks), len(tt.blocks[i]))
+				}
+				for j, b := range pf.blocks {
+					if b.timeMs != tt.blocks[i][j] {
+						t.Errorf("%s: block %d at %dms, want %dms", name, j, b.timeMs, tt.blocks[i][j])
+					}
+					// 参数集只在 CodecPrivate 中，块里只有图像数据
+					for _, nal := range b.nalus {
+						if k := classifyNALU(nal, "h264"); k == naluKindSPS || k == naluKindPPS {
+							t.Errorf("%s: block %d carries parameter sets", name, j)
+						}
+					}
+				}
+				if first := pf.blocks[0]; !first.keyFrame || !bytes.Equal(first.nalus[0], idr) {
+					t.Errorf("%s: file does not start with the IDR frame", name)
+				}
+			}
+		})
+	}
+}
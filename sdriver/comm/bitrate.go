@@ -0,0 +1,28 @@
+package comm
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseBitrate 解析 "8000000" 或 "8M"、"800K" 形式的码率 (bps)
+func ParseBitrate(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	unit := 1
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		unit = 1_000
+		s = s[:len(s)-1]
+	case 'm', 'M':
+		unit = 1_000_000
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n * unit, true
+}
@@ -26,6 +26,8 @@ type DummyDriver struct {
 	running  bool
 	stopOnce sync.Once
 	stopCh   chan struct{}
+	// pauseCh is non-nil while paused and is closed on resume.
+	pauseCh chan struct{}
 
 	videoCh   chan sdriver.AVBox
 	audioCh   chan sdriver.AVBox
@@ -68,8 +70,12 @@ func (d *DummyDriver) GetReceivers() (<-chan sdriver.AVBox, <-chan sdriver.AVBox
 	return d.videoCh, d.audioCh, d.controlCh
 }
 
+// UpdateDriverConfig is not supported, the dummy driver replays a pre-encoded file.
 func (d *DummyDriver) UpdateDriverConfig(config map[string]string) error {
-	return nil
+	if len(config) == 0 {
+		return nil
+	}
+	return sdriver.ErrNotSupported
 }
 
 // StartStream starts reading the H.264 file and produces AVBox packets.
@@ -90,9 +96,25 @@ func (d *DummyDriver) Start() {
 	go d.loop()
 }
 
+// Pause holds the playback loop until Resume is called.
 func (d *DummyDriver) Pause() {
 	log.Println("DummyDriver: Pause called")
-	d.Stop()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pauseCh == nil {
+		d.pauseCh = make(chan struct{})
+	}
+}
+
+func (d *DummyDriver) Resume() {
+	log.Println("DummyDriver: Resume called")
+	d.mu.Lock()
+	if d.pauseCh != nil {
+		close(d.pauseCh)
+		d.pauseCh = nil
+	}
+	d.mu.Unlock()
+	d.RequestIDR(true)
 }
 
 // SendEvent is a no-op for dummy driver.
@@ -119,6 +141,11 @@ func (d *DummyDriver) RequestIDR(firstFrame bool) {
 	}
 }
 
+// SetVideoBitrate is not supported, the dummy driver replays a pre-encoded file.
+func (d *DummyDriver) SetVideoBitrate(bitrate int) error {
+	return sdriver.ErrNotSupported
+}
+
 // Capabilities reports what this driver supports.
 func (d *DummyDriver) Capabilities() sdriver.DriverCaps {
 	return sdriver.DriverCaps{CanClipboard: false, CanUHID: false, CanVideo: true, CanAudio: false, CanControl: false}
@@ -198,6 +225,19 @@ func (d *DummyDriver) loop() {
 			default:
 			}
 
+			// 暂停时停在这里，直到恢复或停止
+			d.mu.RLock()
+			pauseCh := d.pauseCh
+			d.mu.RUnlock()
+			if pauseCh != nil {
+				select {
+				case <-pauseCh:
+				case <-d.stopCh:
+					f.Close()
+					return
+				}
+			}
+
 			var isIDR, isConfig, isVCL bool
 
 			if d.mediaMeta.VideoCodec == "h265" {
@@ -1,5 +1,7 @@
 package sdriver
 
+import "encoding/binary"
+
 type EventType uint8
 
 type Event interface {
@@ -23,15 +25,27 @@ const (
 	EVENT_TYPE_SET_CLIPBOARD EventType = 0x09
 	// Clipboard Events Driver -> Agent -> Web
 	EVENT_TYPE_RECEIVE_CLIPBOARD EventType = 0x17
+	// 设置剪贴板的结果 Driver -> Agent -> Web
+	EVENT_TYPE_CLIPBOARD_ACK EventType = 0x18
+
+	// System Panel Events
+	EVENT_TYPE_BACK_OR_SCREEN_ON         EventType = 0x04
+	EVENT_TYPE_EXPAND_NOTIFICATION_PANEL EventType = 0x05
+	EVENT_TYPE_EXPAND_SETTINGS_PANEL     EventType = 0x06
+	EVENT_TYPE_COLLAPSE_PANELS           EventType = 0x07
 
 	// Command
-	EVENT_TYPE_DISPLAY_OFF EventType = 0x0A
-	EVENT_TYPE_ROTATE      EventType = 0x0B
+	EVENT_TYPE_DISPLAY_POWER EventType = 0x0A
+	EVENT_TYPE_ROTATE        EventType = 0x0B
+	EVENT_TYPE_START_APP     EventType = 0x10
+	EVENT_TYPE_TEXT          EventType = 0x11
 
 	// UHID Events
 	EVENT_TYPE_UHID_CREATE  EventType = 0x0C
 	EVENT_TYPE_UHID_INPUT   EventType = 0x0D
 	EVENT_TYPE_UHID_DESTROY EventType = 0x0E
+	// UHID Output Driver -> Agent -> Web (键盘 LED、手柄震动等)
+	EVENT_TYPE_UHID_OUTPUT EventType = 0x0F
 
 	EVENT_TYPE_REQ_IDR EventType = 0x63
 	// -> Web Toast Message
@@ -115,6 +129,60 @@ func (e RotateEvent) Type() EventType {
 	return EVENT_TYPE_ROTATE
 }
 
+// BackOrScreenOnEvent 屏幕亮着时相当于返回键，熄屏时点亮屏幕
+type BackOrScreenOnEvent struct {
+	Action byte // 0=Down, 1=Up
+}
+
+func (e BackOrScreenOnEvent) Type() EventType {
+	return EVENT_TYPE_BACK_OR_SCREEN_ON
+}
+
+type ExpandNotificationPanelEvent struct{}
+
+func (e ExpandNotificationPanelEvent) Type() EventType {
+	return EVENT_TYPE_EXPAND_NOTIFICATION_PANEL
+}
+
+type ExpandSettingsPanelEvent struct{}
+
+func (e ExpandSettingsPanelEvent) Type() EventType {
+	return EVENT_TYPE_EXPAND_SETTINGS_PANEL
+}
+
+type CollapsePanelsEvent struct{}
+
+func (e CollapsePanelsEvent) Type() EventType {
+	return EVENT_TYPE_COLLAPSE_PANELS
+}
+
+// DisplayPowerEvent 开关物理屏幕，投屏不受影响
+type DisplayPowerEvent struct {
+	On bool
+}
+
+func (e DisplayPowerEvent) Type() EventType {
+	return EVENT_TYPE_DISPLAY_POWER
+}
+
+// TextInjectEvent 输入 UTF-8 文本，不受键盘布局和输入法限制
+type TextInjectEvent struct {
+	Text string
+}
+
+func (e TextInjectEvent) Type() EventType {
+	return EVENT_TYPE_TEXT
+}
+
+// StartAppEvent 按包名启动应用，"+" 前缀表示先强制停止，"?" 前缀表示按应用名搜索
+type StartAppEvent struct {
+	Name string
+}
+
+func (e StartAppEvent) Type() EventType {
+	return EVENT_TYPE_START_APP
+}
+
 type UHIDCreateEvent struct {
 	ID             uint16 // 设备 ID (对应官方的 id 字段)
 	VendorID       uint16
@@ -147,6 +215,24 @@ func (e UHIDDestroyEvent) Type() EventType {
 	return EVENT_TYPE_UHID_DESTROY
 }
 
+type UHIDOutputEvent struct {
+	ID   uint16 // 设备 ID (对应官方的 id 字段)
+	Data []byte // HID output report
+}
+
+func (e UHIDOutputEvent) Type() EventType {
+	return EVENT_TYPE_UHID_OUTPUT
+}
+
+// GetContent 返回 [ID 2][Size 2][Data N]
+func (e UHIDOutputEvent) GetContent() []byte {
+	buf := make([]byte, 4+len(e.Data))
+	binary.BigEndian.PutUint16(buf[0:2], e.ID)
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(e.Data)))
+	copy(buf[4:], e.Data)
+	return buf
+}
+
 type IDRReqEvent struct{}
 
 func (e IDRReqEvent) Type() EventType {
@@ -183,6 +269,26 @@ func (e ReceiveClipboardEvent) GetContent() []byte {
 	return e.Content
 }
 
+// ClipboardAckEvent 是设置剪贴板的结果，OK 为 false 表示超时未收到设备确认
+type ClipboardAckEvent struct {
+	Sequence uint64
+	OK       bool
+}
+
+func (e ClipboardAckEvent) Type() EventType {
+	return EVENT_TYPE_CLIPBOARD_ACK
+}
+
+// GetContent 返回 [Sequence 8][OK 1]
+func (e ClipboardAckEvent) GetContent() []byte {
+	buf := make([]byte, 9)
+	binary.BigEndian.PutUint64(buf[0:8], e.Sequence)
+	if e.OK {
+		buf[8] = 1
+	}
+	return buf
+}
+
 type TextMsgEvent struct {
 	Msg string
 }
@@ -1,13 +1,26 @@
 package sdriver
 
+import "errors"
+
+// ErrNotSupported 表示驱动不支持某个可选操作
+var ErrNotSupported = errors.New("not supported by driver")
+
 type SDriver interface {
 	GetReceivers() (<-chan AVBox, <-chan AVBox, chan Event)
 	SendEvent(event Event) error
 
 	Start()
+	// Pause 停止编码或停止输出视频帧，设备连接和控制通道保持可用
 	Pause()
+	// Resume 恢复视频输出，并尽快发出关键帧
+	Resume()
 
 	RequestIDR(firstFrame bool)
+	// SetVideoBitrate 在推流过程中调整编码码率 (bps)，不支持时返回 ErrNotSupported
+	SetVideoBitrate(bitrate int) error
+	// UpdateDriverConfig 在推流过程中修改部分驱动参数 (分辨率、帧率、码率等)，连接保持不变
+	// config 中只包含需要修改的项，驱动不支持的项返回 ErrNotSupported
+	UpdateDriverConfig(config map[string]string) error
 	Capabilities() DriverCaps
 	// CodecInfo() (videoCodec string, audioCodec string)
 	MediaMeta() MediaMeta
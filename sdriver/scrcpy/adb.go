@@ -1,12 +1,13 @@
 package scrcpy
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
-	"os/exec"
 	"strings"
 	"time"
+	"webscreen/utils/adbclient"
 )
 
 type ADBClient struct {
@@ -30,12 +31,11 @@ func (c *ADBClient) Stop() {
 	c.cancel() // 这会触发所有绑定了该 ctx 的命令被 Kill
 }
 
-// Push 将本地文件推送到设备上
+// PushScrcpyServer 将 scrcpy-server 推送到设备上的 remotePath，之后 SupportOpusAudio 也使用这个路径
 func (c *ADBClient) PushScrcpyServer(localPath string, remotePath string) error {
-	if c.remotePath == "" {
-		c.remotePath = "/data/local/tmp/scrcpy-server"
-	}
-	err := c.adb("push", localPath, c.remotePath)
+	c.remotePath = remotePath
+	log.Printf("Pushing %s to device %s: %s", localPath, c.deviceSerial, c.remotePath)
+	err := adbclient.Default.PushFile(c.ctx, c.deviceSerial, localPath, c.remotePath, nil)
 	if err != nil {
 		return fmt.Errorf("ADB Push failed: %v", err)
 	}
@@ -43,9 +43,40 @@ func (c *ADBClient) PushScrcpyServer(localPath string, remotePath string) error
 	return nil
 }
 
+// Push 推送任意文件到设备，progress 报告已发送的字节数，可以为 nil
+func (c *ADBClient) Push(localPath string, remotePath string, progress adbclient.ProgressFunc) error {
+	log.Printf("Pushing %s to device %s: %s", localPath, c.deviceSerial, remotePath)
+	if err := adbclient.Default.PushFile(c.ctx, c.deviceSerial, localPath, remotePath, progress); err != nil {
+		return fmt.Errorf("ADB Push failed: %v", err)
+	}
+	return nil
+}
+
+// InstallAPK 推送 APK 到临时目录并用 pm install 安装，返回 pm 的输出
+// progress 报告推送阶段的进度，可以为 nil
+func (c *ADBClient) InstallAPK(localPath string, progress adbclient.ProgressFunc) (string, error) {
+	// 每次上传使用不同的文件名，同时安装多个 APK 时互不覆盖
+	remotePath := fmt.Sprintf("/data/local/tmp/webscreen_install_%s.apk", GenerateSCID())
+	// 推送中途失败也会留下不完整的文件
+	defer c.shell("rm -f " + remotePath)
+	if err := c.Push(localPath, remotePath, progress); err != nil {
+		return "", err
+	}
+	output, err := c.shell("pm install -r -t " + remotePath)
+	output = strings.TrimSpace(output)
+	if err != nil {
+		return output, fmt.Errorf("pm install failed: %v, output: %s", err, output)
+	}
+	// 旧版本 Android 的 pm 失败时退出码也是 0
+	if !strings.Contains(output, "Success") {
+		return output, fmt.Errorf("pm install failed: %s", output)
+	}
+	return output, nil
+}
+
+// Reverse 让设备上的 local (如 localabstract:scrcpy_xxx) 连接到本机的 remote (如 tcp:27183)
 func (c *ADBClient) Reverse(local, remote string) error {
-	// c.ReverseRemove(local)
-	err := c.adb("reverse", local, remote)
+	err := adbclient.Default.Reverse(c.ctx, c.deviceSerial, local, remote)
 	if err != nil {
 		return fmt.Errorf("ADB Reverse failed: %v", err)
 	}
@@ -53,41 +84,108 @@ func (c *ADBClient) Reverse(local, remote string) error {
 }
 
 func (c *ADBClient) ReverseRemove(local string) error {
-	c.adb("reverse", "--remove", local)
-	// if err != nil {
-	// 	return fmt.Errorf("ADB Reverse Remove failed: %v", err)
-	// }
+	err := adbclient.Default.ReverseRemove(c.ctx, c.deviceSerial, local)
+	if err != nil {
+		log.Printf("ADB Reverse Remove failed: %v", err)
+	}
 	return nil
 }
 
-func (c *ADBClient) StartScrcpyServer(options map[string]string) error {
+// ServerExit 是 scrcpy-server 进程的退出原因和最后几行输出
+type ServerExit struct {
+	Err    error
+	Output string
+}
+
+// 退出时保留的 scrcpy-server 输出行数
+const SERVER_OUTPUT_LINES = 20
+
+// StartScrcpyServer 启动 scrcpy-server，进程退出时从返回的 channel 收到退出原因
+func (c *ADBClient) StartScrcpyServer(options map[string]string) <-chan ServerExit {
+	// 给一点时间让 reverse tunnel 生效
+	return c.startScrcpyServer(options, time.Second*2)
+}
+
+// RestartScrcpyServer 在 reverse tunnel 已经存在时重新启动 scrcpy-server，不需要等待
+func (c *ADBClient) RestartScrcpyServer(options map[string]string) <-chan ServerExit {
+	return c.startScrcpyServer(options, 0)
+}
+
+func (c *ADBClient) startScrcpyServer(options map[string]string, delay time.Duration) <-chan ServerExit {
 	cmdStr := toScrcpyCommand(options)
+	exited := make(chan ServerExit, 1)
 
 	go func() {
-		time.Sleep(time.Second * 2) // 给一点时间让 reverse tunnel 生效
+		select {
+		case <-time.After(delay):
+		case <-c.ctx.Done():
+			exited <- ServerExit{Err: c.ctx.Err()}
+			return
+		}
 		log.Printf("Starting scrcpy server with command: %s", cmdStr)
-		err := c.adb("shell", cmdStr)
+		output := newTailWriter(SERVER_OUTPUT_LINES)
+		err := adbclient.Default.Shell(c.ctx, c.deviceSerial, cmdStr, output, nil)
 		if err != nil {
 			log.Printf("Failed to run adb shell command: %v", err)
-			return
 		} else {
 			log.Println("Scrcpy server exited normally")
 		}
+		exited <- ServerExit{Err: err, Output: output.String()}
 	}()
 
 	// 这里我们无法立即知道是否成功，因为 Shell 命令会阻塞
 	// 真正的“成功”标志是我们的 Listener Accept 到了连接
-	return nil
+	return exited
 }
 
-func (c *ADBClient) adb(args ...string) error {
-	// 如果没有指定设备地址，则使用默认 adb 命令
-	log.Printf("Executing on device %s: %s", c.deviceSerial, args)
-	if c.deviceSerial == "" {
-		return ExecADB(c.ctx, args...)
+// tailWriter 把 scrcpy-server 的输出逐行打印到日志，并保留最后几行
+type tailWriter struct {
+	max     int
+	lines   []string
+	partial []byte
+}
+
+func newTailWriter(max int) *tailWriter {
+	return &tailWriter{max: max}
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		t.addLine(string(bytes.TrimRight(t.partial[:i], "\r")))
+		t.partial = t.partial[i+1:]
 	}
-	// 否则，添加 -s 参数指定设备
-	return ExecADB(c.ctx, append([]string{"-s", c.deviceSerial}, args...)...)
+	return len(p), nil
+}
+
+func (t *tailWriter) addLine(line string) {
+	if line == "" {
+		return
+	}
+	log.Printf("[scrcpy-server] %s", line)
+	t.lines = append(t.lines, line)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+}
+
+// String 返回保留的输出，调用时命令应已退出
+func (t *tailWriter) String() string {
+	if len(t.partial) > 0 {
+		t.addLine(string(t.partial))
+		t.partial = nil
+	}
+	return strings.Join(t.lines, "\n")
+}
+
+// shell 在设备上执行命令，返回 stdout 和 stderr 的输出
+func (c *ADBClient) shell(cmd string) (string, error) {
+	log.Printf("Executing on device %s: %s", c.deviceSerial, cmd)
+	return adbclient.Default.ShellOutput(c.ctx, c.deviceSerial, cmd)
 }
 
 func (c *ADBClient) SupportOpusAudio(version, scid string) bool {
@@ -101,29 +199,18 @@ func (c *ADBClient) SupportOpusAudio(version, scid string) bool {
 	// 2. 构造 shell 命令
 	cmdStr := fmt.Sprintf("CLASSPATH=%s app_process / com.genymobile.scrcpy.Server %s scid=%s list_encoders=true", serverPath, version, scid)
 
-	// 3. 准备 adb 参数
-	var args []string
-	if c.deviceSerial != "" {
-		args = append(args, "-s", c.deviceSerial)
-	}
-	args = append(args, "shell", cmdStr)
-
-	// 4. 执行命令并捕获输出
+	// 3. 执行命令并捕获输出
 	// 使用 c.ctx 以便在父 Context 取消时能够中止命令
-	cmd := exec.CommandContext(c.ctx, "adb", args...)
-
-	output, err := cmd.CombinedOutput() // 同时获取 stdout 和 stderr
+	output, err := c.shell(cmdStr)
 	if err != nil {
 		// 命令执行失败（可能是 adb 没连接，或者 app_process 报错）
 		log.Printf("Failed to check audio encoders: %v", err)
 		return false
 	}
 
-	// 5. 检查输出中是否包含 "opus"
-	outputStr := string(output)
-
+	// 4. 检查输出中是否包含 "opus"
 	// 调试日志：可选，查看设备实际返回了什么
-	log.Printf("Encoder list output: %s", outputStr)
+	log.Printf("Encoder list output: %s", output)
 
-	return strings.Contains(outputStr, "opus")
+	return strings.Contains(output, "opus")
 }
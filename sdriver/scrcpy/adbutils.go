@@ -2,33 +2,23 @@ package scrcpy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
 	"math/rand"
 	"os"
-	"os/exec"
-	"strconv"
+
 	"strings"
 	"time"
-	"webscreen/utils"
+	"webscreen/utils/adbclient"
 )
 
-func ExecADB(ctx context.Context, args ...string) error {
-	adbPath, err := utils.GetADBPath()
-	if err != nil {
-		return err
-	}
-	cmd := exec.CommandContext(ctx, adbPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err = cmd.Run()
-	return err
-}
-
 func GenerateSCID() string {
 	seed := time.Now().UnixNano() + rand.Int63()
 	r := rand.New(rand.NewSource(seed))
-	// 生成31位随机整数
-	return strconv.FormatInt(int64(r.Uint32()&0x7FFFFFFF), 16)
+	// 生成31位随机整数，scrcpy-server 的 socket 名为 scrcpy_%08x
+	return fmt.Sprintf("%08x", r.Uint32()&0x7FFFFFFF)
 }
 
 // 将ScrcpyParams转为 key=value 格式的参数列表
@@ -45,11 +35,21 @@ func scrcpyParamsToArgs(params map[string]string) []string {
 		"audio",
 		"audio_bit_rate",
 		"audio_codec_options",
+		"audio_source",
 		"control",
+		"video_source",
+		"camera_id",
+		"camera_size",
+		"camera_facing",
+		"camera_fps",
+		"camera_ar",
+		"camera_high_speed",
+		"display_id",
 		"new_display",
 		"max_size",
 		"log_level",
 		"cleanup",
+		"send_device_meta",
 	}
 	for _, key := range keys {
 		if v, ok := params[key]; ok && v != "" {
@@ -72,60 +72,84 @@ func toScrcpyCommand(options map[string]string) string {
 
 // GetConnectedDevices returns a list of connected device serials/IPs
 func GetConnectedDevices() ([]string, error) {
-	adbPath, err := utils.GetADBPath()
-	if err != nil {
-		return nil, err
-	}
-	cmd := exec.Command(adbPath, "devices")
-	output, err := cmd.Output()
+	devices, err := adbclient.Default.Devices(context.Background())
 	if err != nil {
 		return nil, err
 	}
-
-	var devices []string
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "List of devices attached") {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) >= 2 && parts[1] == "device" {
-			devices = append(devices, parts[0])
+	var serials []string
+	for _, d := range devices {
+		if d.State == "device" {
+			serials = append(serials, d.Serial)
 		}
 	}
-	return devices, nil
+	return serials, nil
 }
 
 // ConnectDevice connects to a device via TCP/IP
 func ConnectDevice(address string) error {
-	adbPath, err := utils.GetADBPath()
+	return adbclient.Default.Connect(context.Background(), address)
+}
+
+// PairDevice pairs with a device using a pairing code
+func PairDevice(address, code string) error {
+	return adbclient.Default.Pair(context.Background(), address, code)
+}
+
+// pushEmbeddedServer 把内置的 scrcpy-server 推送到设备上的 remotePath
+// 设备上的文件内容相同时不推送；否则先推送到临时文件再 mv 过去，
+// 不会改写正在运行的 scrcpy-server 打开的文件
+// 多个会话可能同时推送，每次写到不同的临时文件
+func pushEmbeddedServer(c *ADBClient, remotePath string) error {
+	data, err := scrcpyServerData.ReadFile("bin/scrcpy-server-master")
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command(adbPath, "connect", address)
-	output, err := cmd.CombinedOutput()
+	sum := sha256.Sum256(data)
+	if output, err := c.shell("sha256sum " + remotePath + " 2>/dev/null"); err == nil && strings.HasPrefix(strings.TrimSpace(output), hex.EncodeToString(sum[:])) {
+		log.Printf("[scrcpy] %s on device %s is up to date", remotePath, c.deviceSerial)
+		c.remotePath = remotePath
+		return nil
+	}
+	f, err := os.CreateTemp("", "scrcpy-server-*")
 	if err != nil {
-		return fmt.Errorf("adb connect failed: %v, output: %s", err, string(output))
+		return err
 	}
-	if strings.Contains(string(output), "unable to connect") || strings.Contains(string(output), "failed to connect") {
-		return fmt.Errorf("adb connect failed: %s", string(output))
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
 	}
-	return nil
-}
-
-// PairDevice pairs with a device using a pairing code
-func PairDevice(address, code string) error {
-	adbPath, err := utils.GetADBPath()
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command(adbPath, "pair", address, code)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("adb pair failed: %v, output: %s", err, string(output))
+	tmpPath := fmt.Sprintf("%s.tmp-%s", remotePath, GenerateSCID())
+	if err := c.PushScrcpyServer(f.Name(), tmpPath); err != nil {
+		return err
 	}
-	if !strings.Contains(string(output), "Successfully paired") {
-		return fmt.Errorf("adb pair failed: %s", string(output))
+	if output, err := c.shell(fmt.Sprintf("mv -f %s %s", tmpPath, remotePath)); err != nil {
+		c.shell("rm -f " + tmpPath)
+		return fmt.Errorf("move scrcpy-server into place failed: %v, output: %s", err, output)
 	}
+	c.remotePath = remotePath
 	return nil
 }
+
+// runServerList 推送 scrcpy-server 并以 option=true 运行，返回它的输出
+// 用于 list_cameras、list_displays 等只列出信息就退出的参数
+// 正在投屏的会话还在使用 SCRCPY_SERVER_ANDROID_DST，这里推送到单独的文件，用完删除
+func runServerList(deviceID, option string) (string, error) {
+	adbClient := NewADBClient(deviceID, "", context.Background())
+	defer adbClient.cancel()
+	remotePath := fmt.Sprintf("%s-list-%s", SCRCPY_SERVER_ANDROID_DST, GenerateSCID())
+	if err := pushEmbeddedServer(adbClient, remotePath); err != nil {
+		return "", err
+	}
+	defer adbClient.shell("rm -f " + remotePath)
+	cmdStr := fmt.Sprintf("CLASSPATH=%s app_process / com.genymobile.scrcpy.Server %s %s=true", remotePath, SCRCPY_VERSION, option)
+	output, err := adbClient.shell(cmdStr)
+	if err != nil {
+		log.Printf("[scrcpy] %s failed: %v, output: %s", option, err, output)
+		return "", fmt.Errorf("%s failed: %v", option, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,94 @@
+package scrcpy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 摄像头模式下透传给 scrcpy-server 的参数
+var cameraOptionKeys = []string{
+	"camera_id",
+	"camera_size",
+	"camera_facing",
+	"camera_fps",
+	"camera_ar",
+	"camera_high_speed",
+}
+
+// 摄像头参数和 audio_source 允许的取值，参数会拼进 adb shell 命令，不能包含其他字符
+var cameraOptionPatterns = map[string]*regexp.Regexp{
+	// 摄像头 ID 通常是数字，外接摄像头可能是其他字母数字
+	"camera_id":         regexp.MustCompile(`^\w+$`),
+	"camera_size":       regexp.MustCompile(`^\d+x\d+$`),
+	"camera_facing":     regexp.MustCompile(`^(front|back|external)$`),
+	"camera_fps":        regexp.MustCompile(`^\d+$`),
+	"camera_ar":         regexp.MustCompile(`^(sensor|\d+:\d+|\d+(\.\d+)?)$`),
+	"camera_high_speed": regexp.MustCompile(`^(true|false)$`),
+	"audio_source":      regexp.MustCompile(`^(output|playback|mic|mic-unprocessed|mic-camcorder|mic-voice-recognition|mic-voice-communication|voice-call|voice-call-uplink|voice-call-downlink|voice-performance)$`),
+}
+
+// Camera 是 list_cameras 列出的一个摄像头
+type Camera struct {
+	ID     string `json:"id"`
+	Facing string `json:"facing"` // front, back, external
+	Size   string `json:"size"`   // 最大分辨率, 如 4000x3000
+	FPS    []int  `json:"fps"`
+}
+
+// --camera-id=0    (back, 4000x3000, fps=[15, 24, 30])
+var cameraLineRe = regexp.MustCompile(`--camera-id=(\S+)\s+\((\w+), (\d+x\d+), fps=\[([^\]]*)\]`)
+
+// applyCameraOptions 把 config 中的摄像头参数写入 options
+// 摄像头模式下 scrcpy 不支持控制，默认录制麦克风
+func applyCameraOptions(config, options map[string]string) error {
+	for key, pattern := range cameraOptionPatterns {
+		if value := config[key]; value != "" && !pattern.MatchString(value) {
+			return fmt.Errorf("invalid %s: %q", key, value)
+		}
+	}
+	options["video_source"] = "camera"
+	for _, key := range cameraOptionKeys {
+		options[key] = config[key]
+	}
+	// camera_size 与 max_size 不能同时指定
+	if options["camera_size"] != "" {
+		delete(options, "max_size")
+	}
+	options["control"] = "false"
+	options["display_id"] = ""
+	options["new_display"] = ""
+	options["audio_source"] = config["audio_source"]
+	if options["audio_source"] == "" {
+		options["audio_source"] = "mic"
+	}
+	return nil
+}
+
+func parseCameraList(output string) []Camera {
+	cameras := []Camera{}
+	for _, line := range strings.Split(output, "\n") {
+		m := cameraLineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		camera := Camera{ID: m[1], Facing: m[2], Size: m[3], FPS: []int{}}
+		for _, s := range strings.Split(m[4], ",") {
+			if fps, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+				camera.FPS = append(camera.FPS, fps)
+			}
+		}
+		cameras = append(cameras, camera)
+	}
+	return cameras
+}
+
+// ListDeviceCameras 列出设备的摄像头，需要 Android 12 及以上，不需要建立投屏
+func ListDeviceCameras(deviceID string) ([]Camera, error) {
+	output, err := runServerList(deviceID, "list_cameras")
+	if err != nil {
+		return nil, err
+	}
+	return parseCameraList(output), nil
+}
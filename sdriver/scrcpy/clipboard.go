@@ -0,0 +1,67 @@
+package scrcpy
+
+import (
+	"log"
+	"time"
+	"webscreen/sdriver"
+)
+
+// 设置剪贴板后等待设备 ACK 的最长时间，超时视为失败
+const CLIPBOARD_ACK_TIMEOUT = 3 * time.Second
+
+// trackClipboardAck 记录等待 ACK 的序列号，超时后通知浏览器失败
+func (da *ScrcpyDriver) trackClipboardAck(sequence uint64) {
+	da.clipboardMu.Lock()
+	defer da.clipboardMu.Unlock()
+	if da.clipboardPending == nil {
+		da.clipboardPending = make(map[uint64]*time.Timer)
+	}
+	if old, ok := da.clipboardPending[sequence]; ok {
+		old.Stop()
+	}
+	da.clipboardPending[sequence] = time.AfterFunc(CLIPBOARD_ACK_TIMEOUT, func() {
+		log.Printf("[scrcpy] clipboard ack timeout, sequence %d", sequence)
+		da.resolveClipboardAck(sequence, false)
+	})
+}
+
+// resolveClipboardAck 结束等待并把结果发给浏览器，未在等待中的序列号会被忽略
+func (da *ScrcpyDriver) resolveClipboardAck(sequence uint64, ok bool) {
+	da.clipboardMu.Lock()
+	timer, pending := da.clipboardPending[sequence]
+	if pending {
+		timer.Stop()
+		delete(da.clipboardPending, sequence)
+	}
+	da.clipboardMu.Unlock()
+	if !pending {
+		return
+	}
+	da.emit(sdriver.ClipboardAckEvent{Sequence: sequence, OK: ok})
+}
+
+// failClipboardAcks 控制连接断开后不会再收到 ACK，立即通知浏览器所有等待中的设置失败
+func (da *ScrcpyDriver) failClipboardAcks() {
+	da.clipboardMu.Lock()
+	sequences := make([]uint64, 0, len(da.clipboardPending))
+	for sequence := range da.clipboardPending {
+		sequences = append(sequences, sequence)
+	}
+	da.clipboardMu.Unlock()
+	for _, sequence := range sequences {
+		da.resolveClipboardAck(sequence, false)
+	}
+}
+
+// emit 把设备消息交给 Agent，不阻塞调用者
+// Agent 处理不过来或 driver 已停止时丢弃消息，避免卡住控制连接的读取
+func (da *ScrcpyDriver) emit(event sdriver.Event) {
+	if da.ctx.Err() != nil {
+		return
+	}
+	select {
+	case da.ControlChan <- event:
+	default:
+		log.Printf("[scrcpy] control channel full, dropping device message type %d", event.Type())
+	}
+}
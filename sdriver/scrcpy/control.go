@@ -2,12 +2,71 @@ package scrcpy
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"sort"
 	"webscreen/sdriver"
 )
 
-func (da *ScrcpyDriver) SendTouchEvent(e *sdriver.TouchEvent) {
+// 重启 scrcpy-server 期间没有控制连接
+var errNoControl = errors.New("control connection is not available")
+
+func (da *ScrcpyDriver) hasControl() bool {
+	da.controlMu.Lock()
+	defer da.controlMu.Unlock()
+	return da.controlConn != nil
+}
+
+// writeControl 发送一条控制消息，controlMu 保证消息不会交错，也不会写到切换中的连接上
+func (da *ScrcpyDriver) writeControl(buf []byte) error {
+	return da.writeControlState(buf, nil)
+}
+
+// writeControlState 发送控制消息，并在同一把锁内更新重启后需要恢复的状态
+func (da *ScrcpyDriver) writeControlState(buf []byte, update func()) error {
+	da.controlMu.Lock()
+	defer da.controlMu.Unlock()
+	if update != nil {
+		update()
+	}
 	if da.controlConn == nil {
+		return errNoControl
+	}
+	_, err := da.controlConn.Write(buf)
+	return err
+}
+
+// setControlConn 切换到新 scrcpy-server 的控制连接
+// UHID 设备和关闭屏幕的状态随旧进程一起消失，在新连接上重新创建
+func (da *ScrcpyDriver) setControlConn(conn net.Conn) error {
+	da.controlMu.Lock()
+	defer da.controlMu.Unlock()
+	da.controlConn = conn
+	if conn == nil {
+		return nil
+	}
+	ids := make([]int, 0, len(da.uhidDevices))
+	for id := range da.uhidDevices {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if _, err := conn.Write(da.uhidDevices[uint16(id)]); err != nil {
+			return fmt.Errorf("re-create UHID device %d: %w", id, err)
+		}
+	}
+	if da.displayOff {
+		if _, err := conn.Write([]byte{TYPE_SET_DISPLAY_POWER, 0}); err != nil {
+			return fmt.Errorf("turn display off: %w", err)
+		}
+	}
+	return nil
+}
+
+func (da *ScrcpyDriver) SendTouchEvent(e *sdriver.TouchEvent) {
+	if !da.hasControl() {
 		return
 	}
 	// log.Printf("sending touch event: %v\n", e)
@@ -32,14 +91,14 @@ func (da *ScrcpyDriver) SendTouchEvent(e *sdriver.TouchEvent) {
 	binary.BigEndian.PutUint32(buf[28:32], e.Buttons)  // Buttons (4 bytes)
 
 	// 3. 一次性发送
-	_, err := da.controlConn.Write(buf)
+	err := da.writeControl(buf)
 	if err != nil {
 		log.Printf("Error sending touch event: %v\n", err)
 	}
 }
 
 func (da *ScrcpyDriver) SendKeyEvent(e *sdriver.KeyEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 
@@ -51,7 +110,7 @@ func (da *ScrcpyDriver) SendKeyEvent(e *sdriver.KeyEvent) {
 	binary.BigEndian.PutUint32(buf[6:10], 0)        // Repeat (4 bytes)
 	binary.BigEndian.PutUint32(buf[10:14], 0)       // Meta (4 bytes)
 
-	_, err := da.controlConn.Write(buf)
+	err := da.writeControl(buf)
 	if err != nil {
 		log.Printf("Error sending key event: %v\n", err)
 	}
@@ -64,20 +123,62 @@ func (da *ScrcpyDriver) SendKeyEvent(e *sdriver.KeyEvent) {
 // }
 
 func (da *ScrcpyDriver) RotateDevice() {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 	log.Println("Sending Rotate Device command...")
 	msg := []byte{TYPE_ROTATE_DEVICE}
-	_, err := da.controlConn.Write(msg)
+	err := da.writeControl(msg)
 	if err != nil {
 		log.Printf("Error sending rotate command: %v\n", err)
 	}
 	// da.mediaMeta.Width, da.mediaMeta.Height = da.mediaMeta.Height, da.mediaMeta.Width
 }
 
+// sendCommand 发送没有参数的控制消息，如展开通知栏
+func (da *ScrcpyDriver) sendCommand(msgType uint8) {
+	if !da.hasControl() {
+		return
+	}
+	err := da.writeControl([]byte{msgType})
+	if err != nil {
+		log.Printf("Error sending control message %d: %v\n", msgType, err)
+	}
+}
+
+func (da *ScrcpyDriver) SendBackOrScreenOnEvent(e *sdriver.BackOrScreenOnEvent) {
+	if !da.hasControl() {
+		return
+	}
+	// Structure:
+	// Type (1)
+	// Action (1)
+	err := da.writeControl([]byte{TYPE_BACK_OR_SCREEN_ON, e.Action})
+	if err != nil {
+		log.Printf("Error sending back or screen on event: %v\n", err)
+	}
+}
+
+func (da *ScrcpyDriver) SendDisplayPowerEvent(e *sdriver.DisplayPowerEvent) {
+	if !da.hasControl() {
+		return
+	}
+	// Structure:
+	// Type (1)
+	// On (1)
+	var on byte
+	if e.On {
+		on = 1
+	}
+	log.Printf("Setting display power: %v", e.On)
+	err := da.writeControlState([]byte{TYPE_SET_DISPLAY_POWER, on}, func() { da.displayOff = !e.On })
+	if err != nil {
+		log.Printf("Error sending display power event: %v\n", err)
+	}
+}
+
 func (da *ScrcpyDriver) SendScrollEvent(e *sdriver.ScrollEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 	// Scroll Event Structure (21 bytes):
@@ -100,14 +201,14 @@ func (da *ScrcpyDriver) SendScrollEvent(e *sdriver.ScrollEvent) {
 	binary.BigEndian.PutUint16(buf[15:17], e.VScroll)
 	binary.BigEndian.PutUint32(buf[17:21], e.Buttons)
 
-	_, err := da.controlConn.Write(buf)
+	err := da.writeControl(buf)
 	if err != nil {
 		log.Printf("Error sending scroll event: %v\n", err)
 	}
 }
 
 func (da *ScrcpyDriver) SendSetClipboardEvent(e *sdriver.SetClipboardEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 
@@ -133,14 +234,19 @@ func (da *ScrcpyDriver) SendSetClipboardEvent(e *sdriver.SetClipboardEvent) {
 	binary.BigEndian.PutUint32(buf[10:14], uint32(length))
 	copy(buf[14:], data)
 
-	_, err := da.controlConn.Write(buf)
+	// Sequence 0 表示不需要 ACK
+	if e.Sequence != 0 {
+		da.trackClipboardAck(e.Sequence)
+	}
+	err := da.writeControl(buf)
 	if err != nil {
 		log.Printf("Error sending set clipboard event: %v\n", err)
+		da.resolveClipboardAck(e.Sequence, false)
 	}
 }
 
 func (da *ScrcpyDriver) SendGetClipboardEvent(e *sdriver.GetClipboardEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 
@@ -151,14 +257,89 @@ func (da *ScrcpyDriver) SendGetClipboardEvent(e *sdriver.GetClipboardEvent) {
 	buf[0] = byte(e.Type())
 	buf[1] = e.CopyKey
 
-	_, err := da.controlConn.Write(buf)
+	err := da.writeControl(buf)
 	if err != nil {
 		log.Printf("Error sending get clipboard event: %v\n", err)
 	}
 }
 
+// scrcpy-server 单条 INJECT_TEXT 的最大字节数
+const injectTextMaxLength = 300
+
+// SendTextInjectEvent 输入文本
+// INJECT_TEXT 依赖设备的 KeyCharacterMap，只能输入 ASCII，其他文本只能通过剪贴板粘贴
+func (da *ScrcpyDriver) SendTextInjectEvent(e *sdriver.TextInjectEvent) {
+	if !da.hasControl() || e.Text == "" {
+		return
+	}
+	if !isASCII(e.Text) {
+		// 粘贴会替换设备剪贴板且无法恢复原内容，只有用户通过 text_via_clipboard=true 明确允许、
+		// 并且剪贴板同步可用时才这样做
+		if !da.textViaClipboard || !da.capabilities.CanClipboard {
+			log.Printf("[scrcpy] dropping %d bytes of non-ASCII text, set text_via_clipboard=true to paste it through the clipboard", len(e.Text))
+			da.emit(sdriver.TextMsgEvent{Msg: "[scrcpy] Non-ASCII text can only be pasted through the device clipboard. Enable text_via_clipboard to allow replacing it."})
+			return
+		}
+		// Sequence 0 表示不需要 ACK
+		da.SendSetClipboardEvent(&sdriver.SetClipboardEvent{Paste: true, Content: []byte(e.Text)})
+		return
+	}
+	text := e.Text
+	for len(text) > 0 {
+		chunk := text[:min(len(text), injectTextMaxLength)]
+		text = text[len(chunk):]
+
+		// Structure:
+		// Type (1)
+		// Length (4)
+		// Text (length)
+		buf := make([]byte, 5+len(chunk))
+		buf[0] = TYPE_INJECT_TEXT
+		binary.BigEndian.PutUint32(buf[1:5], uint32(len(chunk)))
+		copy(buf[5:], chunk)
+
+		if err := da.writeControl(buf); err != nil {
+			log.Printf("Error sending inject text event: %v\n", err)
+			return
+		}
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func (da *ScrcpyDriver) SendStartAppEvent(e *sdriver.StartAppEvent) {
+	if !da.hasControl() {
+		return
+	}
+	// Structure:
+	// Type (1)
+	// NameLen (1)
+	// Name (NameLen)
+	name := []byte(e.Name)
+	if len(name) == 0 || len(name) > 255 {
+		log.Printf("Invalid app name length: %d\n", len(name))
+		return
+	}
+	buf := make([]byte, 2+len(name))
+	buf[0] = TYPE_START_APP
+	buf[1] = byte(len(name))
+	copy(buf[2:], name)
+
+	err := da.writeControl(buf)
+	if err != nil {
+		log.Printf("Error sending start app event: %v\n", err)
+	}
+}
+
 func (da *ScrcpyDriver) SendUHIDCreateEvent(e *sdriver.UHIDCreateEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 
@@ -206,14 +387,15 @@ func (da *ScrcpyDriver) SendUHIDCreateEvent(e *sdriver.UHIDCreateEvent) {
 
 	log.Printf("Sending UHID_CREATE (Final Fix): ID=%d NameLen=%d", e.ID, nameSize)
 
-	_, err := da.controlConn.Write(buf)
+	// scrcpy-server 重启后用同样的消息重新创建
+	err := da.writeControlState(buf, func() { da.uhidDevices[e.ID] = buf })
 	if err != nil {
 		log.Printf("Error sending uhid create event: %v\n", err)
 	}
 }
 
 func (da *ScrcpyDriver) SendUHIDInputEvent(e *sdriver.UHIDInputEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 	// Scrcpy UHID Input Protocol:
@@ -234,14 +416,14 @@ func (da *ScrcpyDriver) SendUHIDInputEvent(e *sdriver.UHIDInputEvent) {
 	offset += 2
 	copy(buf[offset:], e.Data)
 
-	_, err := da.controlConn.Write(buf)
+	err := da.writeControl(buf)
 	if err != nil {
 		log.Printf("Error sending uhid input event: %v\n", err)
 	}
 }
 
 func (da *ScrcpyDriver) SendUHIDDestroyEvent(e *sdriver.UHIDDestroyEvent) {
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return
 	}
 	// Scrcpy UHID Destroy Protocol:
@@ -252,7 +434,7 @@ func (da *ScrcpyDriver) SendUHIDDestroyEvent(e *sdriver.UHIDDestroyEvent) {
 	buf[0] = byte(e.Type())
 	binary.BigEndian.PutUint16(buf[1:], e.ID)
 
-	_, err := da.controlConn.Write(buf)
+	err := da.writeControlState(buf, func() { delete(da.uhidDevices, e.ID) })
 	if err != nil {
 		log.Printf("Error sending uhid destroy event: %v\n", err)
 	}
@@ -260,13 +442,13 @@ func (da *ScrcpyDriver) SendUHIDDestroyEvent(e *sdriver.UHIDDestroyEvent) {
 
 func (da *ScrcpyDriver) KeyFrameRequest() error {
 	// return nil
-	if da.controlConn == nil {
+	if !da.hasControl() {
 		return nil
 	}
 	log.Println("⚡ Sending Request KeyFrame (Type 99)...")
 	msg := []byte{TYPE_REQUEST_IDR}
 	//<-da.VideoChan
-	_, err := da.controlConn.Write(msg)
+	err := da.writeControl(msg)
 	if err != nil {
 		log.Printf("Error sending keyframe request: %v\n", err)
 		return err
@@ -0,0 +1,34 @@
+package scrcpy
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Display 是 list_displays 列出的一个显示器
+type Display struct {
+	ID   string `json:"id"`
+	Size string `json:"size"`
+}
+
+// --display-id=0    (1080x2400)
+var displayLineRe = regexp.MustCompile(`--display-id=(\d+)\s+\((\d+x\d+)\)`)
+
+func parseDisplayList(output string) []Display {
+	displays := []Display{}
+	for _, line := range strings.Split(output, "\n") {
+		if m := displayLineRe.FindStringSubmatch(line); m != nil {
+			displays = append(displays, Display{ID: m[1], Size: m[2]})
+		}
+	}
+	return displays
+}
+
+// ListDeviceDisplays 列出设备的显示器，折叠屏、外接显示器和桌面模式会有多个
+func ListDeviceDisplays(deviceID string) ([]Display, error) {
+	output, err := runServerList(deviceID, "list_displays")
+	if err != nil {
+		return nil, err
+	}
+	return parseDisplayList(output), nil
+}
@@ -8,11 +8,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
-	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"webscreen/sdriver"
 	"webscreen/sdriver/comm"
@@ -22,17 +24,22 @@ import (
 var scrcpyServerData embed.FS
 
 const (
-	SCRCPY_SERVER_LOCAL_PATH  = "./scrcpy-server"
 	SCRCPY_SERVER_ANDROID_DST = "/data/local/tmp/scrcpy-server"
-	SCRCPY_PROXY_PORT_DEFAULT = "27183"
 	SCRCPY_VERSION            = "3.3.4"
 )
 
+// new_display 的格式，如 1920x1080 或 1920x1080/420
+var newDisplayRe = regexp.MustCompile(`^\d+x\d+(/\d+)?$`)
+
 type ScrcpyDriver struct {
 	VideoChan   chan sdriver.AVBox
 	AudioChan   chan sdriver.AVBox
 	ControlChan chan sdriver.Event
 
+	// 等待设备确认的剪贴板序列号
+	clipboardMu      sync.Mutex
+	clipboardPending map[uint64]*time.Timer
+
 	// LinearBuffer 管理器
 	videoBuffer *comm.LinearBuffer
 	audioBuffer *comm.LinearBuffer
@@ -40,11 +47,39 @@ type ScrcpyDriver struct {
 	mediaMeta  sdriver.MediaMeta
 	deviceName string
 
-	videoConn   net.Conn
-	audioConn   net.Conn
+	videoConn net.Conn
+	audioConn net.Conn
+	// controlMu 保护控制连接的写入和切换，以及重启后需要在新连接上恢复的状态：
+	// 浏览器创建的 UHID 设备 (ID -> UHID_CREATE 消息) 和是否关闭了屏幕
+	controlMu   sync.Mutex
 	controlConn net.Conn
+	uhidDevices map[uint16][]byte
+	displayOff  bool
+	// scrcpy-server 通过 reverse tunnel 连接的本地端口，整个会话期间保持监听
+	listener net.Listener
 
 	options map[string]string
+	// 协商出的编码等级的限制，修改参数时不会超过它
+	limits codecLimits
+	// 用户设置的码率，自适应码率不会超过它
+	maxVideoBitRate int
+	// 重启 scrcpy-server 时需要等旧的读取协程退出
+	restartMu sync.Mutex
+	readers   sync.WaitGroup
+	// 暂停状态由 restartMu 保护，dropVideo 供读取协程查询
+	// awaitKeyFrame 表示恢复后还没有收到新的关键帧
+	paused        bool
+	dropVideo     atomic.Bool
+	awaitKeyFrame atomic.Bool
+	// 当前 scrcpy-server 的代数、参数和启动时间，由 restartMu 保护
+	// 代数变化说明旧进程的退出是重启或 Stop 引起的
+	serverGen       uint64
+	serverOptions   map[string]string
+	serverStartedAt time.Time
+	serverRestarts  int
+
+	// 允许通过设备剪贴板粘贴非 ASCII 文本，会覆盖设备剪贴板
+	textViaClipboard bool
 
 	capabilities sdriver.DriverCaps
 
@@ -61,6 +96,13 @@ type ScrcpyDriver struct {
 	LastIDR            []byte
 	LastPTS            time.Duration
 	LastIDRRequestTime time.Time
+
+	// 重启 scrcpy-server 后 PTS 从头开始，映射到连续的时间轴上
+	ptsMu      sync.Mutex
+	ptsOffset  time.Duration
+	ptsRebase  bool
+	lastOutPTS time.Duration
+	lastOutAt  time.Time
 }
 
 // 一个ScrcpyDriver对应一个scrcpy实例，通过本地端口建立三个连接：视频、音频、控制
@@ -71,11 +113,13 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 		AudioChan:   make(chan sdriver.AVBox, 10),
 		ControlChan: make(chan sdriver.Event, 10),
 
+		uhidDevices: make(map[uint16][]byte),
+
 		videoBuffer: comm.NewLinearBuffer(0),
 		audioBuffer: comm.NewLinearBuffer(4 * 1024 * 1024), // 4MB 音频缓冲区
 
-		// scid: GenerateSCID(),
-		scid: "00000000",
+		// 每个会话使用不同的 scid，同一台设备上的多个会话互不影响
+		scid: GenerateSCID(),
 
 		capabilities: sdriver.DriverCaps{
 			IsAndroid: true,
@@ -84,29 +128,26 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 	da.ctx, da.cancel = context.WithCancel(context.Background())
 	da.adbClient = NewADBClient(deviceID, da.scid, da.ctx)
 
-	data, err := scrcpyServerData.ReadFile("bin/scrcpy-server-master")
-	if err != nil {
-		log.Printf("[scrcpy] read scrcpy-server failed: %v", err)
-		return nil, err
-	}
-	err = os.WriteFile(SCRCPY_SERVER_LOCAL_PATH, data, 0755)
+	err = pushEmbeddedServer(da.adbClient, SCRCPY_SERVER_ANDROID_DST)
 	if err != nil {
-		log.Printf("[scrcpy] write scrcpy-server to local file failed: %v", err)
+		log.Printf("[scrcpy] Push scrcpy-server failed: %v", err)
+		da.cancel()
 		return nil, err
 	}
-	err = da.adbClient.PushScrcpyServer(SCRCPY_SERVER_LOCAL_PATH, SCRCPY_SERVER_ANDROID_DST)
+
+	// 每个会话监听一个随机端口，scrcpy-server 通过 reverse tunnel 连接过来
+	da.listener, err = net.Listen("tcp", ":0")
 	if err != nil {
-		log.Printf("[scrcpy] Push scrcpy-server failed: %v", err)
+		log.Printf("[scrcpy] Listen port failed: %v", err)
+		da.cancel()
 		return nil, err
 	}
-
-	localPort := SCRCPY_PROXY_PORT_DEFAULT
-
-	da.adbClient.ReverseRemove(fmt.Sprintf("localabstract:scrcpy_%s", da.scid))
+	localPort := strconv.Itoa(da.listener.Addr().(*net.TCPAddr).Port)
 	err = da.adbClient.Reverse(fmt.Sprintf("localabstract:scrcpy_%s", da.scid), "tcp:"+localPort)
 	if err != nil {
 		log.Printf("[scrcpy] Set up reverse tunnel failed: %v", err)
-		// listener.Close()
+		da.listener.Close()
+		da.cancel()
 		return nil, err
 	}
 	log.Printf("[scrcpy] set up reverse tunnel success: localabstract:scrcpy_%s -> tcp:%s", da.scid, localPort)
@@ -114,18 +155,10 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 	if !da.adbClient.SupportOpusAudio(SCRCPY_VERSION, da.scid) {
 		config["audio"] = "false"
 		log.Println("[scrcpy] Device does not support Opus audio encoding, disabling audio.")
-		da.ControlChan <- sdriver.TextMsgEvent{Msg: "[scrcpy] Device does not support Opus audio encoding, disabling audio."}
-	}
-	da.adbClient.PushScrcpyServer(SCRCPY_SERVER_LOCAL_PATH, SCRCPY_SERVER_ANDROID_DST)
-	os.Remove(SCRCPY_SERVER_LOCAL_PATH)
-	listener, err := net.Listen("tcp", ":"+localPort)
-	if err != nil {
-		log.Printf("[scrcpy] Listen port failed: %v", err)
-		return nil, err
+		da.emit(sdriver.TextMsgEvent{Msg: "[scrcpy] Device does not support Opus audio encoding, disabling audio."})
 	}
 	// da.adbClient.cancel()
 	log.Printf("[scrcpy] driver config: %v", config)
-	video_codec_options := ""
 	max_size, err := strconv.Atoi(config["max_size"])
 	if err != nil {
 		max_size = 3840
@@ -138,7 +171,164 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 	if err != nil {
 		video_bit_rate = 8000000
 	}
-	codecConfigStr := config["webrtc_codec"]
+	if config["display_id"] != "" && config["new_display"] != "" {
+		da.teardown()
+		return nil, fmt.Errorf("display_id and new_display cannot be used together")
+	}
+	// 这两个参数会拼进 adb shell 命令
+	if id := config["display_id"]; id != "" {
+		if _, err := strconv.ParseUint(id, 10, 32); err != nil {
+			da.teardown()
+			return nil, fmt.Errorf("invalid display_id: %q", id)
+		}
+	}
+	if size := config["new_display"]; size != "" && !newDisplayRe.MatchString(size) {
+		da.teardown()
+		return nil, fmt.Errorf("invalid new_display: %q, want WIDTHxHEIGHT or WIDTHxHEIGHT/DPI", size)
+	}
+	limits, err := parseCodecLimits(config["webrtc_codec"])
+	if err != nil {
+		da.teardown()
+		return nil, err
+	}
+	da.limits = limits
+	max_size = min(max_size, limits.maxSize)
+	max_fps = min(max_fps, limits.maxFPS)
+	video_bit_rate = min(video_bit_rate, limits.videoBitRate)
+	video_codec_options := limits.codecOptions
+	options := map[string]string{
+		"CLASSPATH":           SCRCPY_SERVER_ANDROID_DST,
+		"Version":             SCRCPY_VERSION,
+		"scid":                da.scid,
+		"max_size":            strconv.Itoa(max_size),
+		"max_fps":             strconv.Itoa(max_fps),
+		"video":               "true",
+		"video_codec_options": video_codec_options, // bitrate-mode=2 to enable CBR
+		"video_bit_rate":      strconv.Itoa(video_bit_rate),
+		"video_codec":         config["video_codec"],
+		"audio":               config["audio"],
+		"audio_bit_rate":      config["audio_bit_rate"],
+		// "audio_codec_options": "durationUs=10000", // 10ms
+		"control":     "true",
+		"display_id":  config["display_id"],
+		"new_display": config["new_display"],
+		"cleanup":     "true",
+		"log_level":   "info",
+
+		// "video_encoder":  "c2.rk.hevc.encoder",
+	}
+	da.textViaClipboard = config["text_via_clipboard"] == "true"
+	if config["video_source"] == "camera" {
+		if err := applyCameraOptions(config, options); err != nil {
+			da.teardown()
+			return nil, err
+		}
+		da.capabilities.IsCamera = true
+	}
+
+	// 连接建立之前 scrcpy-server 的退出由这里处理，不自动重启
+	da.restartMu.Lock()
+	da.launchServer(options, true)
+	da.options = options
+	da.maxVideoBitRate = video_bit_rate
+	// log.Println("Scrcpy server started successfully")
+	// conns := make([]net.Conn, 3)
+	log.Println("start tcp listening")
+
+	err = da.acceptConns(options)
+	da.restartMu.Unlock()
+	if err != nil {
+		// 同时结束 scrcpy-server 和它的监视
+		da.teardown()
+		return nil, err
+	}
+
+	return da, nil
+}
+
+// teardown 撤销 New 中已经建立的 reverse tunnel 和监听端口
+func (da *ScrcpyDriver) teardown() {
+	da.adbClient.ReverseRemove(fmt.Sprintf("localabstract:scrcpy_%s", da.scid))
+	da.listener.Close()
+	da.cancel()
+}
+
+// acceptConns 依次接受 scrcpy-server 建立的视频、音频、控制连接
+func (da *ScrcpyDriver) acceptConns(options map[string]string) error {
+	listener := da.listener
+	// 设置一个总的超时时间，如果在这个时间内没有建立所有连接，就认为失败
+	// scrcpy-server 启动失败通常会很快退出，或者根本连不上
+	timeout := time.Second * 5
+	listener.(*net.TCPListener).SetDeadline(time.Now().Add(timeout))
+
+	if options["video"] == "true" {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("[scrcpy] Accept failed (可能是 scrcpy-server 启动失败): %v", err)
+			return fmt.Errorf("failed to accept connection from scrcpy-server: %v", err)
+		}
+		if options["send_device_meta"] != "false" {
+			err = da.readDeviceMeta(conn)
+			if err != nil {
+				log.Println("Failed to read device metadata:", err)
+				return err
+			}
+			log.Printf("[scrcpy] Connected Device: %s", da.deviceName)
+		}
+
+		da.assignConn(conn)
+	}
+	if options["audio"] == "true" {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("[scrcpy] Accept failed (可能是 scrcpy-server 启动失败): %v", err)
+			return fmt.Errorf("failed to accept connection from scrcpy-server: %v", err)
+		}
+		da.assignConn(conn)
+	}
+	if options["control"] == "true" {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("[scrcpy] Accept failed (可能是 scrcpy-server 启动失败): %v", err)
+			return fmt.Errorf("failed to accept connection from scrcpy-server: %v", err)
+		}
+		if err := da.setControlConn(conn); err != nil {
+			conn.Close()
+			return err
+		}
+		da.capabilities.CanControl = true
+		da.capabilities.CanUHID = true
+		da.capabilities.CanClipboard = true
+		da.capabilities.CanSystemPanels = true
+		da.capabilities.CanDisplayPower = true
+		log.Println("Scrcpy Control Connection Established")
+	}
+
+	// 甜点值
+	if da.videoConn != nil {
+		da.videoConn.(*net.TCPConn).SetReadBuffer(4 * 1024 * 1024)
+	}
+	if da.audioConn != nil {
+		da.audioConn.(*net.TCPConn).SetReadBuffer(64 * 1024)
+	}
+
+	// 设更合理的读缓冲区大小
+	// da.videoConn.(*net.TCPConn).SetReadBuffer(2 * 1024 * 1024)
+	// da.audioConn.(*net.TCPConn).SetReadBuffer(64 * 1024)
+	return nil
+}
+
+// codecLimits 是协商出的编码等级对 scrcpy 参数的限制
+type codecLimits struct {
+	maxSize      int
+	maxFPS       int
+	videoBitRate int
+	codecOptions string
+}
+
+// parseCodecLimits 根据 webrtc_codec ("PT||MimeType||fmtp") 计算编码选项和该等级允许的最大分辨率、帧率、码率
+func parseCodecLimits(codecConfigStr string) (codecLimits, error) {
+	l := codecLimits{maxSize: math.MaxInt32, maxFPS: math.MaxInt32, videoBitRate: math.MaxInt32}
 	if codecConfigStr != "" {
 		parts := strings.Split(codecConfigStr, "||")
 		mimeType := parts[1]
@@ -147,7 +337,7 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 			log.Println("AV1 Main 5.1")
 			// --- AV1 ---
 			// PAYLOAD_TYPE_AV1_PROFILE_MAIN_5_1
-			video_codec_options += "profile=1"
+			l.codecOptions += "profile=1"
 			kv := strings.Split(sdpFmtpLine, ";")
 			for _, item := range kv {
 				item = strings.TrimSpace(item)
@@ -156,38 +346,38 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 					levelID64, err := strconv.ParseUint(levelStr, 10, 32)
 					if err != nil {
 						log.Printf("Failed to parse level-idx: %v", err)
-						return nil, err
+						return l, err
 					}
 					levelID := uint(levelID64)
 					switch levelID {
 					case 8:
 						log.Println("AV1 Level 4.0")
-						max_size = min(max_size, 1920)
-						video_bit_rate = min(video_bit_rate, 12_000_000)
+						l.maxSize = min(l.maxSize, 1920)
+						l.videoBitRate = min(l.videoBitRate, 12_000_000)
 					case 9:
 						log.Println("AV1 Level 4.1")
-						max_size = min(max_size, 1920)
-						max_fps = min(max_fps, 60)
-						video_bit_rate = min(video_bit_rate, 20_000_000)
+						l.maxSize = min(l.maxSize, 1920)
+						l.maxFPS = min(l.maxFPS, 60)
+						l.videoBitRate = min(l.videoBitRate, 20_000_000)
 					case 12:
 						log.Println("AV1 Level 5.0")
-						video_bit_rate = min(video_bit_rate, 30_000_000)
+						l.videoBitRate = min(l.videoBitRate, 30_000_000)
 					case 13:
 						log.Println("AV1 Level 5.1")
-						video_bit_rate = min(video_bit_rate, 40_000_000)
+						l.videoBitRate = min(l.videoBitRate, 40_000_000)
 					case 14:
 						log.Println("AV1 Level 5.2")
-						video_bit_rate = min(video_bit_rate, 60_000_000)
+						l.videoBitRate = min(l.videoBitRate, 60_000_000)
 					case 15:
 						log.Println("AV1 Level 5.3")
-						video_bit_rate = min(video_bit_rate, 80_000_000)
+						l.videoBitRate = min(l.videoBitRate, 80_000_000)
 					default:
 						log.Println("AV1 Unexpected Level ID:", levelID)
 						if levelID < 8 {
-							video_bit_rate = min(video_bit_rate, 10_000_000)
+							l.videoBitRate = min(l.videoBitRate, 10_000_000)
 						}
 						if levelID > 15 {
-							video_bit_rate = min(video_bit_rate, 80_000_000)
+							l.videoBitRate = min(l.videoBitRate, 80_000_000)
 						}
 					}
 				}
@@ -195,7 +385,7 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 		} else if strings.EqualFold(mimeType, "video/H265") || strings.EqualFold(mimeType, "video/HEVC") {
 			// --- H.265 (HEVC) ---
 			// Main Profile
-			video_codec_options += "profile=1"
+			l.codecOptions += "profile=1"
 			// FMTP example: "profile-id=1;tier-flag=0;level-id=123"
 			// level-id=123 (Level 4.1), level-id=153 (Level 5.1)
 			var levelID uint
@@ -207,7 +397,7 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 					levelID64, err := strconv.ParseUint(levelStr, 10, 32)
 					if err != nil {
 						log.Printf("Failed to parse level-id: %v", err)
-						return nil, err
+						return l, err
 					}
 					levelID = uint(levelID64)
 					break
@@ -216,137 +406,49 @@ func New(config map[string]string, deviceID string) (*ScrcpyDriver, error) {
 			switch levelID {
 			case 123:
 				log.Println("H.265 Level 4.1")
-				max_size = min(max_size, 1920)
-				max_fps = min(max_fps, 60)
-				video_bit_rate = min(video_bit_rate, 20_000_000)
+				l.maxSize = min(l.maxSize, 1920)
+				l.maxFPS = min(l.maxFPS, 60)
+				l.videoBitRate = min(l.videoBitRate, 20_000_000)
 			case 150:
 				log.Println("H.265 Level 5.0")
-				video_bit_rate = min(video_bit_rate, 25_000_000)
+				l.videoBitRate = min(l.videoBitRate, 25_000_000)
 			case 153:
 				log.Println("H.265 Level 5.1")
-				video_bit_rate = min(video_bit_rate, 40_000_000)
+				l.videoBitRate = min(l.videoBitRate, 40_000_000)
 			case 156:
 				log.Println("H.265 Level 5.2")
-				video_bit_rate = min(video_bit_rate, 50_000_000)
+				l.videoBitRate = min(l.videoBitRate, 50_000_000)
 			case 180:
 				log.Println("H.265 Level 6.0")
-				video_bit_rate = min(video_bit_rate, 60_000_000)
+				l.videoBitRate = min(l.videoBitRate, 60_000_000)
 			default:
 				log.Println("H.265 Unexpected Level ID:", levelID)
 				if levelID < 123 {
-					video_bit_rate = min(video_bit_rate, 10_000_000)
+					l.videoBitRate = min(l.videoBitRate, 10_000_000)
 				}
 				if levelID > 180 {
-					video_bit_rate = min(video_bit_rate, 60_000_000)
+					l.videoBitRate = min(l.videoBitRate, 60_000_000)
 				}
 			}
 		} else if strings.EqualFold(mimeType, "video/H264") {
 			// --- H.264 (AVC) ---
 			// profile-level-id : Baseline (42), Main (4d), High (64)
 			// level-asymmetry-allowed=1 is supposed to be always set
-			video_bit_rate = min(video_bit_rate, 300_000_000)
+			l.videoBitRate = min(l.videoBitRate, 300_000_000)
 			if strings.Contains(sdpFmtpLine, "profile-level-id=42") {
 				log.Println("H.264 Baseline Profile")
-				video_codec_options += "profile=1"
+				l.codecOptions += "profile=1"
 			} else if strings.Contains(sdpFmtpLine, "profile-level-id=4d") {
 				log.Println("H.264 Main Profile")
-				video_codec_options += "profile=2"
+				l.codecOptions += "profile=2"
 			} else if strings.Contains(sdpFmtpLine, "profile-level-id=64") {
 				log.Println("H.264 High Profile")
-				video_codec_options += "profile=8"
+				l.codecOptions += "profile=8"
 			}
 		}
 
 	}
-	options := map[string]string{
-		"CLASSPATH":           SCRCPY_SERVER_ANDROID_DST,
-		"Version":             SCRCPY_VERSION,
-		"scid":                da.scid,
-		"max_size":            strconv.Itoa(max_size),
-		"max_fps":             strconv.Itoa(max_fps),
-		"video":               "true",
-		"video_codec_options": video_codec_options, // bitrate-mode=2 to enable CBR
-		"video_bit_rate":      strconv.Itoa(video_bit_rate),
-		"video_codec":         config["video_codec"],
-		"audio":               config["audio"],
-		"audio_bit_rate":      config["audio_bit_rate"],
-		// "audio_codec_options": "durationUs=10000", // 10ms
-		"control":     "true",
-		"new_display": config["new_display"],
-		"cleanup":     "true",
-		"log_level":   "info",
-
-		// "video_encoder":  "c2.rk.hevc.encoder",
-	}
-
-	da.adbClient.StartScrcpyServer(options)
-	da.options = options
-	// log.Println("Scrcpy server started successfully")
-	// conns := make([]net.Conn, 3)
-	log.Println("start tcp listening")
-
-	// 设置一个总的超时时间，如果在这个时间内没有建立所有连接，就认为失败
-	// scrcpy-server 启动失败通常会很快退出，或者根本连不上
-	timeout := time.Second * 5
-	listener.(*net.TCPListener).SetDeadline(time.Now().Add(timeout))
-
-	if options["video"] == "true" {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("[scrcpy] Accept failed (可能是 scrcpy-server 启动失败): %v", err)
-			listener.Close()
-			da.adbClient.ReverseRemove(fmt.Sprintf("localabstract:scrcpy_%s", da.scid))
-			return nil, fmt.Errorf("failed to accept connection from scrcpy-server: %v", err)
-		}
-		err = da.readDeviceMeta(conn)
-		if err != nil {
-			log.Println("Failed to read device metadata:", err)
-			return nil, err
-		}
-		log.Printf("[scrcpy] Connected Device: %s", da.deviceName)
-
-		da.assignConn(conn)
-	}
-	if options["audio"] == "true" {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("[scrcpy] Accept failed (可能是 scrcpy-server 启动失败): %v", err)
-			listener.Close()
-			da.adbClient.ReverseRemove(fmt.Sprintf("localabstract:scrcpy_%s", da.scid))
-			return nil, fmt.Errorf("failed to accept connection from scrcpy-server: %v", err)
-		}
-		da.assignConn(conn)
-	}
-	if options["control"] == "true" {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("[scrcpy] Accept failed (可能是 scrcpy-server 启动失败): %v", err)
-			listener.Close()
-			da.adbClient.ReverseRemove(fmt.Sprintf("localabstract:scrcpy_%s", da.scid))
-			return nil, fmt.Errorf("failed to accept connection from scrcpy-server: %v", err)
-		}
-		da.controlConn = conn
-		da.capabilities.CanControl = true
-		da.capabilities.CanUHID = true
-		da.capabilities.CanClipboard = true
-		log.Println("Scrcpy Control Connection Established")
-	}
-
-	listener.Close()
-
-	// 甜点值
-	if da.videoConn != nil {
-		da.videoConn.(*net.TCPConn).SetReadBuffer(4 * 1024 * 1024)
-	}
-	if da.audioConn != nil {
-		da.audioConn.(*net.TCPConn).SetReadBuffer(64 * 1024)
-	}
-
-	// 设更合理的读缓冲区大小
-	// da.videoConn.(*net.TCPConn).SetReadBuffer(2 * 1024 * 1024)
-	// da.audioConn.(*net.TCPConn).SetReadBuffer(64 * 1024)
-
-	return da, nil
+	return l, nil
 }
 
 func (da *ScrcpyDriver) ShowDeviceInfo() {
@@ -13,24 +13,27 @@ func (sd *ScrcpyDriver) GetReceivers() (<-chan sdriver.AVBox, <-chan sdriver.AVB
 func (sd *ScrcpyDriver) Start() {
 	log.Println("ScrcpyDriver: Start called")
 	if sd.videoConn != nil {
-		go sd.convertVideoFrame()
+		sd.readers.Add(1)
+		go func() {
+			defer sd.readers.Done()
+			sd.convertVideoFrame()
+		}()
 	}
 	if sd.audioConn != nil {
-		go sd.convertAudioFrame()
+		sd.readers.Add(1)
+		go func() {
+			defer sd.readers.Done()
+			sd.convertAudioFrame()
+		}()
 	}
-	if sd.controlConn != nil {
-		go sd.transferControlMsg()
+	sd.controlMu.Lock()
+	controlConn := sd.controlConn
+	sd.controlMu.Unlock()
+	if controlConn != nil {
+		go sd.transferControlMsg(controlConn)
 	}
 }
 
-func (sd *ScrcpyDriver) UpdateDriverConfig(config map[string]string) error {
-	return nil
-}
-
-func (sd *ScrcpyDriver) Pause() {
-	// sd.stopVideoReader()
-}
-
 func (sd *ScrcpyDriver) SendEvent(event sdriver.Event) error {
 	switch e := event.(type) {
 	case *sdriver.TouchEvent:
@@ -41,10 +44,24 @@ func (sd *ScrcpyDriver) SendEvent(event sdriver.Event) error {
 		sd.SendScrollEvent(e)
 	case *sdriver.RotateEvent:
 		sd.RotateDevice()
+	case *sdriver.BackOrScreenOnEvent:
+		sd.SendBackOrScreenOnEvent(e)
+	case *sdriver.ExpandNotificationPanelEvent:
+		sd.sendCommand(TYPE_EXPAND_NOTIFICATION_PANEL)
+	case *sdriver.ExpandSettingsPanelEvent:
+		sd.sendCommand(TYPE_EXPAND_SETTINGS_PANEL)
+	case *sdriver.CollapsePanelsEvent:
+		sd.sendCommand(TYPE_COLLAPSE_PANELS)
+	case *sdriver.DisplayPowerEvent:
+		sd.SendDisplayPowerEvent(e)
 	case *sdriver.GetClipboardEvent:
 		sd.SendGetClipboardEvent(e)
 	case *sdriver.SetClipboardEvent:
 		sd.SendSetClipboardEvent(e)
+	case *sdriver.TextInjectEvent:
+		sd.SendTextInjectEvent(e)
+	case *sdriver.StartAppEvent:
+		sd.SendStartAppEvent(e)
 	case *sdriver.UHIDCreateEvent:
 		sd.SendUHIDCreateEvent(e)
 	case *sdriver.UHIDInputEvent:
@@ -91,16 +108,27 @@ func (sd *ScrcpyDriver) MediaMeta() sdriver.MediaMeta {
 }
 
 func (sd *ScrcpyDriver) Stop() {
+	// 之后 scrcpy-server 的退出不是意外退出
+	sd.restartMu.Lock()
+	sd.serverGen++
+	sd.restartMu.Unlock()
+	sd.closeConns()
+	sd.listener.Close()
+	// 撤销本会话的 reverse tunnel
+	sd.adbClient.Stop()
+	sd.cancel()
+}
+
+func (sd *ScrcpyDriver) closeConns() {
 	if sd.videoConn != nil {
 		sd.videoConn.Close()
 	}
 	if sd.audioConn != nil {
 		sd.audioConn.Close()
 	}
+	sd.controlMu.Lock()
 	if sd.controlConn != nil {
 		sd.controlConn.Close()
 	}
-	// sd.adbClient.ReverseRemove(fmt.Sprintf("localabstract:scrcpy_%s", sd.scid))
-	sd.adbClient.Stop()
-	sd.cancel()
+	sd.controlMu.Unlock()
 }
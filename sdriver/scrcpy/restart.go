@@ -0,0 +1,162 @@
+package scrcpy
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"strconv"
+	"time"
+	"webscreen/sdriver"
+)
+
+// SetVideoBitrate 用新的 video_bit_rate 重启 scrcpy-server
+// scrcpy-server 不支持运行时修改码率，只能断开旧连接后重新启动，期间画面会短暂停顿
+func (da *ScrcpyDriver) SetVideoBitrate(bitrate int) error {
+	da.restartMu.Lock()
+	defer da.restartMu.Unlock()
+
+	bitrate = min(bitrate, da.maxVideoBitRate)
+	if strconv.Itoa(bitrate) == da.options["video_bit_rate"] {
+		return nil
+	}
+	// 虚拟显示器会随 scrcpy-server 一起销毁，上面的应用也会被关闭
+	if da.options["new_display"] != "" {
+		return sdriver.ErrNotSupported
+	}
+	options := maps.Clone(da.options)
+	options["video_bit_rate"] = strconv.Itoa(bitrate)
+	da.options = options
+	log.Printf("[scrcpy] restarting scrcpy-server with video_bit_rate=%d", bitrate)
+	return da.restartServer(options)
+}
+
+// UpdateDriverConfig 修改 max_size、max_fps、video_bit_rate 并重启 scrcpy-server
+// 取值不会超过协商出的编码等级的限制
+func (da *ScrcpyDriver) UpdateDriverConfig(config map[string]string) error {
+	da.restartMu.Lock()
+	defer da.restartMu.Unlock()
+
+	options := maps.Clone(da.options)
+	changed := false
+	for key, value := range config {
+		var limit int
+		switch key {
+		case "max_size":
+			limit = da.limits.maxSize
+		case "max_fps":
+			limit = da.limits.maxFPS
+		case "video_bit_rate":
+			limit = da.limits.videoBitRate
+		default:
+			return fmt.Errorf("%w: %s", sdriver.ErrNotSupported, key)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid %s: %q", key, value)
+		}
+		n = min(n, limit)
+		if options[key] != strconv.Itoa(n) {
+			options[key] = strconv.Itoa(n)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if da.options["new_display"] != "" {
+		return sdriver.ErrNotSupported
+	}
+	da.options = options
+	da.maxVideoBitRate, _ = strconv.Atoi(options["video_bit_rate"])
+	log.Printf("[scrcpy] restarting scrcpy-server with max_size=%s max_fps=%s video_bit_rate=%s",
+		options["max_size"], options["max_fps"], options["video_bit_rate"])
+	// 新的编码器从关键帧开始，不需要发送缓存的旧分辨率关键帧
+	if err := da.restartServer(options); err != nil {
+		return fmt.Errorf("restart scrcpy-server: %w", err)
+	}
+	return nil
+}
+
+// Pause 停止转发视频帧，scrcpy-server 不重启，控制连接、音频和 UHID 设备保持不变
+func (da *ScrcpyDriver) Pause() {
+	da.restartMu.Lock()
+	defer da.restartMu.Unlock()
+	if da.paused {
+		return
+	}
+	da.paused = true
+	da.dropVideo.Store(true)
+	log.Println("[scrcpy] paused, dropping video frames")
+}
+
+// Resume 恢复转发视频帧，先请求新的关键帧，在它到达之前继续丢弃非关键帧
+func (da *ScrcpyDriver) Resume() {
+	da.restartMu.Lock()
+	defer da.restartMu.Unlock()
+	if !da.paused {
+		return
+	}
+	da.paused = false
+	// 暂停期间缓存的关键帧已经过时，等待编码器输出新的关键帧
+	da.awaitKeyFrame.Store(true)
+	da.dropVideo.Store(false)
+	log.Println("[scrcpy] resumed, requesting a key frame")
+	da.KeyFrameRequest()
+}
+
+// restartServer 断开当前连接并用 options 重新启动 scrcpy-server，reverse tunnel 保持不变
+// 调用方需要持有 restartMu
+func (da *ScrcpyDriver) restartServer(options map[string]string) error {
+	// 断开旧连接，scrcpy-server (cleanup=true) 随之退出，读取协程也会结束
+	da.closeConns()
+	da.readers.Wait()
+	da.videoConn, da.audioConn = nil, nil
+	da.setControlConn(nil)
+	da.failClipboardAcks()
+
+	da.ptsMu.Lock()
+	da.ptsRebase = true
+	da.ptsMu.Unlock()
+
+	da.launchServer(options, false)
+	if err := da.acceptConns(options); err != nil {
+		// 断开已建立的连接，新的 scrcpy-server 随之退出，由 superviseServer 按次数限制重启
+		// 在这之前没有控制连接，浏览器的输入都会被丢弃
+		da.closeConns()
+		da.setControlConn(nil)
+		log.Printf("[scrcpy] Failed to restart scrcpy-server: %v", err)
+		da.emit(sdriver.TextMsgEvent{Msg: fmt.Sprintf("[scrcpy] Failed to restart scrcpy-server, input is unavailable until it restarts: %v", err)})
+		return err
+	}
+	da.Start()
+
+	// UHID 设备已在新的控制连接上重新创建，ID 不变，提醒浏览器按键状态已重置
+	da.controlMu.Lock()
+	uhidDevices := len(da.uhidDevices)
+	da.controlMu.Unlock()
+	if uhidDevices > 0 {
+		da.emit(sdriver.TextMsgEvent{Msg: fmt.Sprintf("[scrcpy] scrcpy-server restarted, re-created %d UHID input devices", uhidDevices)})
+	}
+	return nil
+}
+
+// adjustPTS 把 scrcpy-server 给出的 PTS 映射到连续的时间轴上
+// 重启之后第一个非配置帧决定新的偏移量，使之接在重启前最后一帧之后，间隔为实际经过的时间
+func (da *ScrcpyDriver) adjustPTS(raw time.Duration, isConfig bool) time.Duration {
+	da.ptsMu.Lock()
+	defer da.ptsMu.Unlock()
+	if da.ptsRebase {
+		if isConfig {
+			return da.lastOutPTS
+		}
+		gap := max(time.Since(da.lastOutAt), time.Millisecond)
+		da.ptsOffset = da.lastOutPTS + gap - raw
+		da.ptsRebase = false
+	}
+	pts := raw + da.ptsOffset
+	if pts > da.lastOutPTS {
+		da.lastOutPTS = pts
+		da.lastOutAt = time.Now()
+	}
+	return pts
+}
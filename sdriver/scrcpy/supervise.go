@@ -0,0 +1,86 @@
+package scrcpy
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"webscreen/sdriver"
+)
+
+const (
+	// scrcpy-server 意外退出后最多连续重启的次数
+	SERVER_MAX_RESTARTS = 3
+	// 运行超过这个时间后再退出，重新计算重启次数
+	SERVER_STABLE_TIME = time.Minute
+)
+
+// launchServer 启动 scrcpy-server 并监视它的退出，调用方需要持有 restartMu
+// first 为 true 时等待 reverse tunnel 生效
+func (da *ScrcpyDriver) launchServer(options map[string]string, first bool) {
+	da.serverGen++
+	da.serverOptions = options
+	da.serverStartedAt = time.Now()
+	var exited <-chan ServerExit
+	if first {
+		exited = da.adbClient.StartScrcpyServer(options)
+	} else {
+		exited = da.adbClient.RestartScrcpyServer(options)
+	}
+	go da.superviseServer(da.serverGen, exited)
+}
+
+// superviseServer 等待 scrcpy-server 退出
+// 重启和 Stop 引起的退出会被忽略，意外退出时通知浏览器并按次数限制自动重启
+func (da *ScrcpyDriver) superviseServer(gen uint64, exited <-chan ServerExit) {
+	var exit ServerExit
+	select {
+	case exit = <-exited:
+	case <-da.ctx.Done():
+		return
+	}
+
+	da.restartMu.Lock()
+	if gen != da.serverGen || da.ctx.Err() != nil {
+		da.restartMu.Unlock()
+		return
+	}
+	if time.Since(da.serverStartedAt) > SERVER_STABLE_TIME {
+		da.serverRestarts = 0
+	}
+	da.serverRestarts++
+	attempt := da.serverRestarts
+	da.restartMu.Unlock()
+
+	msg := "[scrcpy] scrcpy-server exited unexpectedly"
+	if exit.Err != nil {
+		msg += fmt.Sprintf(": %v", exit.Err)
+	}
+	if exit.Output != "" {
+		msg += "\n" + exit.Output
+	}
+	log.Println(msg)
+	da.emit(sdriver.TextMsgEvent{Msg: msg})
+
+	if attempt > SERVER_MAX_RESTARTS {
+		da.emit(sdriver.TextMsgEvent{Msg: fmt.Sprintf("[scrcpy] scrcpy-server crashed %d times in a row, giving up", SERVER_MAX_RESTARTS)})
+		return
+	}
+	// 退避时间随次数增加
+	select {
+	case <-time.After(time.Duration(attempt) * time.Second):
+	case <-da.ctx.Done():
+		return
+	}
+
+	da.restartMu.Lock()
+	defer da.restartMu.Unlock()
+	if gen != da.serverGen || da.ctx.Err() != nil {
+		return
+	}
+	log.Printf("[scrcpy] restarting scrcpy-server (attempt %d/%d)", attempt, SERVER_MAX_RESTARTS)
+	if err := da.restartServer(da.serverOptions); err != nil {
+		// restartServer 已经通知了浏览器，新的 scrcpy-server 退出时会再次进入这里
+		return
+	}
+	da.emit(sdriver.TextMsgEvent{Msg: "[scrcpy] scrcpy-server restarted"})
+}
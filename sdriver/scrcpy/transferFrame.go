@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"io"
 	"log"
+	"net"
 	"time"
 	"webscreen/sdriver"
 )
@@ -22,7 +23,7 @@ func (da *ScrcpyDriver) convertVideoFrame() {
 			log.Println("Failed to read scrcpy frame header:", err)
 			return
 		}
-		da.LastPTS = time.Duration(header.PTS) * time.Microsecond
+		da.LastPTS = da.adjustPTS(time.Duration(header.PTS)*time.Microsecond, header.IsConfig)
 		// showFrameHeaderInfo(frame.Header)
 		frameSize := int(header.Size)
 
@@ -34,6 +35,17 @@ func (da *ScrcpyDriver) convertVideoFrame() {
 			return
 		}
 
+		// 暂停时不转发视频帧，只更新参数集缓存；恢复后从新的关键帧开始转发
+		if da.dropVideo.Load() || (da.awaitKeyFrame.Load() && !header.IsKeyFrame) {
+			if header.IsConfig {
+				da.updateCache(payloadBuf, da.mediaMeta.VideoCodec)
+			}
+			continue
+		}
+		if header.IsKeyFrame {
+			da.awaitKeyFrame.Store(false)
+		}
+
 		// fmt.Printf("ScrcpyDriver: isKeyFrame=%v, nal Type=%v, Size=%d bytes\n", header.IsKeyFrame, nalType, len(payloadBuf))
 
 		if header.IsKeyFrame {
@@ -104,41 +116,67 @@ func (da *ScrcpyDriver) convertAudioFrame() {
 
 		da.AudioChan <- sdriver.AVBox{
 			Data:     payloadBuf,
-			PTS:      time.Duration(header.PTS) * time.Microsecond,
-			IsConfig: false,
+			PTS:      da.adjustPTS(time.Duration(header.PTS)*time.Microsecond, header.IsConfig),
+			IsConfig: header.IsConfig,
 		}
 
 	}
 }
 
-func (da *ScrcpyDriver) transferControlMsg() {
-	header := make([]byte, 5) // Type (1) + Length (4)
+func (da *ScrcpyDriver) transferControlMsg(conn net.Conn) {
+	// 设备消息的长度字段因类型而异，只能先读类型
+	// CLIPBOARD:     [Type 1][Length 4][Text N]
+	// ACK_CLIPBOARD: [Type 1][Sequence 8]
+	// UHID_OUTPUT:   [Type 1][ID 2][Size 2][Data N]
+	msgType := make([]byte, 1)
 	for {
-		_, err := io.ReadFull(da.controlConn, header)
+		_, err := io.ReadFull(conn, msgType)
 		if err != nil {
 			log.Println("Control connection read error:", err)
 			return
 		}
 
-		msgType := header[0]
-		length := binary.BigEndian.Uint32(header[1:])
-
-		switch msgType {
+		switch msgType[0] {
 		case DEVICE_MSG_TYPE_CLIPBOARD:
-			content := make([]byte, length)
-			_, err := io.ReadFull(da.controlConn, content)
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				log.Println("Control connection read header error:", err)
+				return
+			}
+			content := make([]byte, binary.BigEndian.Uint32(header))
+			_, err := io.ReadFull(conn, content)
 			if err != nil {
 				log.Println("Control connection read content error:", err)
 				return
 			}
-			da.ControlChan <- sdriver.ReceiveClipboardEvent{
+			da.emit(sdriver.ReceiveClipboardEvent{
 				Content: content,
+			})
+		case DEVICE_MSG_TYPE_ACK_CLIPBOARD:
+			sequence := make([]byte, 8)
+			if _, err := io.ReadFull(conn, sequence); err != nil {
+				log.Println("Control connection read ack error:", err)
+				return
 			}
-		default:
-			// Skip unknown message
-			if length > 0 {
-				io.CopyN(io.Discard, da.controlConn, int64(length))
+			da.resolveClipboardAck(binary.BigEndian.Uint64(sequence), true)
+		case DEVICE_MSG_TYPE_UHID_OUTPUT:
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				log.Println("Control connection read uhid header error:", err)
+				return
 			}
+			data := make([]byte, binary.BigEndian.Uint16(header[2:4]))
+			if _, err := io.ReadFull(conn, data); err != nil {
+				log.Println("Control connection read uhid data error:", err)
+				return
+			}
+			da.emit(sdriver.UHIDOutputEvent{
+				ID:   binary.BigEndian.Uint16(header[0:2]),
+				Data: data,
+			})
+		default:
+			// 不知道长度，只跳过类型字节，继续按消息类型读取
+			log.Printf("Unknown device message type: %d, skipped", msgType[0])
 		}
 	}
 }
@@ -25,6 +25,13 @@ type DriverCaps struct {
 	CanVideo     bool `json:"can_video"`
 	CanAudio     bool `json:"can_audio"`
 	CanControl   bool `json:"can_control"`
+	// 通知栏、快捷设置面板和返回/亮屏
+	CanSystemPanels bool `json:"can_system_panels"`
+	// 关闭物理屏幕但继续投屏
+	CanDisplayPower bool `json:"can_display_power"`
+
+	// 视频来自 Android 摄像头而不是屏幕，不能控制
+	IsCamera bool `json:"is_camera"`
 
 	IsAndroid bool `json:"is_android"` // If true, show the android-specific buttons, like vol buttons, back, home, recent apps.
 	IsLinux   bool `json:"is_linux"`
@@ -1,6 +1,7 @@
 package linuxXvfbDriver
 
 import (
+	"context"
 	"embed"
 	"encoding/binary"
 	"fmt"
@@ -8,6 +9,9 @@ import (
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 	"webscreen/sdriver"
 	"webscreen/sdriver/comm"
@@ -21,16 +25,29 @@ type LinuxDriver struct {
 	videoChan   chan sdriver.AVBox
 	videoBuffer *comm.LinearBuffer
 	conn        net.Conn
+	// capturer 进程的生命周期，Stop 时取消
+	ctx    context.Context
+	cancel context.CancelFunc
 
+	// 保护下面可以在推流中修改的参数
+	mu          sync.Mutex
 	ip          string
-	user        string
-	password    string
+	target      SSHTarget
 	resolution  string
 	frameRate   string
 	bitRate     string
 	video_codec string
 }
 
+// 发往 capturer 的控制包，键盘 0x00 和鼠标 0x01 见 SendEvent
+const (
+	PacketTypeBitrate = 0x02
+	PacketTypePause   = 0x03
+	PacketTypeResume  = 0x04
+	PacketTypeConfig  = 0x05
+	PacketTypeText    = 0x06
+)
+
 // 简单的 Header 定义，对应发送端的结构
 type Header struct {
 	PTS  uint64
@@ -40,8 +57,7 @@ type Header struct {
 func New(cfg map[string]string) (*LinuxDriver, error) {
 	d := &LinuxDriver{
 		videoChan:   make(chan sdriver.AVBox, 10), // 适当增大缓冲防止阻塞
-		ip:          cfg["ip"],
-		user:        cfg["user"],
+		target:      TargetFromConfig(cfg),
 		resolution:  cfg["resolution"],
 		frameRate:   cfg["frameRate"],
 		bitRate:     cfg["bitRate"],
@@ -49,26 +65,31 @@ func New(cfg map[string]string) (*LinuxDriver, error) {
 
 		videoBuffer: comm.NewLinearBuffer(16 * 1024 * 1024),
 	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
 	data, err := capturerXvfbData.ReadFile("bin/capturer_xvfb")
 	if err != nil {
 		log.Printf("[xvfb] 读取 capturer_xvfb 失败: %v", err)
+		d.cancel()
 		return nil, err
 	}
 	err = os.WriteFile("capturer_xvfb", data, 0755)
 	if err != nil {
 		log.Printf("[xvfb] 写入本地文件失败: %v", err)
 		os.Remove("capturer_xvfb")
+		d.cancel()
 		return nil, err
 	}
-	if d.ip == "127.0.0.1" || d.ip == "localhost" || d.ip == "" {
+	if d.target.IsLocal() {
 		d.ip = "127.0.0.1"
-		err = LocalStartXvfb("27184", d.resolution, d.bitRate, d.frameRate, d.video_codec)
+		err = LocalStartXvfb(d.ctx, "27184", d.resolution, d.bitRate, d.frameRate, d.video_codec)
 	} else {
-		err = PushAndStartXvfb(d.user, d.ip, "27184", d.resolution, d.bitRate, d.frameRate, d.video_codec)
+		d.ip = d.target.Host
+		err = PushAndStartXvfb(d.ctx, d.target, "27184", d.resolution, d.bitRate, d.frameRate, d.video_codec)
 	}
 	if err != nil {
 		log.Printf("[xvfb] 启动远程 capturer_xvfb 失败: %v", err)
 		os.Remove("capturer_xvfb")
+		d.cancel()
 		return nil, err
 	}
 
@@ -82,6 +103,7 @@ func New(cfg map[string]string) (*LinuxDriver, error) {
 		time.Sleep(time.Second)
 		if time.Since(startTime) > 5*time.Second {
 			os.Remove("capturer_xvfb")
+			d.cancel()
 			return nil, fmt.Errorf("Failed to connect to capturer after 5 seconds: %v", err)
 		}
 	}
@@ -95,10 +117,66 @@ func (d *LinuxDriver) Start() {
 	log.Println("LinuxDriver started, listening for connections...")
 }
 
+// UpdateDriverConfig 修改输出分辨率 (resolution)、帧率 (frameRate)、码率 (bitRate)，capturer 会重启 ffmpeg
+// 包格式: [0x05][Width 2][Height 2][FPS 2][Bitrate 4]
 func (d *LinuxDriver) UpdateDriverConfig(config map[string]string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	resolution, frameRate, bitRate := d.resolution, d.frameRate, d.bitRate
+	for key, value := range config {
+		switch key {
+		case "resolution":
+			resolution = value
+		case "frameRate":
+			frameRate = value
+		case "bitRate":
+			bitRate = value
+		default:
+			return fmt.Errorf("%w: %s", sdriver.ErrNotSupported, key)
+		}
+	}
+	width, height, ok := parseResolution(resolution)
+	if !ok {
+		return fmt.Errorf("invalid resolution: %q", resolution)
+	}
+	fps, err := strconv.Atoi(frameRate)
+	if err != nil || fps <= 0 || fps > 240 {
+		return fmt.Errorf("invalid frame rate: %q", frameRate)
+	}
+	bps, ok := comm.ParseBitrate(bitRate)
+	if !ok {
+		return fmt.Errorf("invalid bit rate: %q", bitRate)
+	}
+	if d.conn == nil {
+		return fmt.Errorf("capturer is not connected")
+	}
+	buf := make([]byte, 11)
+	buf[0] = PacketTypeConfig
+	binary.BigEndian.PutUint16(buf[1:3], uint16(width))
+	binary.BigEndian.PutUint16(buf[3:5], uint16(height))
+	binary.BigEndian.PutUint16(buf[5:7], uint16(fps))
+	binary.BigEndian.PutUint32(buf[7:11], uint32(bps))
+	if _, err := d.conn.Write(buf); err != nil {
+		return err
+	}
+	d.resolution, d.frameRate, d.bitRate = resolution, frameRate, bitRate
 	return nil
 }
 
+// parseResolution 解析 "1920x1080"，宽高需要是偶数
+func parseResolution(resolution string) (int, int, bool) {
+	w, h, found := strings.Cut(resolution, "x")
+	if !found {
+		return 0, 0, false
+	}
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 || width > 7680 || height > 4320 || width%2 != 0 || height%2 != 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
 // Start, GetReceivers 等方法保持不变...
 // 仅重写 handleConnection
 
@@ -184,7 +262,42 @@ func (d *LinuxDriver) GetReceivers() (<-chan sdriver.AVBox, <-chan sdriver.AVBox
 	return d.videoChan, nil, nil
 }
 
-func (d *LinuxDriver) Pause() {}
+// Pause 让 capturer 停止 ffmpeg，X 会话和输入控制保持不变
+func (d *LinuxDriver) Pause() {
+	d.sendCommand(PacketTypePause)
+}
+
+// Resume 让 capturer 重新启动 ffmpeg，新的编码器从关键帧开始
+func (d *LinuxDriver) Resume() {
+	d.sendCommand(PacketTypeResume)
+}
+
+func (d *LinuxDriver) sendCommand(packetType byte) {
+	if d.conn == nil {
+		return
+	}
+	if _, err := d.conn.Write([]byte{packetType}); err != nil {
+		log.Printf("[xvfb] Failed to send command 0x%X: %v", packetType, err)
+	}
+}
+
+// SetVideoBitrate 通知 capturer 用新的码率重启 ffmpeg 编码器
+// 包格式: [0x02][Bitrate 4] (bps, BigEndian)
+func (d *LinuxDriver) SetVideoBitrate(bitrate int) error {
+	if d.conn == nil {
+		return fmt.Errorf("capturer is not connected")
+	}
+	buf := make([]byte, 5)
+	buf[0] = PacketTypeBitrate
+	binary.BigEndian.PutUint32(buf[1:5], uint32(bitrate))
+	if _, err := d.conn.Write(buf); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.bitRate = strconv.Itoa(bitrate)
+	d.mu.Unlock()
+	return nil
+}
 
 func (d *LinuxDriver) RequestIDR(firstFrame bool) {
 }
@@ -202,9 +315,15 @@ func (d *LinuxDriver) Capabilities() sdriver.DriverCaps {
 
 // CodecInfo() (videoCodec string, audioCodec string)
 func (d *LinuxDriver) MediaMeta() sdriver.MediaMeta {
+	d.mu.Lock()
+	width, height, ok := parseResolution(d.resolution)
+	d.mu.Unlock()
+	if !ok {
+		width, height = 1920, 1080
+	}
 	return sdriver.MediaMeta{
-		Width:      1920,
-		Height:     1080,
+		Width:      uint32(width),
+		Height:     uint32(height),
 		VideoCodec: "h264",
 		AudioCodec: "",
 	}
@@ -213,5 +332,7 @@ func (d *LinuxDriver) Stop() {
 	if d.conn != nil {
 		d.conn.Close()
 	}
+	// 结束本机或远程的 capturer
+	d.cancel()
 	os.Remove("capturer_xvfb")
 }
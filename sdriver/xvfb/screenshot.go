@@ -0,0 +1,77 @@
+package linuxXvfbDriver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+)
+
+// 推流时 capturer 使用的虚拟显示器
+const DEFAULT_DISPLAY = ":99"
+
+// 与 capturer 的 SCREENSHOT_EXIT_NO_DISPLAY 一致
+const screenshotExitNoDisplay = 3
+
+// ErrDisplayNotRunning 表示截图的显示器上没有 X server，通常是设备没有在推流
+var ErrDisplayNotRunning = errors.New("display is not running, start a stream first or choose another display")
+
+var displayPattern = regexp.MustCompile(`^:[0-9]+(\.[0-9]+)?$`)
+
+// Screenshot 返回 display 的 PNG 截图，不影响推流，display 为空时使用推流的虚拟显示器
+// 截图由 capturer_xvfb -screenshot 完成，远程主机通过 ssh 执行
+func Screenshot(target SSHTarget, display string) ([]byte, error) {
+	if display == "" {
+		display = DEFAULT_DISPLAY
+	}
+	// display 会拼进远程命令，只允许 :N 或 :N.M
+	if !displayPattern.MatchString(display) {
+		return nil, fmt.Errorf("invalid display: %q", display)
+	}
+	data, err := capturerXvfbData.ReadFile("bin/capturer_xvfb")
+	if err != nil {
+		return nil, err
+	}
+	// 推流中的 capturer_xvfb 正在运行，不能覆盖它，写到临时文件
+	f, err := os.CreateTemp("", "capturer_xvfb_*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	if target.IsLocal() {
+		cmd = exec.Command(f.Name(), "-screenshot", "-display", display)
+	} else {
+		remote := "/tmp/capturer_xvfb_screenshot"
+		push := target.SCP(context.Background(), f.Name(), remote)
+		if output, err := push.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("scp failed: %v, output: %s", err, output)
+		}
+		cmd = target.SSH(context.Background(), "chmod +x "+remote+" && "+remote+" -screenshot -display "+display)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == screenshotExitNoDisplay {
+			return nil, fmt.Errorf("%w: %s", ErrDisplayNotRunning, display)
+		}
+		return nil, fmt.Errorf("screenshot failed: %v, output: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
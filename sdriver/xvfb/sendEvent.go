@@ -75,6 +75,16 @@ func (d *LinuxDriver) SendEvent(event sdriver.Event) error {
 		buf.WriteByte(v.Action)                                             // [0] Action
 		binary.Write(buf, binary.BigEndian, AndroidKeyCodeToX11(v.KeyCode)) // [1-4] KeyCode
 
+	// =================================================================
+	// Case 4: 文本 -> 文本包，capturer 通过 XTest 逐字符输入
+	// 结构: [Len 4][UTF-8 N]
+	// =================================================================
+	case *sdriver.TextInjectEvent:
+		buf.WriteByte(PacketTypeText) // Header: 0x06
+
+		binary.Write(buf, binary.BigEndian, uint32(len(v.Text)))
+		buf.WriteString(v.Text)
+
 	// 其他事件直接忽略
 	default:
 
@@ -1,30 +1,162 @@
 package linuxXvfbDriver
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// scp and execute Xvfb capturer binary
-func PushAndStartXvfb(user, ip, tcpPort, resolution, bitrate, frameRate, codec string) error {
+// 远程主机的认证方式
+const (
+	SSH_AUTH_AGENT    = "agent"    // ssh-agent 或 ~/.ssh/config 中的默认密钥
+	SSH_AUTH_KEY      = "key"      // 指定私钥文件
+	SSH_AUTH_PASSWORD = "password" // 密码，需要安装 sshpass
+)
+
+// ssh 连接超时，避免主机不可达时长时间阻塞
+const SSH_CONNECT_TIMEOUT = 5 * time.Second
 
-	execCmd := exec.Command("bash", "-c",
-		"scp ./capturer_xvfb "+user+"@"+ip+":/tmp/capturer_xvfb && "+
-			"ssh "+user+"@"+ip+" 'chmod +x /tmp/capturer_xvfb && "+
-			"/tmp/capturer_xvfb -resolution "+resolution+" -tcp_port "+tcpPort+
-			" -bitrate "+bitrate+" -framerate "+frameRate+" -codec "+codec+"'")
+// SSHTarget 是远程主机的 ssh 连接参数，Host 为空表示本机
+type SSHTarget struct {
+	User     string
+	Host     string
+	Port     int // 0 表示 22
+	Auth     string
+	KeyFile  string
+	Password string
+}
+
+// TargetFromConfig 从驱动参数 ip、user、ssh_port、ssh_auth、ssh_key、ssh_password 读取连接参数
+func TargetFromConfig(cfg map[string]string) SSHTarget {
+	port, _ := strconv.Atoi(cfg["ssh_port"])
+	return SSHTarget{
+		User:     cfg["user"],
+		Host:     cfg["ip"],
+		Port:     port,
+		Auth:     cfg["ssh_auth"],
+		KeyFile:  cfg["ssh_key"],
+		Password: cfg["ssh_password"],
+	}
+}
 
+func (t SSHTarget) IsLocal() bool {
+	return t.Host == "" || t.Host == "127.0.0.1" || t.Host == "localhost"
+}
+
+func (t SSHTarget) address() string {
+	if t.User == "" {
+		return t.Host
+	}
+	return t.User + "@" + t.Host
+}
+
+// options 是 ssh 和 scp 共用的参数，scp 的端口参数是 -P
+func (t SSHTarget) options(portFlag string) []string {
+	opts := []string{
+		"-o", "ConnectTimeout=" + strconv.Itoa(int(SSH_CONNECT_TIMEOUT.Seconds())),
+		// 第一次连接时记住主机密钥，之后密钥变化会拒绝连接
+		"-o", "StrictHostKeyChecking=accept-new",
+	}
+	if t.Port > 0 {
+		opts = append(opts, portFlag, strconv.Itoa(t.Port))
+	}
+	switch t.Auth {
+	case SSH_AUTH_KEY:
+		opts = append(opts, "-i", t.KeyFile, "-o", "IdentitiesOnly=yes", "-o", "BatchMode=yes")
+	case SSH_AUTH_PASSWORD:
+		opts = append(opts, "-o", "PreferredAuthentications=password,keyboard-interactive", "-o", "PubkeyAuthentication=no")
+	default:
+		// 不能在服务端弹出密码提示
+		opts = append(opts, "-o", "BatchMode=yes")
+	}
+	return opts
+}
+
+// command 创建 ssh 或 scp 命令，密码认证通过 sshpass 的环境变量传入，不出现在命令行中
+func (t SSHTarget) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if t.Auth != SSH_AUTH_PASSWORD {
+		return exec.CommandContext(ctx, name, args...)
+	}
+	cmd := exec.CommandContext(ctx, "sshpass", append([]string{"-e", name}, args...)...)
+	cmd.Env = append(os.Environ(), "SSHPASS="+t.Password)
+	return cmd
+}
+
+// SSH 在远程主机上执行 remoteCmd
+func (t SSHTarget) SSH(ctx context.Context, remoteCmd string) *exec.Cmd {
+	args := append(t.options("-p"), t.address(), remoteCmd)
+	return t.command(ctx, "ssh", args...)
+}
+
+// SCP 把本地文件复制到远程主机的 remotePath
+func (t SSHTarget) SCP(ctx context.Context, localPath, remotePath string) *exec.Cmd {
+	args := append(t.options("-P"), localPath, t.address()+":"+remotePath)
+	return t.command(ctx, "scp", args...)
+}
+
+// Check 登录远程主机执行 true，用于确认地址、用户和认证方式可用
+func (t SSHTarget) Check(ctx context.Context) error {
+	if t.Auth == SSH_AUTH_PASSWORD {
+		if _, err := exec.LookPath("sshpass"); err != nil {
+			return fmt.Errorf("password authentication needs sshpass: %v", err)
+		}
+	}
+	output, err := t.SSH(ctx, "true").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ssh %s failed: %v, output: %s", t.address(), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// scp and execute Xvfb capturer binary
+// capturer 在前台运行直到推流结束，ssh 进程在后台等待它退出
+// ctx 取消时结束 ssh，远程 shell 读到 stdin 关闭后结束 capturer
+func PushAndStartXvfb(ctx context.Context, target SSHTarget, tcpPort, resolution, bitrate, frameRate, codec string) error {
+	if output, err := target.SCP(ctx, "./capturer_xvfb", "/tmp/capturer_xvfb").CombinedOutput(); err != nil {
+		return fmt.Errorf("scp failed: %v, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	// 没有 tty 时 ssh 断开不会给远程进程发 SIGHUP，由 read 等待连接断开
+	execCmd := target.SSH(ctx, "chmod +x /tmp/capturer_xvfb && "+
+		"{ /tmp/capturer_xvfb -resolution "+resolution+" -tcp_port "+tcpPort+
+		" -bitrate "+bitrate+" -framerate "+frameRate+" -codec "+codec+" </dev/null & } && "+
+		"pid=$! && read _; kill $pid 2>/dev/null; wait $pid")
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
-	return execCmd.Run()
+	// stdin 保持打开，进程结束时由 Wait 关闭
+	if _, err := execCmd.StdinPipe(); err != nil {
+		return err
+	}
+	if err := execCmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		if err := execCmd.Wait(); err != nil && ctx.Err() == nil {
+			log.Printf("[xvfb] remote capturer on %s exited: %v", target.address(), err)
+		}
+	}()
+	return nil
 }
 
-func LocalStartXvfb(tcpPort, resolution, bitrate, frameRate, codec string) error {
-	execCmd := exec.Command("bash", "-c",
+// LocalStartXvfb 在本机启动 capturer，ctx 取消时结束它
+func LocalStartXvfb(ctx context.Context, tcpPort, resolution, bitrate, frameRate, codec string) error {
+	execCmd := exec.CommandContext(ctx, "bash", "-c",
 		"chmod +x ./capturer_xvfb && "+
-			"./capturer_xvfb -resolution "+resolution+" -tcp_port "+tcpPort+
+			"exec ./capturer_xvfb -resolution "+resolution+" -tcp_port "+tcpPort+
 			" -bitrate "+bitrate+" -framerate "+frameRate+" -codec "+codec)
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
-	return execCmd.Start()
+	if err := execCmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		if err := execCmd.Wait(); err != nil && ctx.Err() == nil {
+			log.Printf("[xvfb] local capturer exited: %v", err)
+		}
+	}()
+	return nil
 }
@@ -4,8 +4,12 @@ import (
 	"crypto/rand"
 	"fmt"
 	"log"
+	"maps"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"webscreen/recorder"
 	"webscreen/sdriver"
 	"webscreen/sdriver/dummy"
 	"webscreen/sdriver/scrcpy"
@@ -23,15 +27,31 @@ type Agent struct {
 	driver     sdriver.SDriver
 	driverCaps sdriver.DriverCaps
 	config     AgentConfig
-	// 用来接收前端的 RTCP 请求
-	rtpSenderVideo *webrtc.RTPSender
-	rtpSenderAudio *webrtc.RTPSender
+	// 每个浏览器对应一个 Viewer，共享同一组 Track
+	viewers map[string]*Viewer
 	// chan
 	videoCh   <-chan sdriver.AVBox
 	audioCh   <-chan sdriver.AVBox
 	controlCh chan sdriver.Event
 
 	negotiatedCodec chan webrtc.RTPCodecParameters
+	// 只有第一个建立连接的 Viewer 决定驱动使用的编码参数
+	negotiatedOnce sync.Once
+	streamingOnce  sync.Once
+	streaming      bool
+
+	// 所有 Viewer 都暂停时驱动被暂停，见 pause.go
+	pauseMu sync.Mutex
+	paused  bool
+
+	closed bool
+	// Agent 关闭时关闭，用于结束后台协程
+	done chan struct{}
+	// 自上次码率检查以来发出的视频字节数
+	videoBytes atomic.Int64
+
+	// 服务端录制，为 nil 时表示未在录制
+	recorder *recorder.Recorder
 
 	// 用于音视频推流的 PTS 记录
 	lastVideoPTS time.Duration
@@ -46,9 +66,12 @@ type Agent struct {
 // 创建视频轨和音频轨，并初始化 Agent. 可以选择是否开启音视频同步.
 func NewAgent(config AgentConfig) (*Agent, error) {
 	sa := &Agent{
-		config: config,
+		config:          config,
+		viewers:         make(map[string]*Viewer),
+		negotiatedCodec: make(chan webrtc.RTPCodecParameters, 1),
+		done:            make(chan struct{}),
 	}
-	log.Printf("Driver config: %+v", config.DriverConfig)
+	log.Printf("Driver config: %+v", redactDriverConfig(config.DriverConfig))
 	var videoMimeType, audioMimeType string
 	switch config.DriverConfig["video_codec"] {
 	case "h264":
@@ -125,6 +148,22 @@ func (sa *Agent) InitDriver() error {
 	}
 	// finalPayloadType := <-sa.videoPayloadType
 	// sa.config.DriverConfig["video_payload_type"] = fmt.Sprintf("%d", finalPayloadType)
+	return sa.initDriver()
+}
+
+// InitDriverHeadless 在没有浏览器参与协商的情况下初始化驱动 (例如仅做服务端录制)
+// 驱动使用配置中的编码参数，后续加入的 Viewer 仍然可以正常观看
+func (sa *Agent) InitDriverHeadless() error {
+	// 占用协商结果，之后加入的 Viewer 不会再向 negotiatedCodec 写入
+	sa.negotiatedOnce.Do(func() {})
+	return sa.initDriver()
+}
+
+func (sa *Agent) initDriver() error {
+	if sa.config.DriverConfig == nil {
+		sa.config.DriverConfig = make(map[string]string)
+	}
+	var driver sdriver.SDriver
 	switch sa.config.DeviceType {
 	case DEVICE_TYPE_DUMMY:
 		// 初始化 Dummy Driver
@@ -133,7 +172,7 @@ func (sa *Agent) InitDriver() error {
 			log.Printf("Failed to initialize dummy driver: %v", err)
 			return err
 		}
-		sa.driver = dummyDriver
+		driver = dummyDriver
 	case DEVICE_TYPE_ANDROID:
 		// 初始化 Android Driver
 		androidDriver, err := scrcpy.New(sa.config.DriverConfig, sa.config.DeviceID)
@@ -141,7 +180,7 @@ func (sa *Agent) InitDriver() error {
 			log.Printf("Failed to initialize Android driver: %v", err)
 			return err
 		}
-		sa.driver = androidDriver
+		driver = androidDriver
 	case DEVICE_TYPE_XVFB:
 		// 初始化 Linux Driver
 		linuxDriver, err := linuxXvfbDriver.New(sa.config.DriverConfig)
@@ -149,25 +188,35 @@ func (sa *Agent) InitDriver() error {
 			log.Printf("Failed to initialize Linux driver: %v", err)
 			return err
 		}
-		sa.driver = linuxDriver
+		driver = linuxDriver
 	default:
 		log.Printf("Unsupported device type: %s", sa.config.DeviceType)
 		return fmt.Errorf("unsupported device type: %s", sa.config.DeviceType)
 	}
+	sa.Lock()
+	if sa.closed {
+		// 初始化期间所有 Viewer 都已离开，Agent 已被关闭
+		sa.Unlock()
+		driver.Stop()
+		return fmt.Errorf("agent closed during driver initialization")
+	}
+	sa.driver = driver
 	sa.driverCaps = sa.driver.Capabilities()
 	// sa.videoCh, sa.audioCh, sa.controlCh = sa.driver.GetReceivers()
 	sa.videoCh, sa.audioCh, sa.controlCh = sa.driver.GetReceivers()
+	sa.Unlock()
 
 	return nil
 }
 
-func (sa *Agent) HandleRTCP() {
+// HandleRTCP 读取某个 Viewer 的视频 RTCP 反馈，收到 PLI 时向驱动请求关键帧，REMB 记录给码率控制使用
+func (sa *Agent) HandleRTCP(v *Viewer) {
 	rtcpBuf := make([]byte, 1500)
 	lastRTCPTime := time.Now()
 	for {
-		n, _, err := sa.rtpSenderVideo.Read(rtcpBuf)
+		n, _, err := v.rtpSenderVideo.Read(rtcpBuf)
 		if err != nil {
-			log.Printf("Error reading RTCP: %v", err)
+			log.Printf("Error reading RTCP of viewer %s: %v", v.ID, err)
 			return
 		}
 		packets, err := rtcp.Unmarshal(rtcpBuf[:n])
@@ -175,77 +224,188 @@ func (sa *Agent) HandleRTCP() {
 			continue
 		}
 		for _, p := range packets {
-			switch p.(type) {
+			switch pkt := p.(type) {
+			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				v.setREMB(pkt.Bitrate)
 			case *rtcp.PictureLossIndication:
 				now := time.Now()
 				if now.Sub(lastRTCPTime) < time.Second*2 {
 					continue
 				}
+				driver := sa.getDriver()
+				if driver == nil {
+					// 驱动尚未初始化，启动时会主动发送关键帧
+					continue
+				}
 				lastRTCPTime = now
-				log.Println("IDR requested via RTCP PLI")
-				sa.driver.RequestIDR(false)
+				log.Printf("IDR requested via RTCP PLI from viewer %s", v.ID)
+				driver.RequestIDR(false)
 			}
 		}
 	}
 }
 
-func (sa *Agent) CreateWebRTCConnection(offer string) string {
-	var finalSDP string
-	sa.negotiatedCodec = make(chan webrtc.RTPCodecParameters, 1)
-	finalSDP = sa.handleSDP(offer)
+// CreateWebRTCConnection 为一个新的 Viewer 创建 PeerConnection 并返回 Answer SDP
+// role 为空时按只读 Viewer 处理，onCandidate 不为 nil 时启用 Trickle ICE
+func (sa *Agent) CreateWebRTCConnection(viewerID string, role string, offer string, onCandidate func(*webrtc.ICECandidateInit)) string {
+	if role != VIEWER_ROLE_CONTROLLER {
+		role = VIEWER_ROLE_VIEWER
+	}
+	v := &Viewer{ID: viewerID, role: role}
+	finalSDP := sa.handleSDP(v, offer, onCandidate)
+	if finalSDP == "" {
+		v.Close()
+		return ""
+	}
+	sa.addViewer(v)
+	log.Printf("[agent] viewer %s joined as %s", viewerID, role)
+	sa.updatePause()
 	return finalSDP
 }
 
+func (sa *Agent) getDriver() sdriver.SDriver {
+	sa.RLock()
+	defer sa.RUnlock()
+	return sa.driver
+}
+
 func (sa *Agent) Close() {
 	log.Printf("Closing agent for device %s", sa.config.DeviceID)
-	if sa.driver != nil {
-		sa.driver.Stop()
+	sa.Lock()
+	if !sa.closed {
+		close(sa.done)
+	}
+	sa.closed = true
+	viewers := sa.viewers
+	sa.viewers = make(map[string]*Viewer)
+	sa.Unlock()
+	for _, v := range viewers {
+		v.Close()
+	}
+	if _, err := sa.StopRecording(); err != nil && err != ErrNotRecording {
+		log.Printf("Failed to stop recording: %v", err)
+	}
+	if driver := sa.getDriver(); driver != nil {
+		driver.Stop()
 	}
 }
 
 func (sa *Agent) GetCodecInfo() (string, string) {
-	m := sa.driver.MediaMeta()
+	m := sa.GetMediaMeta()
 	return m.VideoCodec, m.AudioCodec
 }
 
-func (sa *Agent) GetMediaMeta() sdriver.MediaMeta {
-	return sa.driver.MediaMeta()
+// Device 返回 Agent 对应的设备类型和设备 ID
+func (sa *Agent) Device() (string, string) {
+	return sa.config.DeviceType, sa.config.DeviceID
 }
 
-func (sa *Agent) Capabilities() sdriver.DriverCaps {
-	return sa.driver.Capabilities()
-}
-
-func (sa *Agent) StartStreaming() {
-	sa.driver.Start()
-	sa.baseTime = time.Now()
-	go sa.StreamingVideo()
-	go sa.StreamingAudio()
-	if sa.rtpSenderVideo != nil {
-		log.Printf("RTCP handler started")
-		go sa.HandleRTCP()
+// GetMediaMeta 返回驱动的媒体信息，驱动尚未初始化时返回零值
+func (sa *Agent) GetMediaMeta() sdriver.MediaMeta {
+	driver := sa.getDriver()
+	if driver == nil {
+		return sdriver.MediaMeta{}
 	}
-	sa.driver.RequestIDR(true)
+	return driver.MediaMeta()
 }
 
-func (sa *Agent) PauseStreaming() {
+// Capabilities 返回驱动的能力，驱动尚未初始化时返回零值
+func (sa *Agent) Capabilities() sdriver.DriverCaps {
+	driver := sa.getDriver()
+	if driver == nil {
+		return sdriver.DriverCaps{}
+	}
+	return driver.Capabilities()
 }
 
-func (sa *Agent) ResumeStreaming() {
-
+// StartStreaming 启动驱动并开始推流，多个 Viewer 共享同一路流，因此只会执行一次
+func (sa *Agent) StartStreaming() {
+	sa.streamingOnce.Do(func() {
+		driver := sa.getDriver()
+		driver.Start()
+		sa.baseTime = time.Now()
+		go sa.StreamingVideo()
+		go sa.StreamingAudio()
+		driver.RequestIDR(true)
+		if sa.adaptiveBitrateEnabled() {
+			go sa.adaptBitrate()
+		}
+		sa.Lock()
+		sa.streaming = true
+		sa.Unlock()
+	})
+	// 推流开始前 Viewer 可能已经切到后台
+	sa.updatePause()
 }
 
-func (sa *Agent) SendEvent(raw []byte) error {
-	if !sa.driverCaps.CanControl {
+// SendEvent 将某个 Viewer 发来的控制事件转发给驱动，只读 Viewer 的事件会被拒绝
+func (sa *Agent) SendEvent(viewerID string, raw []byte) error {
+	sa.RLock()
+	driver, caps := sa.driver, sa.driverCaps
+	sa.RUnlock()
+	if driver == nil {
+		return fmt.Errorf("driver is not initialized")
+	}
+	if !caps.CanControl {
 		return fmt.Errorf("driver does not support control events")
 	}
+	v, ok := sa.getViewer(viewerID)
+	if !ok {
+		return fmt.Errorf("unknown viewer: %s", viewerID)
+	}
+	if !v.CanControl() {
+		return fmt.Errorf("viewer %s is view-only", viewerID)
+	}
 	event, err := sa.parseEvent(raw)
 	if err != nil {
 		log.Printf("[agent] Failed to parse control event: %v", err)
 		return err
 	}
 	// log.Printf("Parsed control event: %+v", event)
-	return sa.driver.SendEvent(event)
+	return driver.SendEvent(event)
+}
+
+// UpdateDriverConfig 在不断开连接的情况下修改驱动参数，只有控制者可以修改
+func (sa *Agent) UpdateDriverConfig(viewerID string, updates map[string]string) error {
+	v, ok := sa.getViewer(viewerID)
+	if !ok {
+		return fmt.Errorf("unknown viewer: %s", viewerID)
+	}
+	if !v.CanControl() {
+		return fmt.Errorf("viewer %s is view-only", viewerID)
+	}
+	driver := sa.getDriver()
+	if driver == nil {
+		return fmt.Errorf("driver is not initialized")
+	}
+	log.Printf("[agent] viewer %s updates driver config: %v", viewerID, updates)
+	if err := driver.UpdateDriverConfig(updates); err != nil {
+		return err
+	}
+	// 复制后替换，其他协程持有的旧 map 不会被修改
+	sa.Lock()
+	config := maps.Clone(sa.config.DriverConfig)
+	maps.Copy(config, updates)
+	sa.config.DriverConfig = config
+	sa.Unlock()
+	return nil
+}
+
+func (sa *Agent) driverConfig() map[string]string {
+	sa.RLock()
+	defer sa.RUnlock()
+	return sa.config.DriverConfig
+}
+
+// redactDriverConfig 隐藏密码类参数，用于打印日志
+func redactDriverConfig(config map[string]string) map[string]string {
+	redacted := maps.Clone(config)
+	for k, v := range redacted {
+		if strings.Contains(k, "password") && v != "" {
+			redacted[k] = "***"
+		}
+	}
+	return redacted
 }
 
 func generateStreamID() string {
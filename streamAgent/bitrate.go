@@ -0,0 +1,183 @@
+package sagent
+
+import (
+	"errors"
+	"log"
+	"time"
+	"webscreen/sdriver"
+	"webscreen/sdriver/comm"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/webrtc/v4"
+)
+
+// 自适应码率参数
+const (
+	ABR_MIN_BITRATE         = 500_000
+	ABR_DEFAULT_MAX_BITRATE = 8_000_000
+	ABR_CHECK_INTERVAL      = time.Second
+	// 带宽不足持续这么久才降码率，避免偶发抖动
+	ABR_DOWN_HOLD = 3 * time.Second
+	// 网络没有拥塞持续这么久才升码率
+	ABR_UP_HOLD = 15 * time.Second
+	// 两次调整的最小间隔，scrcpy 调整码率需要重启编码器，不能太频繁
+	ABR_MIN_CHANGE_INTERVAL = 10 * time.Second
+)
+
+// registerBandwidthEstimator 为 Viewer 的 PeerConnection 注册 GCC 带宽估计
+func (sa *Agent) registerBandwidthEstimator(v *Viewer, m *webrtc.MediaEngine, i *interceptor.Registry) error {
+	maxBitrate := sa.maxVideoBitrate()
+	factory, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+		return gcc.NewSendSideBWE(
+			gcc.SendSideBWEInitialBitrate(maxBitrate),
+			gcc.SendSideBWEMinBitrate(ABR_MIN_BITRATE),
+			gcc.SendSideBWEMaxBitrate(maxBitrate),
+			// 只做估计，不对发送做节流，码率由编码器控制
+			gcc.SendSideBWEPacer(gcc.NewNoOpPacer()),
+		)
+	})
+	if err != nil {
+		return err
+	}
+	// NewPeerConnection 时同步回调
+	factory.OnNewPeerConnection(func(_ string, estimator cc.BandwidthEstimator) {
+		v.bweMu.Lock()
+		v.bwe = estimator
+		v.bweMu.Unlock()
+	})
+	i.Add(factory)
+	return webrtc.ConfigureTWCCHeaderExtensionSender(m, i)
+}
+
+func (v *Viewer) setREMB(bitrate float32) {
+	v.bweMu.Lock()
+	v.remb = bitrate
+	v.bweMu.Unlock()
+}
+
+// estimatedBitrate 返回该 Viewer 的可用带宽估计，取 GCC 与 REMB 中较小者
+func (v *Viewer) estimatedBitrate() (int, bool) {
+	v.bweMu.Lock()
+	defer v.bweMu.Unlock()
+	if v.bwe == nil {
+		return 0, false
+	}
+	estimate := v.bwe.GetTargetBitrate()
+	if v.remb > 0 && int(v.remb) < estimate {
+		estimate = int(v.remb)
+	}
+	return estimate, true
+}
+
+// estimatedBitrate 返回所有 Viewer 中最小的带宽估计，共享同一路编码，只能照顾最差的连接
+func (sa *Agent) estimatedBitrate() (int, bool) {
+	sa.RLock()
+	defer sa.RUnlock()
+	found := false
+	minEstimate := 0
+	for _, v := range sa.viewers {
+		estimate, ok := v.estimatedBitrate()
+		if !ok {
+			continue
+		}
+		if !found || estimate < minEstimate {
+			minEstimate = estimate
+		}
+		found = true
+	}
+	return minEstimate, found
+}
+
+// maxVideoBitrate 是用户配置的码率，自适应码率不会超过它
+func (sa *Agent) maxVideoBitrate() int {
+	config := sa.driverConfig()
+	for _, key := range []string{"video_bit_rate", "bitRate"} {
+		if bitrate, ok := comm.ParseBitrate(config[key]); ok {
+			return max(bitrate, ABR_MIN_BITRATE)
+		}
+	}
+	return ABR_DEFAULT_MAX_BITRATE
+}
+
+// adaptiveBitrateEnabled 默认开启，driver_config 中 adaptive_bitrate=false 时关闭
+func (sa *Agent) adaptiveBitrateEnabled() bool {
+	return sa.driverConfig()["adaptive_bitrate"] != "false"
+}
+
+// adaptBitrate 根据带宽估计调整驱动的编码码率，直到 Agent 关闭
+// GCC 在发送量不足时估计值最多只有实际发送量的 1.5 倍 (例如静止画面)，
+// 因此只有估计值低于实际发送量时才认为发生了拥塞
+func (sa *Agent) adaptBitrate() {
+	maxBitrate := sa.maxVideoBitrate()
+	current := maxBitrate
+	var congestedSince, clearSince, lastChange time.Time
+	ticker := time.NewTicker(ABR_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sa.done:
+			return
+		case <-ticker.C:
+		}
+		// 用户修改了码率，从新的码率重新开始
+		if m := sa.maxVideoBitrate(); m != maxBitrate {
+			maxBitrate, current = m, m
+			congestedSince, clearSince, lastChange = time.Time{}, time.Time{}, time.Now()
+		}
+		sendRate := int(sa.videoBytes.Swap(0) * 8 * int64(time.Second) / int64(ABR_CHECK_INTERVAL))
+		estimate, ok := sa.estimatedBitrate()
+		// 暂停期间没有视频发送，估计值没有参考意义
+		if !ok || sa.IsPaused() {
+			congestedSince, clearSince = time.Time{}, time.Time{}
+			continue
+		}
+		now := time.Now()
+		congested := estimate < current*7/10 && estimate < sendRate
+		if congested {
+			clearSince = time.Time{}
+			if congestedSince.IsZero() {
+				congestedSince = now
+			}
+		} else {
+			congestedSince = time.Time{}
+			if estimate >= sendRate && clearSince.IsZero() {
+				clearSince = now
+			}
+		}
+		if now.Sub(lastChange) < ABR_MIN_CHANGE_INTERVAL {
+			continue
+		}
+
+		target := current
+		switch {
+		case !congestedSince.IsZero() && now.Sub(congestedSince) >= ABR_DOWN_HOLD:
+			target = estimate * 9 / 10
+		case !clearSince.IsZero() && now.Sub(clearSince) >= ABR_UP_HOLD && current < maxBitrate:
+			// 估计值受限于实际发送量，每次最多上调 25%
+			target = max(estimate*9/10, current*5/4)
+		}
+		target = min(max(target, ABR_MIN_BITRATE), maxBitrate)
+		if target == current {
+			continue
+		}
+
+		driver := sa.getDriver()
+		if driver == nil {
+			continue
+		}
+		log.Printf("[agent] adaptive bitrate: %d -> %d bps (estimate %d bps, sending %d bps)", current, target, estimate, sendRate)
+		if err := driver.SetVideoBitrate(target); err != nil {
+			if errors.Is(err, sdriver.ErrNotSupported) {
+				log.Printf("[agent] driver does not support bitrate changes, adaptive bitrate disabled")
+				return
+			}
+			log.Printf("[agent] Failed to set video bitrate: %v", err)
+		} else {
+			current = target
+		}
+		lastChange = now
+		congestedSince, clearSince = time.Time{}, time.Time{}
+	}
+}
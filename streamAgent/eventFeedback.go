@@ -10,12 +10,12 @@ func (sa *Agent) EventFeedback(handler func([]byte) bool) {
 		// log.Printf("[Agent] Received event: %+v", event)
 		eType := event.Type()
 		switch eType {
-		case sdriver.EVENT_TYPE_RECEIVE_CLIPBOARD:
+		case sdriver.EVENT_TYPE_RECEIVE_CLIPBOARD, sdriver.EVENT_TYPE_UHID_OUTPUT, sdriver.EVENT_TYPE_CLIPBOARD_ACK:
 			event := event.(sdriver.SampleEvent)
 			content := event.GetContent()
 			msg := make([]byte, 1+len(content))
 			copy(msg[1:], content)
-			msg[0] = byte(sdriver.EVENT_TYPE_RECEIVE_CLIPBOARD)
+			msg[0] = byte(eType)
 			if !handler(msg) {
 				return
 			}
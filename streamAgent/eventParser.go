@@ -3,6 +3,7 @@ package sagent
 import (
 	"encoding/binary"
 	"fmt"
+	"unicode/utf8"
 	"webscreen/sdriver"
 )
 
@@ -26,6 +27,20 @@ func (a *Agent) parseEvent(raw []byte) (sdriver.Event, error) {
 		return a.parseScrollEvent(raw)
 	case sdriver.EVENT_TYPE_ROTATE:
 		return a.parseRotateEvent()
+	case sdriver.EVENT_TYPE_BACK_OR_SCREEN_ON:
+		return a.parseBackOrScreenOnEvent(raw)
+	case sdriver.EVENT_TYPE_EXPAND_NOTIFICATION_PANEL:
+		return &sdriver.ExpandNotificationPanelEvent{}, nil
+	case sdriver.EVENT_TYPE_EXPAND_SETTINGS_PANEL:
+		return &sdriver.ExpandSettingsPanelEvent{}, nil
+	case sdriver.EVENT_TYPE_COLLAPSE_PANELS:
+		return &sdriver.CollapsePanelsEvent{}, nil
+	case sdriver.EVENT_TYPE_DISPLAY_POWER:
+		return a.parseDisplayPowerEvent(raw)
+	case sdriver.EVENT_TYPE_TEXT:
+		return a.parseTextInjectEvent(raw)
+	case sdriver.EVENT_TYPE_START_APP:
+		return a.parseStartAppEvent(raw)
 	case sdriver.EVENT_TYPE_UHID_CREATE:
 		return a.parseUHIDCreateEvent(raw)
 	case sdriver.EVENT_TYPE_UHID_INPUT:
@@ -110,10 +125,59 @@ func (a *Agent) parseRotateEvent() (*sdriver.RotateEvent, error) {
 	return &sdriver.RotateEvent{}, nil
 }
 
+func (a *Agent) parseBackOrScreenOnEvent(raw []byte) (*sdriver.BackOrScreenOnEvent, error) {
+	// WS Packet: [Type 1][Action 1]
+	if len(raw) != 2 {
+		return nil, fmt.Errorf("invalid back or screen on message length: %d", len(raw))
+	}
+	return &sdriver.BackOrScreenOnEvent{Action: raw[1]}, nil
+}
+
+func (a *Agent) parseDisplayPowerEvent(raw []byte) (*sdriver.DisplayPowerEvent, error) {
+	// WS Packet: [Type 1][On 1]
+	if len(raw) != 2 {
+		return nil, fmt.Errorf("invalid display power message length: %d", len(raw))
+	}
+	return &sdriver.DisplayPowerEvent{On: raw[1] != 0}, nil
+}
+
 func (a *Agent) parseIDRReqEvent() (*sdriver.IDRReqEvent, error) {
 	return &sdriver.IDRReqEvent{}, nil
 }
 
+func (a *Agent) parseTextInjectEvent(raw []byte) (*sdriver.TextInjectEvent, error) {
+	// WS Packet: [Type 1][TextLen 4][Text N]
+	if len(raw) < 5 {
+		return nil, fmt.Errorf("invalid text message length: %d", len(raw))
+	}
+	textLen := binary.BigEndian.Uint32(raw[1:5])
+	if len(raw) != 5+int(textLen) {
+		return nil, fmt.Errorf("invalid text message length (text): expected %d, got %d", 5+textLen, len(raw))
+	}
+	if !utf8.Valid(raw[5:]) {
+		return nil, fmt.Errorf("text is not valid UTF-8")
+	}
+	e := &sdriver.TextInjectEvent{
+		Text: string(raw[5:]),
+	}
+	return e, nil
+}
+
+func (a *Agent) parseStartAppEvent(raw []byte) (*sdriver.StartAppEvent, error) {
+	// WS Packet: [Type 1][NameLen 1][Name N]
+	if len(raw) < 2 {
+		return nil, fmt.Errorf("invalid start app message length: %d", len(raw))
+	}
+	nameLen := int(raw[1])
+	if nameLen == 0 || len(raw) != 2+nameLen {
+		return nil, fmt.Errorf("invalid start app message length (name): expected %d, got %d", 2+nameLen, len(raw))
+	}
+	e := &sdriver.StartAppEvent{
+		Name: string(raw[2 : 2+nameLen]),
+	}
+	return e, nil
+}
+
 func (a *Agent) parseUHIDCreateEvent(raw []byte) (*sdriver.UHIDCreateEvent, error) {
 	// 协议: [Type 1][ID 2][Vendor 2][Prod 2][NameLen 1][Name N][DescLen 2][Desc N]
 	const minHeaderSize = 8
@@ -0,0 +1,66 @@
+package sagent
+
+import "log"
+
+// PauseStreaming 标记某个 Viewer 暂时不看画面 (例如浏览器标签页被隐藏)
+// 所有 Viewer 都暂停且没有在录制时，驱动停止编码以节省设备电量
+func (sa *Agent) PauseStreaming(viewerID string) {
+	sa.setViewerPaused(viewerID, true)
+}
+
+// ResumeStreaming 取消某个 Viewer 的暂停，驱动恢复后会立即发送关键帧
+func (sa *Agent) ResumeStreaming(viewerID string) {
+	sa.setViewerPaused(viewerID, false)
+}
+
+func (sa *Agent) setViewerPaused(viewerID string, paused bool) {
+	sa.Lock()
+	v, ok := sa.viewers[viewerID]
+	if ok {
+		v.paused = paused
+	}
+	sa.Unlock()
+	if !ok {
+		return
+	}
+	log.Printf("[agent] viewer %s paused=%v", viewerID, paused)
+	// 暂停恢复驱动可能需要重启编码器，不阻塞调用方；updatePause 总是按最新状态处理
+	go sa.updatePause()
+}
+
+// IsPaused 返回驱动当前是否处于暂停状态
+func (sa *Agent) IsPaused() bool {
+	sa.pauseMu.Lock()
+	defer sa.pauseMu.Unlock()
+	return sa.paused
+}
+
+// updatePause 根据 Viewer 和录制状态暂停或恢复驱动，Viewer 加入离开和录制开始结束时都需要调用
+func (sa *Agent) updatePause() {
+	sa.pauseMu.Lock()
+	defer sa.pauseMu.Unlock()
+
+	sa.RLock()
+	driver, streaming, closed := sa.driver, sa.streaming, sa.closed
+	shouldPause := len(sa.viewers) > 0 && sa.recorder == nil
+	for _, v := range sa.viewers {
+		if !v.paused {
+			shouldPause = false
+			break
+		}
+	}
+	sa.RUnlock()
+
+	// 驱动启动前不能暂停，否则 Start 会重复启动读取协程
+	if driver == nil || !streaming || closed || shouldPause == sa.paused {
+		return
+	}
+	sa.paused = shouldPause
+	if shouldPause {
+		log.Printf("[agent] all viewers paused, pausing driver")
+		driver.Pause()
+	} else {
+		log.Printf("[agent] resuming driver")
+		driver.Resume()
+	}
+}
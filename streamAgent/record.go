@@ -0,0 +1,73 @@
+package sagent
+
+import (
+	"errors"
+	"log"
+	"webscreen/recorder"
+)
+
+var (
+	ErrAlreadyRecording = errors.New("session is already recording")
+	ErrNotRecording     = errors.New("session is not recording")
+)
+
+// StartRecording 开始把驱动输出的原始码流写入 path，不经过 WebRTC，也不重新编码
+func (sa *Agent) StartRecording(path string) error {
+	driver := sa.getDriver()
+	if driver == nil {
+		return errors.New("driver is not initialized")
+	}
+	sa.Lock()
+	if sa.recorder != nil {
+		sa.Unlock()
+		return ErrAlreadyRecording
+	}
+	rec, err := recorder.New(path, driver.MediaMeta())
+	if err != nil {
+		sa.Unlock()
+		return err
+	}
+	sa.recorder = rec
+	sa.Unlock()
+	// 录制期间驱动不能处于暂停状态
+	sa.updatePause()
+	// 录制文件需要从关键帧开始
+	driver.RequestIDR(true)
+	return nil
+}
+
+// StopRecording 结束录制并返回录制结果
+func (sa *Agent) StopRecording() (recorder.Info, error) {
+	sa.Lock()
+	rec := sa.recorder
+	sa.recorder = nil
+	sa.Unlock()
+	if rec == nil {
+		return recorder.Info{}, ErrNotRecording
+	}
+	info, err := rec.Stop()
+	sa.updatePause()
+	if err != nil {
+		log.Printf("[agent] recording %s finished with error: %v", info.File, err)
+	}
+	return info, err
+}
+
+// RecordingInfo 返回当前录制的状态，未在录制时第二个返回值为 false
+func (sa *Agent) RecordingInfo() (recorder.Info, bool) {
+	rec := sa.getRecorder()
+	if rec == nil {
+		return recorder.Info{}, false
+	}
+	return rec.Info(), true
+}
+
+func (sa *Agent) IsRecording() bool {
+	return sa.getRecorder() != nil
+}
+
+func (sa *Agent) getRecorder() *recorder.Recorder {
+	sa.RLock()
+	defer sa.RUnlock()
+	return sa.recorder
+}
@@ -8,7 +8,10 @@ import (
 	"github.com/pion/webrtc/v4"
 )
 
-func (sa *Agent) handleSDP(sdp string) string {
+// handleSDP 处理浏览器的 Offer 并返回 Answer
+// onCandidate 不为 nil 时使用 Trickle ICE: 立即返回 Answer，本地候选通过 onCandidate 逐个送出，收集结束时传入 nil
+// onCandidate 为 nil 时等待 ICE 收集完成，返回包含全部候选的完整 SDP (兼容旧客户端)
+func (sa *Agent) handleSDP(v *Viewer, sdp string, onCandidate func(*webrtc.ICECandidateInit)) string {
 	offer := webrtc.SessionDescription{
 		Type: webrtc.SDPTypeOffer,
 		SDP:  sdp,
@@ -39,19 +42,20 @@ func (sa *Agent) handleSDP(sdp string) string {
 	if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
 		panic(err)
 	}
-	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i))
-	// Configure ICE servers (STUN) for NAT traversal
-	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{URLs: []string{"stun:stun.l.google.com:19302"}},
-		},
+	// 发送端带宽估计 (GCC)：给发出的 RTP 加上 transport-cc 序号，根据浏览器回传的 TWCC 反馈估算可用带宽
+	if err := sa.registerBandwidthEstimator(v, m, i); err != nil {
+		log.Println("Register bandwidth estimator failed:", err)
 	}
+	// ICE 服务器、NAT 映射和端口范围来自服务端配置
+	se := sa.config.WebRTC.settingEngine()
+	api := webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i), webrtc.WithSettingEngine(se))
 	// Create PeerConnection
-	peerConnection, err := api.NewPeerConnection(config)
+	peerConnection, err := api.NewPeerConnection(sa.config.WebRTC.configuration())
 	if err != nil {
 		log.Println("Create PeerConnection failed:", err)
 		return ""
 	}
+	v.pc = peerConnection
 
 	var rtpSenderVideo *webrtc.RTPSender
 	var rtpSenderAudio *webrtc.RTPSender
@@ -70,8 +74,8 @@ func (sa *Agent) handleSDP(sdp string) string {
 			rtpSenderAudio = nil
 		}
 	}
-	sa.rtpSenderVideo = rtpSenderVideo
-	sa.rtpSenderAudio = rtpSenderAudio
+	v.rtpSenderVideo = rtpSenderVideo
+	v.rtpSenderAudio = rtpSenderAudio
 	// Set Remote Description (Offer from browser)
 	if err := peerConnection.SetRemoteDescription(offer); err != nil {
 		log.Println("set Remote Description failed:", err)
@@ -85,7 +89,7 @@ func (sa *Agent) handleSDP(sdp string) string {
 		return ""
 	}
 	peerConnection.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
-		log.Printf("webrtc Connection State: %s", s)
+		log.Printf("webrtc Connection State of viewer %s: %s", v.ID, s)
 		if s == webrtc.PeerConnectionStateFailed || s == webrtc.PeerConnectionStateClosed {
 			// Do some cleanup, like removing references
 			peerConnection.Close()
@@ -101,18 +105,39 @@ func (sa *Agent) handleSDP(sdp string) string {
 				}
 				params := sender.GetParameters()
 				selectedCodec := params.Codecs[0] // 通常只有一个活跃的 codec
-				log.Printf("Negotiation result: %v", selectedCodec)
+				log.Printf("Negotiation result of viewer %s: %v", v.ID, selectedCodec)
 				// 根据 PayloadType 决定 scrcpy 参数
-				sa.negotiatedCodec <- selectedCodec
-				close(sa.negotiatedCodec)
+				// 驱动只初始化一次，后加入的 Viewer 复用第一个 Viewer 的协商结果
+				sa.negotiatedOnce.Do(func() {
+					sa.negotiatedCodec <- selectedCodec
+					close(sa.negotiatedCodec)
+				})
 				break
 			}
 		}
 	})
 
+	if onCandidate != nil {
+		// Trickle ICE: 必须在 SetLocalDescription 之前注册，否则会漏掉最早的候选
+		peerConnection.OnICECandidate(func(c *webrtc.ICECandidate) {
+			if c == nil {
+				onCandidate(nil)
+				return
+			}
+			init := c.ToJSON()
+			onCandidate(&init)
+		})
+		if err := peerConnection.SetLocalDescription(answer); err != nil {
+			log.Println("Set Local Description failed:", err)
+			return ""
+		}
+		sa.startRTCP(v)
+		return peerConnection.LocalDescription().SDP
+	}
+
 	// 设置 Local Description 并等待 ICE 收集完成
 	// 这一步是为了生成一个包含所有网络路径信息的完整 SDP，
-	// 这样我们就不需要写复杂的 Trickle ICE 逻辑了。
+	// 供不支持 Trickle ICE 的旧客户端使用
 	gatherComplete := webrtc.GatheringCompletePromise(peerConnection)
 
 	if err := peerConnection.SetLocalDescription(answer); err != nil {
@@ -122,10 +147,18 @@ func (sa *Agent) handleSDP(sdp string) string {
 
 	// 阻塞等待 ICE 收集完成 (通常几百毫秒)
 	<-gatherComplete
+	sa.startRTCP(v)
 	finalSDP := peerConnection.LocalDescription().SDP
 	return finalSDP
 }
 
+func (sa *Agent) startRTCP(v *Viewer) {
+	if v.rtpSenderVideo != nil {
+		log.Printf("RTCP handler started for viewer %s", v.ID)
+		go sa.HandleRTCP(v)
+	}
+}
+
 func createMediaEngine(mimeTypes []string) *webrtc.MediaEngine {
 	m := &webrtc.MediaEngine{}
 	for _, mime := range mimeTypes {
@@ -18,6 +18,9 @@ func (sa *Agent) StreamingVideo() {
 	const defaultDuration = time.Millisecond * 16
 	var firstPTS time.Duration = -1
 	for vBox := range sa.videoCh {
+		if rec := sa.getRecorder(); rec != nil {
+			rec.WriteVideo(vBox)
+		}
 		if firstPTS == -1 {
 			firstPTS = vBox.PTS
 		}
@@ -56,6 +59,7 @@ func (sa *Agent) StreamingVideo() {
 			Timestamp: timestamp,
 		}
 
+		sa.videoBytes.Add(int64(len(vBox.Data)))
 		if err := sa.VideoTrack.WriteSample(sample); err != nil {
 			// log.Println("WriteSample error:", err)
 			return
@@ -73,6 +77,13 @@ func (sa *Agent) StreamingAudio() {
 	const defaultDuration = 20 * time.Millisecond
 	var currentTimestamp = sa.baseTime
 	for aBox := range sa.audioCh {
+		if rec := sa.getRecorder(); rec != nil {
+			rec.WriteAudio(aBox)
+		}
+		// 配置帧只对录制有意义，不发送给浏览器
+		if aBox.IsConfig {
+			continue
+		}
 		if err := sa.AudioTrack.WriteSample(media.Sample{
 			Data:      aBox.Data,
 			Duration:  defaultDuration,
@@ -6,6 +6,11 @@ const (
 	DEVICE_TYPE_DUMMY   = "dummy"
 )
 
+const (
+	VIEWER_ROLE_CONTROLLER = "controller" // 可以发送控制事件
+	VIEWER_ROLE_VIEWER     = "viewer"     // 只读观看
+)
+
 type AgentConfig struct {
 	DeviceType string `json:"device_type"`
 	DeviceID   string `json:"device_id"`
@@ -14,7 +19,11 @@ type AgentConfig struct {
 	// FilePath   string               `json:"file_path"` // move to StreamConfig.OtherOpts
 	SDP          string            `json:"sdp"`
 	AVSync       bool              `json:"av_sync"`
+	Role         string            `json:"role"`        // 请求的角色，由服务端决定是否批准，见 webservice.ScreenSession.claimRole
+	TrickleICE   bool              `json:"trickle_ice"` // 客户端支持 Trickle ICE，否则等待收集完成后返回完整 SDP
 	DriverConfig map[string]string `json:"driver_config"`
+	// 服务端网络设置，不从客户端读取
+	WebRTC WebRTCConfig `json:"-"`
 }
 
 const (
@@ -0,0 +1,124 @@
+package sagent
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"webscreen/sdriver"
+
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/webrtc/v4"
+)
+
+// Viewer 对应一个浏览器端的 PeerConnection
+// 所有 Viewer 共享 Agent 的 VideoTrack/AudioTrack，由 pion 负责向每个连接分发
+type Viewer struct {
+	ID string
+
+	// 角色由服务端分配，交接控制权时会被修改
+	roleMu sync.RWMutex
+	role   string
+
+	pc             *webrtc.PeerConnection
+	rtpSenderVideo *webrtc.RTPSender
+	rtpSenderAudio *webrtc.RTPSender
+	// 浏览器标签页被隐藏等情况下暂停观看
+	paused bool
+
+	// 带宽估计，见 bitrate.go
+	bweMu sync.Mutex
+	bwe   cc.BandwidthEstimator
+	remb  float32
+}
+
+func (v *Viewer) Role() string {
+	v.roleMu.RLock()
+	defer v.roleMu.RUnlock()
+	return v.role
+}
+
+func (v *Viewer) CanControl() bool {
+	return v.Role() == VIEWER_ROLE_CONTROLLER
+}
+
+func (v *Viewer) Close() {
+	if v.pc != nil {
+		if err := v.pc.Close(); err != nil {
+			log.Printf("[agent] close peer connection of viewer %s failed: %v", v.ID, err)
+		}
+	}
+}
+
+func (sa *Agent) addViewer(v *Viewer) {
+	sa.Lock()
+	defer sa.Unlock()
+	sa.viewers[v.ID] = v
+}
+
+// RemoveViewer 关闭并移除一个 Viewer，返回剩余的 Viewer 数量
+func (sa *Agent) RemoveViewer(viewerID string) int {
+	sa.Lock()
+	v, ok := sa.viewers[viewerID]
+	delete(sa.viewers, viewerID)
+	remaining := len(sa.viewers)
+	sa.Unlock()
+	if ok {
+		log.Printf("[agent] viewer %s (%s) left, %d remaining", viewerID, v.Role(), remaining)
+		v.Close()
+		sa.updatePause()
+	}
+	return remaining
+}
+
+// SetViewerRole 修改 Viewer 的角色，控制权交接由 webservice 决定
+func (sa *Agent) SetViewerRole(viewerID string, role string) error {
+	if role != VIEWER_ROLE_CONTROLLER {
+		role = VIEWER_ROLE_VIEWER
+	}
+	v, ok := sa.getViewer(viewerID)
+	if !ok {
+		return fmt.Errorf("unknown viewer: %s", viewerID)
+	}
+	v.roleMu.Lock()
+	v.role = role
+	v.roleMu.Unlock()
+	log.Printf("[agent] viewer %s is now %s", viewerID, role)
+	return nil
+}
+
+func (sa *Agent) ViewerCount() int {
+	sa.RLock()
+	defer sa.RUnlock()
+	return len(sa.viewers)
+}
+
+func (sa *Agent) getViewer(viewerID string) (*Viewer, bool) {
+	sa.RLock()
+	defer sa.RUnlock()
+	v, ok := sa.viewers[viewerID]
+	return v, ok
+}
+
+// AddICECandidate 添加浏览器通过 Trickle ICE 发来的远端候选
+func (sa *Agent) AddICECandidate(viewerID string, candidate webrtc.ICECandidateInit) error {
+	v, ok := sa.getViewer(viewerID)
+	if !ok || v.pc == nil {
+		return fmt.Errorf("unknown viewer: %s", viewerID)
+	}
+	return v.pc.AddICECandidate(candidate)
+}
+
+// ViewerCapabilities 返回针对某个 Viewer 裁剪后的能力集
+// 只读 Viewer 不允许发送控制事件，因此前端不会加载控制脚本
+func (sa *Agent) ViewerCapabilities(viewerID string) sdriver.DriverCaps {
+	caps := sa.Capabilities()
+	v, ok := sa.getViewer(viewerID)
+	if !ok || !v.CanControl() {
+		caps.CanControl = false
+		caps.CanClipboard = false
+		caps.CanUHID = false
+		caps.CanSystemPanels = false
+		caps.CanDisplayPower = false
+	}
+	return caps
+}
@@ -0,0 +1,144 @@
+package sagent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/pion/ice/v4"
+	"github.com/pion/webrtc/v4"
+)
+
+const DEFAULT_STUN_SERVER = "stun:stun.l.google.com:19302"
+
+// ICEServer 对应一个 STUN/TURN 服务器，TURN 需要填写用户名和密码
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// WebRTCConfig 是服务端的 WebRTC 网络设置，由启动参数或配置文件决定，不接受浏览器传入
+type WebRTCConfig struct {
+	ICEServers []ICEServer `json:"ice_servers"`
+	// NAT 1:1 映射，服务器在 NAT 后面时填写公网 IP，会替换 host 候选中的内网地址
+	NAT1To1IPs []string `json:"nat_1to1_ips"`
+	// 本地 UDP 端口范围，均为 0 时由系统随机分配
+	UDPPortMin uint16 `json:"udp_port_min"`
+	UDPPortMax uint16 `json:"udp_port_max"`
+	// 所有 PeerConnection 复用同一个 UDP 端口，0 表示不启用，启用后 UDP 端口范围不再生效
+	UDPMuxPort int `json:"udp_mux_port"`
+	// ICE-TCP 监听端口，供屏蔽 UDP 的网络使用，0 表示不启用
+	TCPMuxPort int `json:"tcp_mux_port"`
+
+	// Listen 创建的共享监听，所有 Agent 复制配置时共用同一份
+	udpMux ice.UDPMux
+	tcpMux ice.TCPMux
+}
+
+func DefaultWebRTCConfig() WebRTCConfig {
+	return WebRTCConfig{
+		ICEServers: []ICEServer{{URLs: []string{DEFAULT_STUN_SERVER}}},
+	}
+}
+
+// LoadWebRTCConfig 从 JSON 文件读取 WebRTC 设置
+func LoadWebRTCConfig(path string) (WebRTCConfig, error) {
+	var config WebRTCConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return config, config.Validate()
+}
+
+func (c WebRTCConfig) Validate() error {
+	if c.UDPPortMin > c.UDPPortMax {
+		return fmt.Errorf("invalid udp port range: %d-%d", c.UDPPortMin, c.UDPPortMax)
+	}
+	if (c.UDPPortMin == 0) != (c.UDPPortMax == 0) {
+		return fmt.Errorf("udp port range needs both min and max")
+	}
+	if c.UDPMuxPort < 0 || c.UDPMuxPort > 65535 || c.TCPMuxPort < 0 || c.TCPMuxPort > 65535 {
+		return fmt.Errorf("invalid mux port")
+	}
+	for _, s := range c.ICEServers {
+		if len(s.URLs) == 0 {
+			return fmt.Errorf("ice server without urls")
+		}
+	}
+	return nil
+}
+
+// Listen 打开 UDP mux 和 ICE-TCP 的监听端口，需要在创建任何 Agent 之前调用
+func (c *WebRTCConfig) Listen() error {
+	if c.UDPMuxPort != 0 {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: c.UDPMuxPort})
+		if err != nil {
+			return fmt.Errorf("listen udp mux: %w", err)
+		}
+		c.udpMux = webrtc.NewICEUDPMux(nil, conn)
+		log.Printf("WebRTC UDP mux listening on :%d", c.UDPMuxPort)
+	}
+	if c.TCPMuxPort != 0 {
+		listener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: c.TCPMuxPort})
+		if err != nil {
+			c.Close()
+			return fmt.Errorf("listen ice-tcp: %w", err)
+		}
+		c.tcpMux = webrtc.NewICETCPMux(nil, listener, 8)
+		log.Printf("WebRTC ICE-TCP listening on :%d", c.TCPMuxPort)
+	}
+	return nil
+}
+
+func (c *WebRTCConfig) Close() {
+	if c.udpMux != nil {
+		c.udpMux.Close()
+		c.udpMux = nil
+	}
+	if c.tcpMux != nil {
+		c.tcpMux.Close()
+		c.tcpMux = nil
+	}
+}
+
+func (c WebRTCConfig) configuration() webrtc.Configuration {
+	servers := make([]webrtc.ICEServer, 0, len(c.ICEServers))
+	for _, s := range c.ICEServers {
+		server := webrtc.ICEServer{URLs: s.URLs}
+		if s.Username != "" || s.Credential != "" {
+			server.Username = s.Username
+			server.Credential = s.Credential
+		}
+		servers = append(servers, server)
+	}
+	return webrtc.Configuration{ICEServers: servers}
+}
+
+func (c WebRTCConfig) settingEngine() webrtc.SettingEngine {
+	se := webrtc.SettingEngine{}
+	if len(c.NAT1To1IPs) > 0 {
+		se.SetNAT1To1IPs(c.NAT1To1IPs, webrtc.ICECandidateTypeHost)
+	}
+	if c.udpMux != nil {
+		se.SetICEUDPMux(c.udpMux)
+	} else if c.UDPPortMin != 0 && c.UDPPortMax != 0 {
+		if err := se.SetEphemeralUDPPortRange(c.UDPPortMin, c.UDPPortMax); err != nil {
+			log.Printf("Invalid UDP port range %d-%d: %v", c.UDPPortMin, c.UDPPortMax, err)
+		}
+	}
+	if c.tcpMux != nil {
+		se.SetICETCPMux(c.tcpMux)
+		se.SetNetworkTypes([]webrtc.NetworkType{
+			webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6,
+			webrtc.NetworkTypeTCP4, webrtc.NetworkTypeTCP6,
+		})
+	}
+	return se
+}
@@ -0,0 +1,96 @@
+package turnserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pion/turn/v4"
+)
+
+const REALM = "webscreen"
+
+// Config 描述内置 TURN 服务器
+type Config struct {
+	// 监听的 UDP 端口
+	Port int
+	// 写进 relay 候选的地址，浏览器需要能访问到它
+	PublicIP string
+}
+
+// Server 是一个进程内的 TURN 服务器，只接受由 Credentials 签发的临时凭据
+// 凭据格式遵循 TURN REST API: username = "<过期时间戳>:<用户>", password = base64(HMAC-SHA1(secret, username))
+type Server struct {
+	config Config
+	secret string
+	server *turn.Server
+}
+
+func Start(config Config) (*Server, error) {
+	publicIP := net.ParseIP(config.PublicIP)
+	if config.PublicIP == "" {
+		publicIP = outboundIP()
+	}
+	if publicIP == nil {
+		return nil, fmt.Errorf("invalid turn public ip: %q", config.PublicIP)
+	}
+	config.PublicIP = publicIP.String()
+
+	conn, err := net.ListenPacket("udp4", "0.0.0.0:"+strconv.Itoa(config.Port))
+	if err != nil {
+		return nil, fmt.Errorf("listen turn: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s := &Server{config: config, secret: hex.EncodeToString(secret)}
+	s.server, err = turn.NewServer(turn.ServerConfig{
+		Realm:       REALM,
+		AuthHandler: turn.LongTermTURNRESTAuthHandler(s.secret, nil),
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: conn,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: publicIP,
+					Address:      "0.0.0.0",
+				},
+			},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	log.Printf("[turn] TURN server listening on :%d, relay address %s", config.Port, config.PublicIP)
+	return s, nil
+}
+
+// URL 返回浏览器连接用的 TURN 地址
+func (s *Server) URL() string {
+	return fmt.Sprintf("turn:%s:%d?transport=udp", s.config.PublicIP, s.config.Port)
+}
+
+// Credentials 为 user 签发有效期为 ttl 的临时凭据
+func (s *Server) Credentials(user string, ttl time.Duration) (string, string, error) {
+	return turn.GenerateLongTermTURNRESTCredentials(s.secret, user, ttl)
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// outboundIP 返回默认路由使用的本机地址，没有配置公网 IP 时作为 relay 地址
+func outboundIP() net.IP {
+	conn, err := net.Dial("udp4", "8.8.8.8:80")
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP
+}
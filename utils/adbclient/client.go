@@ -0,0 +1,224 @@
+// Package adbclient 通过 smart-socket 协议直接和 adb server 通信，
+// 不再为每条命令启动 adb 进程
+package adbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"webscreen/utils"
+)
+
+const DEFAULT_SERVER_PORT = 5037
+
+var (
+	// ErrServerUnavailable 表示连不上 adb server，也无法启动它
+	ErrServerUnavailable = errors.New("adb server unavailable")
+	// ErrProtocol 表示 adb server 返回了协议不允许的内容
+	ErrProtocol = errors.New("adb protocol error")
+	// 设备相关的错误对应 adb server 的 FAIL 消息，见 ServerError.Is
+	ErrDeviceNotFound = errors.New("device not found")
+	ErrDeviceOffline  = errors.New("device offline")
+	ErrUnauthorized   = errors.New("device unauthorized")
+)
+
+// ServerError 是 FAIL 响应，或者 host:connect、host:pair 返回的失败结果
+type ServerError struct {
+	Request string
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("adb %s: %s", e.Request, e.Message)
+}
+
+func (e *ServerError) Is(target error) bool {
+	switch target {
+	case ErrDeviceNotFound:
+		// "device 'serial' not found" 或 "no devices/emulators found"
+		return (strings.HasPrefix(e.Message, "device '") && strings.HasSuffix(e.Message, "not found")) ||
+			strings.HasPrefix(e.Message, "no devices")
+	case ErrDeviceOffline:
+		return strings.Contains(e.Message, "offline")
+	case ErrUnauthorized:
+		return strings.Contains(e.Message, "unauthorized")
+	}
+	return false
+}
+
+// ExitError 表示 shell 命令的退出码不为 0
+type ExitError struct {
+	Command string
+	Code    int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("adb shell %s: exit status %d", e.Command, e.Code)
+}
+
+// Client 可以并发使用，每个请求使用单独的连接
+type Client struct {
+	Addr string
+}
+
+// Default 连接本机的 adb server，端口可以用 ANDROID_ADB_SERVER_PORT 指定
+var Default = New()
+
+func New() *Client {
+	port := DEFAULT_SERVER_PORT
+	if p, err := strconv.Atoi(os.Getenv("ANDROID_ADB_SERVER_PORT")); err == nil && p > 0 {
+		port = p
+	}
+	return &Client{Addr: net.JoinHostPort("127.0.0.1", strconv.Itoa(port))}
+}
+
+// conn 是一个 smart-socket 连接，ctx 取消时自动关闭
+type conn struct {
+	net.Conn
+	ctx  context.Context
+	stop func() bool
+}
+
+func (c *conn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// wrap 在 ctx 取消时返回 ctx 的错误，而不是关闭连接导致的 "use of closed connection"
+func (c *conn) wrap(err error) error {
+	if err != nil && c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	return err
+}
+
+func (cl *Client) dial(ctx context.Context) (*conn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", cl.Addr)
+	if err != nil && errors.Is(err, syscall.ECONNREFUSED) {
+		// 和 adb 命令一样，第一次使用时启动 server
+		if startErr := cl.startServer(ctx); startErr == nil {
+			nc, err = d.DialContext(ctx, "tcp", cl.Addr)
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v", ErrServerUnavailable, err)
+	}
+	c := &conn{Conn: nc, ctx: ctx}
+	c.stop = context.AfterFunc(ctx, func() { nc.Close() })
+	return c, nil
+}
+
+func (cl *Client) startServer(ctx context.Context) error {
+	adbPath, err := utils.GetADBPath()
+	if err != nil {
+		return err
+	}
+	_, port, _ := net.SplitHostPort(cl.Addr)
+	return exec.CommandContext(ctx, adbPath, "-P", port, "start-server").Run()
+}
+
+// send 按 <4 位十六进制长度><内容> 发送请求
+func (c *conn) send(request string) error {
+	_, err := fmt.Fprintf(c, "%04x%s", len(request), request)
+	return c.wrap(err)
+}
+
+// readStatus 读取 OKAY 或 FAIL <消息>
+func (c *conn) readStatus(request string) error {
+	var status [4]byte
+	if _, err := io.ReadFull(c, status[:]); err != nil {
+		return c.wrap(err)
+	}
+	switch string(status[:]) {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		msg, err := c.readString()
+		if err != nil {
+			return err
+		}
+		return &ServerError{Request: request, Message: msg}
+	}
+	return fmt.Errorf("%w: unexpected status %q", ErrProtocol, status[:])
+}
+
+// readString 读取 <4 位十六进制长度><内容> 格式的响应
+func (c *conn) readString() (string, error) {
+	var hexLen [4]byte
+	if _, err := io.ReadFull(c, hexLen[:]); err != nil {
+		return "", c.wrap(err)
+	}
+	n, err := strconv.ParseUint(string(hexLen[:]), 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad length %q", ErrProtocol, hexLen[:])
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return "", c.wrap(err)
+	}
+	return string(buf), nil
+}
+
+// request 建立连接并发送 host 请求，收到 OKAY 后返回连接
+func (cl *Client) request(ctx context.Context, request string) (*conn, error) {
+	c, err := cl.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.send(request); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.readStatus(request); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// query 发送 host 请求并返回带长度前缀的响应
+func (cl *Client) query(ctx context.Context, request string) (string, error) {
+	c, err := cl.request(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	return c.readString()
+}
+
+// transport 建立连接并切换到设备，serial 为空时选择唯一的设备
+func (cl *Client) transport(ctx context.Context, serial string) (*conn, error) {
+	request := "host:transport:" + serial
+	if serial == "" {
+		request = "host:transport-any"
+	}
+	return cl.request(ctx, request)
+}
+
+// service 打开 shell:、sync: 等设备服务
+func (cl *Client) service(ctx context.Context, serial, service string) (*conn, error) {
+	c, err := cl.transport(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.send(service); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.readStatus(service); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
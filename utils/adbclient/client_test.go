@@ -0,0 +1,307 @@
+package adbclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeShell 是 fakeServer 中一条 shell 命令的输出和退出码
+type fakeShell struct {
+	stdout string
+	stderr string
+	code   byte
+}
+
+// fakeFile 是通过 sync SEND 写入 fakeServer 的文件
+type fakeFile struct {
+	data  []byte
+	mode  uint32
+	mtime uint32
+}
+
+// fakeServer 是进程内的 adb server，按 smart-socket 协议应答
+type fakeServer struct {
+	// host 请求的应答，如 host:devices-l、host:connect:addr
+	replies map[string]string
+	// host:transport 可以切换到的设备和它们的状态
+	states   map[string]string
+	features string
+	shell    map[string]fakeShell
+
+	mu       sync.Mutex
+	requests []string
+	files    map[string]fakeFile
+	reverses map[string]string
+}
+
+// start 在随机端口上监听，返回连接到它的 Client
+func (f *fakeServer) start(t *testing.T) *Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f.files = map[string]fakeFile{}
+	f.reverses = map[string]string{}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(c)
+		}
+	}()
+	return &Client{Addr: ln.Addr().String()}
+}
+
+// requested 返回收到的所有请求，包括切换到设备后的服务请求
+func (f *fakeServer) requested() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+func (f *fakeServer) readRequest(c net.Conn) (string, error) {
+	var hexLen [4]byte
+	if _, err := io.ReadFull(c, hexLen[:]); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(hexLen[:]), 16, 16)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, string(buf))
+	f.mu.Unlock()
+	return string(buf), nil
+}
+
+func writeFail(c net.Conn, msg string) {
+	fmt.Fprintf(c, "FAIL%04x%s", len(msg), msg)
+}
+
+func (f *fakeServer) handle(c net.Conn) {
+	defer c.Close()
+	req, err := f.readRequest(c)
+	if err != nil {
+		return
+	}
+	switch {
+	case req == "host:transport-any":
+		if len(f.states) == 0 {
+			writeFail(c, "no devices/emulators found")
+			return
+		}
+		c.Write([]byte("OKAY"))
+		f.device(c)
+	case strings.HasPrefix(req, "host:transport:"):
+		serial := strings.TrimPrefix(req, "host:transport:")
+		switch state, ok := f.states[serial]; {
+		case !ok:
+			writeFail(c, fmt.Sprintf("device '%s' not found", serial))
+		case state == "offline":
+			writeFail(c, "device offline")
+		case state == "unauthorized":
+			writeFail(c, "device unauthorized.\nThis adb server's $ADB_VENDOR_KEYS is not set")
+		default:
+			c.Write([]byte("OKAY"))
+			f.device(c)
+		}
+	case req == "host:features" || strings.HasPrefix(req, "host-serial:") && strings.HasSuffix(req, ":features"):
+		fmt.Fprintf(c, "OKAY%04x%s", len(f.features), f.features)
+	default:
+		reply, ok := f.replies[req]
+		if !ok {
+			writeFail(c, "unknown host service")
+			return
+		}
+		fmt.Fprintf(c, "OKAY%04x%s", len(reply), reply)
+	}
+}
+
+// device 处理切换到设备之后的服务请求
+func (f *fakeServer) device(c net.Conn) {
+	req, err := f.readRequest(c)
+	if err != nil {
+		return
+	}
+	switch {
+	case strings.HasPrefix(req, "shell,v2,raw:"):
+		cmd := strings.TrimPrefix(req, "shell,v2,raw:")
+		sh := f.lookupShell(cmd)
+		c.Write([]byte("OKAY"))
+		// 客户端先关闭 stdin
+		var closeStdin [5]byte
+		if _, err := io.ReadFull(c, closeStdin[:]); err != nil || closeStdin[0] != shellCloseStdin {
+			return
+		}
+		writeShellPacket(c, shellStdout, []byte(sh.stdout))
+		writeShellPacket(c, shellStderr, []byte(sh.stderr))
+		writeShellPacket(c, shellExit, []byte{sh.code})
+	case strings.HasPrefix(req, "shell:"):
+		sh := f.lookupShell(strings.TrimPrefix(req, "shell:"))
+		c.Write([]byte("OKAY" + sh.stdout + sh.stderr))
+	case req == "sync:":
+		c.Write([]byte("OKAY"))
+		f.sync(c)
+	case strings.HasPrefix(req, "reverse:forward:"):
+		remote, local, _ := strings.Cut(strings.TrimPrefix(req, "reverse:forward:"), ";")
+		// 先应答服务本身，再应答执行结果
+		c.Write([]byte("OKAY"))
+		if !strings.HasPrefix(local, "tcp:") {
+			writeFail(c, "cannot bind listener: unknown socket specification")
+			return
+		}
+		f.mu.Lock()
+		f.reverses[remote] = local
+		f.mu.Unlock()
+		c.Write([]byte("OKAY"))
+	default:
+		writeFail(c, "unknown service")
+	}
+}
+
+func (f *fakeServer) lookupShell(cmd string) fakeShell {
+	if sh, ok := f.shell[cmd]; ok {
+		return sh
+	}
+	return fakeShell{stderr: "/system/bin/sh: " + cmd + ": not found\n", code: 127}
+}
+
+func writeShellPacket(c net.Conn, id byte, data []byte) {
+	if len(data) == 0 && id != shellExit {
+		return
+	}
+	header := []byte{id, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[1:], uint32(len(data)))
+	c.Write(append(header, data...))
+}
+
+func writeSyncPacket(c net.Conn, id string, data []byte) {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	c.Write(append(header, data...))
+}
+
+// sync 处理 sync: 协议，/system/ 下的文件只读
+func (f *fakeServer) sync(c net.Conn) {
+	var path string
+	var mode uint64
+	var data bytes.Buffer
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(c, header[:]); err != nil {
+			return
+		}
+		id, n := string(header[:4]), binary.LittleEndian.Uint32(header[4:])
+		switch id {
+		case "SEND", "RECV":
+			arg := make([]byte, n)
+			if _, err := io.ReadFull(c, arg); err != nil {
+				return
+			}
+			if id == "RECV" {
+				f.recv(c, string(arg))
+				continue
+			}
+			var m string
+			path, m, _ = strings.Cut(string(arg), ",")
+			mode, _ = strconv.ParseUint(m, 10, 32)
+			data.Reset()
+		case "DATA":
+			if n > syncMaxChunk {
+				writeSyncPacket(c, "FAIL", []byte("DATA packet too large"))
+				return
+			}
+			if _, err := io.CopyN(&data, c, int64(n)); err != nil {
+				return
+			}
+		case "DONE":
+			// DONE 的长度字段是修改时间
+			if strings.HasPrefix(path, "/system/") {
+				writeSyncPacket(c, "FAIL", []byte("Read-only file system"))
+				continue
+			}
+			f.mu.Lock()
+			f.files[path] = fakeFile{data: bytes.Clone(data.Bytes()), mode: uint32(mode), mtime: n}
+			f.mu.Unlock()
+			writeSyncPacket(c, "OKAY", nil)
+		case "QUIT":
+			return
+		default:
+			writeSyncPacket(c, "FAIL", []byte("unknown sync request "+id))
+			return
+		}
+	}
+}
+
+func (f *fakeServer) recv(c net.Conn, path string) {
+	f.mu.Lock()
+	file, ok := f.files[path]
+	f.mu.Unlock()
+	if !ok {
+		writeSyncPacket(c, "FAIL", []byte("No such file or directory"))
+		return
+	}
+	for data := file.data; len(data) > 0; {
+		n := min(len(data), syncMaxChunk)
+		writeSyncPacket(c, "DATA", data[:n])
+		data = data[n:]
+	}
+	writeSyncPacket(c, "DONE", nil)
+}
+
+func TestTransport(t *testing.T) {
+	f := &fakeServer{states: map[string]string{
+		"emulator-5554":     "device",
+		"192.168.1.5:5555":  "offline",
+		"R58M12345678":      "unauthorized",
+		"192.168.1.6:40001": "device",
+	}}
+	cl := f.start(t)
+	tests := []struct {
+		serial  string
+		request string
+		err     error
+	}{
+		{"", "host:transport-any", nil},
+		{"emulator-5554", "host:transport:emulator-5554", nil},
+		{"192.168.1.6:40001", "host:transport:192.168.1.6:40001", nil},
+		{"missing", "host:transport:missing", ErrDeviceNotFound},
+		{"192.168.1.5:5555", "host:transport:192.168.1.5:5555", ErrDeviceOffline},
+		{"R58M12345678", "host:transport:R58M12345678", ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.request, func(t *testing.T) {
+			c, err := cl.transport(context.Background(), tt.serial)
+			if c != nil {
+				c.Close()
+			}
+			if tt.err == nil && err != nil {
+				t.Fatalf("transport(%q) = %v", tt.serial, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("transport(%q) = %v, want %v", tt.serial, err, tt.err)
+			}
+			if requests := f.requested(); requests[len(requests)-1] != tt.request {
+				t.Errorf("last request %q, want %q", requests[len(requests)-1], tt.request)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package adbclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// shell v2 协议的包类型
+const (
+	shellStdin      = 0
+	shellStdout     = 1
+	shellStderr     = 2
+	shellExit       = 3
+	shellCloseStdin = 4
+)
+
+// Shell 在设备上执行 cmd，stdin 关闭，stderr 为 nil 时合并到 stdout
+// 支持 shell_v2 时退出码不为 0 返回 *ExitError，旧设备只能知道命令是否启动
+// 取消 ctx 会关闭连接，设备上的命令也随之结束
+func (cl *Client) Shell(ctx context.Context, serial, cmd string, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = stdout
+	}
+	features, err := cl.Features(ctx, serial)
+	if err != nil {
+		return err
+	}
+	if !features["shell_v2"] {
+		c, err := cl.service(ctx, serial, "shell:"+cmd)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		_, err = io.Copy(stdout, c)
+		return c.wrap(err)
+	}
+
+	c, err := cl.service(ctx, serial, "shell,v2,raw:"+cmd)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte{shellCloseStdin, 0, 0, 0, 0}); err != nil {
+		return c.wrap(err)
+	}
+	var header [5]byte
+	for {
+		if _, err := io.ReadFull(c, header[:]); err != nil {
+			if err == io.EOF {
+				// 连接关闭时没有收到退出码
+				return fmt.Errorf("%w: shell closed without exit status", ErrProtocol)
+			}
+			return c.wrap(err)
+		}
+		n := int64(binary.LittleEndian.Uint32(header[1:]))
+		switch header[0] {
+		case shellStdout:
+			_, err = io.CopyN(stdout, c, n)
+		case shellStderr:
+			_, err = io.CopyN(stderr, c, n)
+		case shellExit:
+			var code [1]byte
+			if n != 1 {
+				return fmt.Errorf("%w: exit packet of %d bytes", ErrProtocol, n)
+			}
+			if _, err := io.ReadFull(c, code[:]); err != nil {
+				return c.wrap(err)
+			}
+			if code[0] != 0 {
+				return &ExitError{Command: cmd, Code: int(code[0])}
+			}
+			return nil
+		default:
+			_, err = io.CopyN(io.Discard, c, n)
+		}
+		if err != nil {
+			return c.wrap(err)
+		}
+	}
+}
+
+// ShellOutput 执行 cmd 并返回合并的 stdout 和 stderr，失败时也返回输出
+func (cl *Client) ShellOutput(ctx context.Context, serial, cmd string) (string, error) {
+	var out bytes.Buffer
+	err := cl.Shell(ctx, serial, cmd, &out, nil)
+	return out.String(), err
+}
+
+// ExecOut 不分配 pty 执行 cmd 并返回原始 stdout，相当于 adb exec-out
+func (cl *Client) ExecOut(ctx context.Context, serial, cmd string) ([]byte, error) {
+	c, err := cl.service(ctx, serial, "exec:"+cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	data, err := io.ReadAll(c)
+	return data, c.wrap(err)
+}
+
+// Reverse 让设备上的 remote (如 localabstract:name) 连接到本机的 local (如 tcp:27183)
+func (cl *Client) Reverse(ctx context.Context, serial, remote, local string) error {
+	return cl.reverseCommand(ctx, serial, "reverse:forward:"+remote+";"+local)
+}
+
+// ReverseRemove 删除 Reverse 建立的 reverse tunnel
+func (cl *Client) ReverseRemove(ctx context.Context, serial, remote string) error {
+	return cl.reverseCommand(ctx, serial, "reverse:killforward:"+remote)
+}
+
+// reverseCommand 发送 reverse: 服务，先收到服务的 OKAY，再收到执行结果
+func (cl *Client) reverseCommand(ctx context.Context, serial, service string) error {
+	c, err := cl.service(ctx, serial, service)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return c.readStatus(strings.SplitN(service, ";", 2)[0])
+}
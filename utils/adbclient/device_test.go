@@ -0,0 +1,86 @@
+package adbclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestShell(t *testing.T) {
+	commands := map[string]fakeShell{
+		"echo hi":                              {stdout: "hi\n"},
+		"ls /nope":                             {stderr: "ls: /nope: No such file or directory\n", code: 1},
+		"getprop ro.build.version.sdk; exit 2": {stdout: "34\n", code: 2},
+	}
+	tests := []struct {
+		name     string
+		features string
+		cmd      string
+		want     string
+		code     int
+	}{
+		{"v2 exit 0", "shell_v2,cmd", "echo hi", "hi\n", 0},
+		{"v2 stderr and exit 1", "shell_v2,cmd", "ls /nope", "ls: /nope: No such file or directory\n", 1},
+		{"v2 output before exit 2", "shell_v2,cmd", "getprop ro.build.version.sdk; exit 2", "34\n", 2},
+		{"v2 unknown command", "shell_v2", "nope", "/system/bin/sh: nope: not found\n", 127},
+		// 旧设备不支持 shell_v2，拿不到退出码
+		{"legacy shell", "cmd", "ls /nope", "ls: /nope: No such file or directory\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeServer{states: map[string]string{"emulator-5554": "device"}, features: tt.features, shell: commands}
+			cl := f.start(t)
+			out, err := cl.ShellOutput(context.Background(), "emulator-5554", tt.cmd)
+			if out != tt.want {
+				t.Errorf("output %q, want %q", out, tt.want)
+			}
+			var exitErr *ExitError
+			switch {
+			case tt.code == 0 && err != nil:
+				t.Errorf("ShellOutput() = %v", err)
+			case tt.code != 0 && (!errors.As(err, &exitErr) || exitErr.Code != tt.code || exitErr.Command != tt.cmd):
+				t.Errorf("ShellOutput() = %v, want exit status %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		local   string
+		wantErr string
+	}{
+		{"tcp target", "localabstract:scrcpy_0a1b2c3d", "tcp:27183", ""},
+		{"bad target", "localabstract:scrcpy_0a1b2c3d", "bogus:1", "cannot bind listener"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeServer{states: map[string]string{"emulator-5554": "device"}}
+			cl := f.start(t)
+			err := cl.Reverse(context.Background(), "emulator-5554", tt.remote, tt.local)
+			if tt.wantErr != "" {
+				var serverErr *ServerError
+				if !errors.As(err, &serverErr) || !strings.Contains(serverErr.Message, tt.wantErr) {
+					t.Fatalf("Reverse() = %v, want %q", err, tt.wantErr)
+				}
+				// 错误信息中只有服务名和 remote，不包含 local
+				if serverErr.Request != "reverse:forward:"+tt.remote {
+					t.Errorf("request %q", serverErr.Request)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reverse() = %v", err)
+			}
+			f.mu.Lock()
+			got := f.reverses[tt.remote]
+			f.mu.Unlock()
+			if got != tt.local {
+				t.Errorf("reverse %s -> %q, want %q", tt.remote, got, tt.local)
+			}
+		})
+	}
+}
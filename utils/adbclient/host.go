@@ -0,0 +1,137 @@
+package adbclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Device 是 host:devices-l 输出中的一行
+type Device struct {
+	Serial      string `json:"serial"`
+	State       string `json:"state"` // device, offline, unauthorized, ...
+	Product     string `json:"product"`
+	Model       string `json:"model"`
+	Device      string `json:"device"`
+	TransportID string `json:"transport_id"`
+}
+
+// Version 返回 adb server 的协议版本
+func (cl *Client) Version(ctx context.Context) (int, error) {
+	s, err := cl.query(ctx, "host:version")
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(s, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad version %q", ErrProtocol, s)
+	}
+	return int(v), nil
+}
+
+// Devices 列出 adb server 已知的所有设备，包括各种状态
+func (cl *Client) Devices(ctx context.Context) ([]Device, error) {
+	s, err := cl.query(ctx, "host:devices-l")
+	if err != nil {
+		return nil, err
+	}
+	return parseDevices(s), nil
+}
+
+// emulator-5554  device product:sdk_gphone64 model:sdk_gphone64 device:emu64 transport_id:1
+func parseDevices(s string) []Device {
+	devices := []Device{}
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		d := Device{Serial: fields[0], State: fields[1]}
+		for _, f := range fields[2:] {
+			key, value, ok := strings.Cut(f, ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "product":
+				d.Product = value
+			case "model":
+				d.Model = value
+			case "device":
+				d.Device = value
+			case "transport_id":
+				d.TransportID = value
+			}
+		}
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+// Features 返回 adb server 和设备都支持的特性，如 shell_v2
+func (cl *Client) Features(ctx context.Context, serial string) (map[string]bool, error) {
+	request := "host:features"
+	if serial != "" {
+		request = "host-serial:" + serial + ":features"
+	}
+	s, err := cl.query(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	features := map[string]bool{}
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			features[f] = true
+		}
+	}
+	return features, nil
+}
+
+// Connect 通过 TCP/IP 连接设备，address 为 host:port
+func (cl *Client) Connect(ctx context.Context, address string) error {
+	request := "host:connect:" + address
+	s, err := cl.query(ctx, request)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(s, "connected to") || strings.HasPrefix(s, "already connected to") {
+		return nil
+	}
+	return &ServerError{Request: request, Message: s}
+}
+
+// Pair 使用设备无线调试设置中显示的配对码进行配对
+func (cl *Client) Pair(ctx context.Context, address, code string) error {
+	s, err := cl.query(ctx, "host:pair:"+code+":"+address)
+	// 错误信息中不包含配对码
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		serverErr.Request = "host:pair:" + address
+	}
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(s, "Successfully paired") {
+		return nil
+	}
+	return &ServerError{Request: "host:pair:" + address, Message: s}
+}
+
+// TrackDevices 先用当前的设备列表调用 fn，之后每次变化时用完整列表再调用
+// 一直阻塞到 ctx 取消或 adb server 关闭连接
+func (cl *Client) TrackDevices(ctx context.Context, fn func([]Device)) error {
+	c, err := cl.request(ctx, "host:track-devices-l")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for {
+		s, err := c.readString()
+		if err != nil {
+			return err
+		}
+		fn(parseDevices(s))
+	}
+}
@@ -0,0 +1,108 @@
+package adbclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDevices(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  []Device
+	}{
+		{
+			name:  "no devices",
+			reply: "",
+			want:  []Device{},
+		},
+		{
+			name: "usb and tcp devices",
+			reply: "emulator-5554          device product:sdk_gphone64 model:sdk_gphone64 device:emu64 transport_id:1\n" +
+				"R58M12345678           unauthorized usb:1-1 transport_id:2\n" +
+				"192.168.1.5:5555       offline transport_id:3\n",
+			want: []Device{
+				{Serial: "emulator-5554", State: "device", Product: "sdk_gphone64", Model: "sdk_gphone64", Device: "emu64", TransportID: "1"},
+				{Serial: "R58M12345678", State: "unauthorized", TransportID: "2"},
+				{Serial: "192.168.1.5:5555", State: "offline", TransportID: "3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := (&fakeServer{replies: map[string]string{"host:devices-l": tt.reply}}).start(t)
+			got, err := cl.Devices(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Devices() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnect(t *testing.T) {
+	const address = "192.168.1.5:5555"
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr bool
+	}{
+		{"connected", "connected to " + address, false},
+		{"already connected", "already connected to " + address, false},
+		{"refused", "failed to connect to '" + address + "': Connection refused", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := (&fakeServer{replies: map[string]string{"host:connect:" + address: tt.reply}}).start(t)
+			err := cl.Connect(context.Background(), address)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Connect() = %v", err)
+				}
+				return
+			}
+			var serverErr *ServerError
+			if !errors.As(err, &serverErr) || serverErr.Message != tt.reply {
+				t.Fatalf("Connect() = %v, want ServerError %q", err, tt.reply)
+			}
+		})
+	}
+}
+
+func TestPair(t *testing.T) {
+	const address, code = "192.168.1.5:37000", "482913"
+	tests := []struct {
+		name    string
+		replies map[string]string
+		wantErr bool
+	}{
+		{"paired", map[string]string{"host:pair:" + code + ":" + address: "Successfully paired to " + address + " [guid=adb-R58M12345678]"}, false},
+		{"wrong code", map[string]string{"host:pair:" + code + ":" + address: "Failed: Wrong password or connection was dropped."}, true},
+		{"server FAIL", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := (&fakeServer{replies: tt.replies}).start(t)
+			err := cl.Pair(context.Background(), address, code)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Pair() = %v", err)
+				}
+				return
+			}
+			var serverErr *ServerError
+			if !errors.As(err, &serverErr) {
+				t.Fatalf("Pair() = %v, want ServerError", err)
+			}
+			// 错误信息中不能出现配对码
+			if strings.Contains(err.Error(), code) {
+				t.Errorf("Pair() error %q leaks the pairing code", err)
+			}
+		})
+	}
+}
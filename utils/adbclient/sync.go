@@ -0,0 +1,177 @@
+package adbclient
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// sync 协议每个 DATA 包最多 64KB
+const syncMaxChunk = 64 * 1024
+
+// syncConn 使用 sync: 协议，请求格式为 <4 字节 id><小端 u32 长度><数据>
+type syncConn struct {
+	*conn
+}
+
+func (cl *Client) openSync(ctx context.Context, serial string) (*syncConn, error) {
+	c, err := cl.service(ctx, serial, "sync:")
+	if err != nil {
+		return nil, err
+	}
+	return &syncConn{c}, nil
+}
+
+func (s *syncConn) sendPacket(id string, data []byte) error {
+	buf := make([]byte, 8+len(data))
+	copy(buf, id)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(data)))
+	copy(buf[8:], data)
+	_, err := s.Write(buf)
+	return s.wrap(err)
+}
+
+func (s *syncConn) readHeader() (string, uint32, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(s, header[:]); err != nil {
+		return "", 0, s.wrap(err)
+	}
+	return string(header[:4]), binary.LittleEndian.Uint32(header[4:]), nil
+}
+
+func (s *syncConn) readFail(request string, n uint32) error {
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(s, msg); err != nil {
+		return s.wrap(err)
+	}
+	return &ServerError{Request: request, Message: string(msg)}
+}
+
+func (s *syncConn) Close() error {
+	s.sendPacket("QUIT", nil)
+	return s.conn.Close()
+}
+
+// Push 把 r 写到设备上的 remotePath，使用给定的权限位
+func (cl *Client) Push(ctx context.Context, serial string, r io.Reader, remotePath string, perm os.FileMode, mtime time.Time) error {
+	s, err := cl.openSync(ctx, serial)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	request := "sync SEND " + remotePath
+	// mode 包含 S_IFREG，和主机上 stat() 的结果一致
+	if err := s.sendPacket("SEND", fmt.Appendf(nil, "%s,%d", remotePath, 0o100000|perm.Perm())); err != nil {
+		return err
+	}
+	buf := make([]byte, syncMaxChunk)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := s.sendPacket("DATA", buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var done [8]byte
+	copy(done[:], "DONE")
+	binary.LittleEndian.PutUint32(done[4:], uint32(mtime.Unix()))
+	if _, err := s.Write(done[:]); err != nil {
+		return s.wrap(err)
+	}
+	id, n, err := s.readHeader()
+	if err != nil {
+		return err
+	}
+	switch id {
+	case "OKAY":
+		return nil
+	case "FAIL":
+		return s.readFail(request, n)
+	}
+	return fmt.Errorf("%w: unexpected sync response %q", ErrProtocol, id)
+}
+
+// ProgressFunc 报告已通过 DATA 包发送的字节数和文件大小
+type ProgressFunc func(sent, total int64)
+
+// progressReader 统计已读入 DATA 包的字节数
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.progress(p.sent, p.total)
+	}
+	return n, err
+}
+
+// PushFile 把本地文件复制到 remotePath，保留权限和修改时间
+// progress 可以为 nil
+func (cl *Client) PushFile(ctx context.Context, serial, localPath, remotePath string, progress ProgressFunc) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var r io.Reader = f
+	if progress != nil {
+		r = &progressReader{r: f, total: info.Size(), progress: progress}
+	}
+	return cl.Push(ctx, serial, r, remotePath, info.Mode(), info.ModTime())
+}
+
+// Pull 把设备上的 remotePath 复制到 w
+func (cl *Client) Pull(ctx context.Context, serial, remotePath string, w io.Writer) error {
+	s, err := cl.openSync(ctx, serial)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	request := "sync RECV " + remotePath
+	if err := s.sendPacket("RECV", []byte(remotePath)); err != nil {
+		return err
+	}
+	for {
+		id, n, err := s.readHeader()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case "DATA":
+			if n > syncMaxChunk {
+				return fmt.Errorf("%w: DATA packet of %d bytes", ErrProtocol, n)
+			}
+			if _, err := io.CopyN(w, s, int64(n)); err != nil {
+				return s.wrap(err)
+			}
+		case "DONE":
+			return nil
+		case "FAIL":
+			return s.readFail(request, n)
+		default:
+			return fmt.Errorf("%w: unexpected sync response %q", ErrProtocol, id)
+		}
+	}
+}
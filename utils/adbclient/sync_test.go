@@ -0,0 +1,83 @@
+package adbclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPushPull(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		size    int
+		wantErr string
+	}{
+		{"empty file", "/data/local/tmp/empty", 0, ""},
+		{"single DATA packet", "/data/local/tmp/small", 100, ""},
+		{"several DATA packets", "/sdcard/Download/big.bin", 2*syncMaxChunk + 1, ""},
+		{"read-only target", "/system/app/big.apk", 10, "Read-only file system"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeServer{states: map[string]string{"emulator-5554": "device"}}
+			cl := f.start(t)
+			ctx := context.Background()
+
+			data := make([]byte, tt.size)
+			for i := range data {
+				data[i] = byte(i * 7)
+			}
+			local := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(local, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(local, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mtime := time.Unix(1700000000, 0)
+			if err := os.Chtimes(local, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			var sent, total int64
+			err := cl.PushFile(ctx, "emulator-5554", local, tt.path, func(s, n int64) { sent, total = s, n })
+
+			var serverErr *ServerError
+			if tt.wantErr != "" {
+				if !errors.As(err, &serverErr) || serverErr.Message != tt.wantErr {
+					t.Fatalf("PushFile() = %v, want %q", err, tt.wantErr)
+				}
+				// 推送失败后设备上没有这个文件
+				err := cl.Pull(ctx, "emulator-5554", tt.path, &bytes.Buffer{})
+				if !errors.As(err, &serverErr) || serverErr.Message != "No such file or directory" {
+					t.Fatalf("Pull() = %v, want missing file", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PushFile() = %v", err)
+			}
+			if sent != int64(tt.size) || (tt.size > 0 && total != int64(tt.size)) {
+				t.Errorf("progress %d/%d, want %d", sent, total, tt.size)
+			}
+			f.mu.Lock()
+			file := f.files[tt.path]
+			f.mu.Unlock()
+			if file.mode != 0o100644 || file.mtime != uint32(mtime.Unix()) {
+				t.Errorf("mode %o mtime %d, want 100644 and %d", file.mode, file.mtime, mtime.Unix())
+			}
+
+			var out bytes.Buffer
+			if err := cl.Pull(ctx, "emulator-5554", tt.path, &out); err != nil {
+				t.Fatalf("Pull() = %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Errorf("pulled %d bytes, want the %d bytes pushed", out.Len(), len(data))
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package android
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"webscreen/utils/adbclient"
+)
+
+var packageNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)+$`)
+
+// Intent describes an `am start` call, empty fields are omitted
+type Intent struct {
+	Action    string `json:"action"`
+	Data      string `json:"data"`
+	Package   string `json:"package"`
+	Component string `json:"component"`
+	Category  string `json:"category"`
+}
+
+// shell runs a command on the device, every argument is quoted for the device shell
+func shell(deviceID string, args ...string) (string, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	output, err := adbclient.Default.ShellOutput(context.Background(), deviceID, strings.Join(quoted, " "))
+	if err != nil {
+		return "", fmt.Errorf("adb shell %s failed: %w, output: %s", args[0], err, output)
+	}
+	return output, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func checkPackageName(pkg string) error {
+	if !packageNameRe.MatchString(pkg) {
+		return fmt.Errorf("invalid package name: %s", pkg)
+	}
+	return nil
+}
+
+// ListPackages returns installed package names, thirdParty limits the list to user-installed apps
+func ListPackages(deviceID string, thirdParty bool) ([]string, error) {
+	args := []string{"pm", "list", "packages"}
+	if thirdParty {
+		args = append(args, "-3")
+	}
+	output, err := shell(deviceID, args...)
+	if err != nil {
+		return nil, err
+	}
+	packages := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if pkg, ok := strings.CutPrefix(line, "package:"); ok {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+	return packages, nil
+}
+
+// StartApp launches the app's launcher activity
+func StartApp(deviceID, pkg string) error {
+	if err := checkPackageName(pkg); err != nil {
+		return err
+	}
+	output, err := shell(deviceID, "monkey", "-p", pkg, "-c", "android.intent.category.LAUNCHER", "1")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(output, "No activities found") {
+		return fmt.Errorf("no launcher activity in %s", pkg)
+	}
+	return nil
+}
+
+// ForceStopApp kills the app and its background services
+func ForceStopApp(deviceID, pkg string) error {
+	if err := checkPackageName(pkg); err != nil {
+		return err
+	}
+	_, err := shell(deviceID, "am", "force-stop", pkg)
+	return err
+}
+
+// ClearAppData deletes the app's data and cache, the app is stopped as well
+func ClearAppData(deviceID, pkg string) error {
+	if err := checkPackageName(pkg); err != nil {
+		return err
+	}
+	output, err := shell(deviceID, "pm", "clear", pkg)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(output, "Success") {
+		return fmt.Errorf("pm clear failed: %s", strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// StartIntent starts an activity, a URL alone is opened with ACTION_VIEW
+func StartIntent(deviceID string, intent Intent) error {
+	if intent.Action == "" && intent.Data != "" {
+		intent.Action = "android.intent.action.VIEW"
+	}
+	if intent.Action == "" && intent.Component == "" && intent.Package == "" {
+		return fmt.Errorf("intent needs an action, data, package or component")
+	}
+	args := []string{"am", "start"}
+	if intent.Action != "" {
+		args = append(args, "-a", intent.Action)
+	}
+	if intent.Data != "" {
+		args = append(args, "-d", intent.Data)
+	}
+	if intent.Category != "" {
+		args = append(args, "-c", intent.Category)
+	}
+	if intent.Component != "" {
+		args = append(args, "-n", intent.Component)
+	} else if intent.Package != "" {
+		if err := checkPackageName(intent.Package); err != nil {
+			return err
+		}
+		args = append(args, "-p", intent.Package)
+	}
+	output, err := shell(deviceID, args...)
+	if err != nil {
+		return err
+	}
+	// am start exits with 0 even when the intent fails
+	if strings.Contains(output, "Error:") {
+		return fmt.Errorf("am start failed: %s", strings.TrimSpace(output))
+	}
+	return nil
+}
@@ -1,96 +1,73 @@
 package android
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os"
-	"os/exec"
 	"strings"
-	"webscreen/utils"
+	"webscreen/utils/adbclient"
 )
 
-func ExecADB(args ...string) error {
-	adbPath, err := utils.GetADBPath()
-	if err != nil {
-		return err
-	}
-	cmd := exec.Command(adbPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 // GetDevices returns a list of connected devices
 func GetDevices() ([]AndroidDevice, error) {
-	adbPath, err := utils.GetADBPath()
-	if err != nil {
-		return nil, err
-	}
-	cmd := exec.Command(adbPath, "devices")
-	output, err := cmd.Output()
+	devices, err := adbclient.Default.Devices(context.Background())
 	if err != nil {
 		return nil, err
 	}
+	return toAndroidDevices(devices), nil
+}
+
+// WatchDevices calls fn with the device list whenever adb reports a change, until ctx is cancelled
+// or the connection to the adb server is lost
+func WatchDevices(ctx context.Context, fn func([]AndroidDevice)) error {
+	return adbclient.Default.TrackDevices(ctx, func(devices []adbclient.Device) {
+		fn(toAndroidDevices(devices))
+	})
+}
 
+func toAndroidDevices(devices []adbclient.Device) []AndroidDevice {
 	var adbDevices []AndroidDevice
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "List of devices attached") {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) >= 2 {
-			switch parts[1] {
-			case "device":
-				adbDevices = append(adbDevices, AndroidDevice{
-					DeviceID: parts[0],
-					Status:   "connected",
-				})
-			case "offline":
-				adbDevices = append(adbDevices, AndroidDevice{
-					DeviceID: parts[0],
-					Status:   "offline",
-				})
-			case "unauthorized":
-				adbDevices = append(adbDevices, AndroidDevice{
-					DeviceID: parts[0],
-					Status:   "unauthorized",
-				})
-			}
+	for _, d := range devices {
+		switch d.State {
+		case "device":
+			adbDevices = append(adbDevices, AndroidDevice{
+				DeviceID: d.Serial,
+				Status:   "connected",
+			})
+		case "offline", "unauthorized":
+			adbDevices = append(adbDevices, AndroidDevice{
+				DeviceID: d.Serial,
+				Status:   d.State,
+			})
 		}
 	}
-	return adbDevices, nil
+	return adbDevices
 }
 
 // ConnectDevice connects to a device via TCP/IP
 func ConnectDevice(address string) error {
-	adbPath, err := utils.GetADBPath()
-	if err != nil {
-		return err
-	}
-	cmd := exec.Command(adbPath, "connect", address)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("adb connect failed: %v, output: %s", err, string(output))
-	}
-	if strings.Contains(string(output), "unable to connect") || strings.Contains(string(output), "failed to connect") {
-		return fmt.Errorf("adb connect failed: %s", string(output))
+	if err := adbclient.Default.Connect(context.Background(), address); err != nil {
+		return fmt.Errorf("adb connect failed: %w", err)
 	}
 	return nil
 }
 
 // PairDevice pairs with a device using a pairing code
 func PairDevice(address, code string) error {
-	adbPath, err := utils.GetADBPath()
-	if err != nil {
-		return err
+	if err := adbclient.Default.Pair(context.Background(), address, code); err != nil {
+		return fmt.Errorf("adb pair failed: %w", err)
 	}
-	cmd := exec.Command(adbPath, "pair", address, code)
-	output, err := cmd.CombinedOutput()
+	return nil
+}
+
+// Screenshot captures the screen as PNG with screencap, it works while scrcpy is streaming
+func Screenshot(deviceID string) ([]byte, error) {
+	output, err := adbclient.Default.ExecOut(context.Background(), deviceID, "screencap -p")
 	if err != nil {
-		return fmt.Errorf("adb pair failed: %v, output: %s", err, string(output))
+		return nil, fmt.Errorf("adb screencap failed: %w", err)
 	}
-	if !strings.Contains(string(output), "Successfully paired") {
-		return fmt.Errorf("adb pair failed: %s", string(output))
+	if !bytes.HasPrefix(output, []byte("\x89PNG")) {
+		return nil, fmt.Errorf("adb screencap failed: %s", strings.TrimSpace(string(output)))
 	}
-	return nil
+	return output, nil
 }
@@ -2,50 +2,81 @@ package android
 
 import (
 	"context"
-	"log"
-	"time"
+	"sync"
 
 	"github.com/grandcat/zeroconf"
 )
 
-// FindDevices 使用 mDNS 查找局域网内的 Android 设备
-func FindAndroidDevices() []AndroidDevice {
-	resolver, err := zeroconf.NewResolver(nil)
-	if err != nil {
-		log.Println("Failed to initialize resolver:", err)
-		return []AndroidDevice{}
-	}
-
-	entries := make(chan *zeroconf.ServiceEntry)
-	var devices []AndroidDevice
+// Android 11+ 无线调试发布的 mDNS 服务
+const (
+	MDNS_SERVICE_CONNECT = "_adb-tls-connect._tcp"
+	// 只在手机上打开“使用配对码配对”对话框时存在
+	MDNS_SERVICE_PAIRING = "_adb-tls-pairing._tcp"
+)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
-	defer cancel()
+// DiscoveredDevice 是局域网内发现的一个无线调试服务
+type DiscoveredDevice struct {
+	Name    string `json:"name"`    // mDNS 实例名，如 adb-R5CT1234-AbCdEf
+	Service string `json:"service"` // connect 或 pairing
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+}
 
-	// Discover ADB services
-	// _adb._tcp is the standard service type for ADB over Wi-Fi
-	if err := resolver.Browse(ctx, "_adb._tcp", "local.", entries); err != nil {
-		log.Println("Failed to browse:", err)
-		return []AndroidDevice{}
-	}
+// Serial 是 adb 通过 mDNS 自动连接时使用的设备序列号
+func (d DiscoveredDevice) Serial() string {
+	return d.Name + "." + MDNS_SERVICE_CONNECT
+}
 
-	for entry := range entries {
-		ip := ""
-		// 如果没有找到 192.168，则使用第一个 IPv4
-		if len(entry.AddrIPv4) > 0 {
-			ip = entry.AddrIPv4[0].String()
+// FindAndroidDevices 使用 mDNS 查找局域网内的 Android 无线调试服务，直到 ctx 结束
+func FindAndroidDevices(ctx context.Context) ([]DiscoveredDevice, error) {
+	var (
+		mu      sync.Mutex
+		devices []DiscoveredDevice
+		wg      sync.WaitGroup
+	)
+	browse := func(service, kind string) error {
+		// 一个 resolver 只能同时浏览一种服务，它们共用同一组 socket
+		resolver, err := zeroconf.NewResolver(nil)
+		if err != nil {
+			return err
 		}
-		// 最后尝试 IPv6
-		if ip == "" && len(entry.AddrIPv6) > 0 {
-			ip = entry.AddrIPv6[0].String()
+		entries := make(chan *zeroconf.ServiceEntry)
+		if err := resolver.Browse(ctx, service, "local.", entries); err != nil {
+			return err
 		}
-
-		devices = append(devices, AndroidDevice{
-			DeviceID: entry.Instance,
-			IP:       ip,
-			Port:     entry.Port,
-		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// ctx 结束后 entries 会被关闭
+			for entry := range entries {
+				ip := ""
+				// 优先使用 IPv4
+				if len(entry.AddrIPv4) > 0 {
+					ip = entry.AddrIPv4[0].String()
+				}
+				// 最后尝试 IPv6
+				if ip == "" && len(entry.AddrIPv6) > 0 {
+					ip = entry.AddrIPv6[0].String()
+				}
+				mu.Lock()
+				devices = append(devices, DiscoveredDevice{
+					Name:    entry.Instance,
+					Service: kind,
+					IP:      ip,
+					Port:    entry.Port,
+				})
+				mu.Unlock()
+			}
+		}()
+		return nil
 	}
 
-	return devices
+	if err := browse(MDNS_SERVICE_CONNECT, "connect"); err != nil {
+		return nil, err
+	}
+	if err := browse(MDNS_SERVICE_PAIRING, "pairing"); err != nil {
+		return nil, err
+	}
+	wg.Wait()
+	return devices, nil
 }
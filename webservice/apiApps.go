@@ -0,0 +1,84 @@
+package webservice
+
+import (
+	"log"
+	"webscreen/webservice/android"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 应用管理只支持 Android 设备，路径中的 :type 与截图接口保持一致
+func androidDeviceID(c *gin.Context) (string, bool) {
+	if c.Param("type") != DeviceTypeAndroid {
+		c.JSON(400, gin.H{"error": "Unsupported device type"})
+		return "", false
+	}
+	return c.Param("id"), true
+}
+
+// GET /api/device/:type/:id/apps?third_party=true
+func (wm *WebMaster) handleListApps(c *gin.Context) {
+	deviceID, ok := androidDeviceID(c)
+	if !ok {
+		return
+	}
+	packages, err := android.ListPackages(deviceID, c.Query("third_party") == "true")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"packages": packages})
+}
+
+// POST /api/device/:type/:id/apps/:package/:action
+// action: start, stop (force-stop), clear (清除数据)
+func (wm *WebMaster) handleAppAction(c *gin.Context) {
+	deviceID, ok := androidDeviceID(c)
+	if !ok {
+		return
+	}
+	pkg := c.Param("package")
+	var err error
+	switch action := c.Param("action"); action {
+	case "start":
+		err = android.StartApp(deviceID, pkg)
+	case "stop":
+		err = android.ForceStopApp(deviceID, pkg)
+	case "clear":
+		err = android.ClearAppData(deviceID, pkg)
+	default:
+		c.JSON(400, gin.H{"error": "Unsupported action: " + action})
+		return
+	}
+	if err != nil {
+		log.Printf("App action %s on %s failed: %v", c.Param("action"), pkg, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// POST /api/device/:type/:id/open
+// body: {"url": "..."} 或 {"action": "...", "data": "...", "package": "...", "component": "...", "category": "..."}
+func (wm *WebMaster) handleOpenIntent(c *gin.Context) {
+	deviceID, ok := androidDeviceID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		URL string `json:"url"`
+		android.Intent
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.URL != "" {
+		req.Data = req.URL
+	}
+	if err := android.StartIntent(deviceID, req.Intent); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
@@ -1,55 +1,68 @@
 package webservice
 
 import (
+	"errors"
+	"io"
+	"log"
+	"time"
+	"webscreen/sdriver/scrcpy"
 	"webscreen/webservice/android"
 	"webscreen/webservice/xvfb"
 
 	"github.com/gin-gonic/gin"
 )
 
+// handleListDevices 返回设备监听维护的设备列表，不会重新查询 adb 和 xvfb
+// 获取某种设备失败时列表中没有这种设备，原因在 errors 中按设备类型给出
 func (wm *WebMaster) handleListDevices(c *gin.Context) {
-	wm.devicesDiscoveredMu.RLock()
-	defer wm.devicesDiscoveredMu.RUnlock()
-
-	var devicesInfo []DeviceInfo
-	devices, err := android.GetDevices()
-	for _, d := range devices {
-		devicesInfo = append(devicesInfo, DeviceInfo{
-			Type:     d.GetType(),
-			DeviceID: d.GetDeviceID(),
-			IP:       d.GetIP(),
-			Port:     d.GetPort(),
-			Status:   d.GetStatus(),
-		})
-	}
-	xvfbDevices, err := xvfb.GetDevices()
-	for _, d := range xvfbDevices {
-		devicesInfo = append(devicesInfo, DeviceInfo{
-			Type:     d.GetType(),
-			DeviceID: d.GetDeviceID(),
-			IP:       d.GetIP(),
-			Port:     d.GetPort(),
-			Status:   d.GetStatus(),
-		})
-	}
-	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
-		return
+	resp := gin.H{"devices": wm.devices.list()}
+	if errs := wm.devices.errors(); len(errs) > 0 {
+		resp["errors"] = errs
 	}
-
-	c.JSON(200, gin.H{"devices": devicesInfo})
+	c.JSON(200, resp)
 }
 
-func (wm *WebMaster) handleListDevicesDiscoveried(c *gin.Context) {
-	wm.devicesDiscoveredMu.RLock()
-	defer wm.devicesDiscoveredMu.RUnlock()
+// handleDeviceEvents 以 Server-Sent Events 推送设备变化，
+// 连接后先发送 snapshot 事件包含完整列表，之后每次变化发送 device 事件
+func (wm *WebMaster) handleDeviceEvents(c *gin.Context) {
+	snapshot, events, unsubscribe := wm.devices.subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// nginx 默认会缓冲响应
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("snapshot", gin.H{"devices": snapshot})
+	c.Writer.Flush()
 
-	var devices []Device
-	for _, v := range wm.devicesDiscovered {
-		devices = append(devices, v)
+	ping := time.NewTicker(DEVICE_EVENT_PING_INTERVAL)
+	defer ping.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				// 事件积压被断开，浏览器重连后重新获取列表
+				return
+			}
+			c.SSEvent("device", ev)
+		case <-ping.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
 	}
+}
 
-	c.JSON(200, devices)
+// handleListDevicesDiscoveried 返回 mDNS 发现的无线调试服务
+// GET /api/device/discovery
+func (wm *WebMaster) handleListDevicesDiscoveried(c *gin.Context) {
+	if !wm.config.EnableAndroidDiscover {
+		c.JSON(200, gin.H{"enabled": false, "devices": []DiscoveredDeviceInfo{}})
+		return
+	}
+	c.JSON(200, gin.H{"enabled": true, "devices": wm.discoveredDevices()})
 }
 
 // HandleConnectDevice 处理连接设备的请求
@@ -59,11 +72,26 @@ func (wm *WebMaster) handleConnectDevice(c *gin.Context) {
 		DeviceType string `json:"device_type"`
 		IP         string `json:"ip"`
 		Port       string `json:"port"`
+		// xvfb 远程主机的 ssh 登录方式，port 为 ssh 端口
+		User     string `json:"user"`
+		Auth     string `json:"auth"`
+		KeyFile  string `json:"key_file"`
+		Password string `json:"password"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid request"})
 		return
 	}
+	if req.DeviceType == DeviceTypeXvfb {
+		wm.addXvfbHost(c, xvfb.RemoteHost{
+			IP:       req.IP,
+			User:     req.User,
+			Auth:     req.Auth,
+			KeyFile:  req.KeyFile,
+			Password: req.Password,
+		}, req.Port)
+		return
+	}
 	addr := req.IP
 	if req.Port != "" {
 		addr = addr + ":" + req.Port
@@ -82,6 +110,64 @@ func (wm *WebMaster) handleConnectDevice(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "connected"})
 }
 
+// handleScreenshot 返回设备当前画面的 PNG 截图，不需要建立推流
+// GET /api/device/:type/:id/screenshot[?display=:0]，display 只用于 xvfb
+func (wm *WebMaster) handleScreenshot(c *gin.Context) {
+	deviceID := c.Param("id")
+	var data []byte
+	var err error
+	switch c.Param("type") {
+	case DeviceTypeAndroid:
+		data, err = android.Screenshot(deviceID)
+	case DeviceTypeXvfb:
+		data, err = xvfb.Screenshot(deviceID, c.Query("display"))
+	default:
+		c.JSON(400, gin.H{"error": "Unsupported device type"})
+		return
+	}
+	if errors.Is(err, xvfb.ErrDisplayNotRunning) || errors.Is(err, xvfb.ErrPasswordRequired) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to take screenshot of %s: %v", deviceID, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(200, "image/png", data)
+}
+
+// handleListCameras 列出 Android 设备的摄像头，用于 video_source=camera 的 camera_id
+// GET /api/device/:type/:id/cameras
+func (wm *WebMaster) handleListCameras(c *gin.Context) {
+	deviceID, ok := androidDeviceID(c)
+	if !ok {
+		return
+	}
+	cameras, err := scrcpy.ListDeviceCameras(deviceID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"cameras": cameras})
+}
+
+// handleListDisplays 列出 Android 设备的显示器，用于 display_id
+// GET /api/device/:type/:id/displays
+func (wm *WebMaster) handleListDisplays(c *gin.Context) {
+	deviceID, ok := androidDeviceID(c)
+	if !ok {
+		return
+	}
+	displays, err := scrcpy.ListDeviceDisplays(deviceID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"displays": displays})
+}
+
 func (wm *WebMaster) handlePairDevice(c *gin.Context) {
 	var req struct {
 		DeviceType string `json:"device_type"`
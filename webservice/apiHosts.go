@@ -0,0 +1,65 @@
+package webservice
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+	"webscreen/webservice/xvfb"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 登记主机时 ssh 登录检查的总时长，包括密码认证
+const XVFB_HOST_CHECK_TIMEOUT = 15 * time.Second
+
+// addXvfbHost 登记远程 Linux 主机，先用 ssh 登录确认可用
+// POST /api/device/connect {"device_type": "xvfb", "ip", "port", "user", "auth", "key_file", "password"}
+func (wm *WebMaster) addXvfbHost(c *gin.Context, host xvfb.RemoteHost, port string) {
+	if port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			c.JSON(400, gin.H{"error": "Invalid ssh port: " + port})
+			return
+		}
+		host.SSHPort = p
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), XVFB_HOST_CHECK_TIMEOUT)
+	defer cancel()
+	added, err := xvfb.AddHost(ctx, host)
+	if err != nil {
+		if errors.Is(err, xvfb.ErrInvalidHost) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to add xvfb host %s@%s: %v", host.User, host.IP, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("Added xvfb host %s", added.ID)
+	wm.devices.pollXvfb()
+	c.JSON(200, gin.H{"status": "connected", "device_id": added.ID, "host": added})
+}
+
+// handleListHosts 返回登记的远程 Linux 主机，不含密码
+// GET /api/hosts
+func (wm *WebMaster) handleListHosts(c *gin.Context) {
+	c.JSON(200, gin.H{"hosts": xvfb.Hosts()})
+}
+
+// handleDeleteHost 删除远程 Linux 主机
+// DELETE /api/hosts/:id
+func (wm *WebMaster) handleDeleteHost(c *gin.Context) {
+	err := xvfb.RemoveHost(c.Param("id"))
+	if errors.Is(err, xvfb.ErrHostNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	wm.devices.pollXvfb()
+	c.JSON(200, gin.H{"status": "removed"})
+}
@@ -0,0 +1,50 @@
+package webservice
+
+import (
+	"log"
+	"time"
+	sagent "webscreen/streamAgent"
+	"webscreen/turnserver"
+
+	"github.com/gin-gonic/gin"
+)
+
+// iceServersFor 返回随屏幕 WebSocket 下发给浏览器的 ICE 服务器
+// 内置 TURN 的凭据为临时凭据，只发给通过 PIN 登录的会话，与 JWT 绑定并随 JWT 一起过期
+// 未启用 PIN 时任何人都能连上 WebSocket，不下发 TURN 凭据，避免成为开放中继
+func (wm *WebMaster) iceServersFor(c *gin.Context) []sagent.ICEServer {
+	servers := make([]sagent.ICEServer, 0, len(wm.config.WebRTC.ICEServers)+1)
+	for _, s := range wm.config.WebRTC.ICEServers {
+		// 配置中的 TURN 密码不下发给浏览器，只转发 STUN
+		if s.Username != "" || s.Credential != "" {
+			continue
+		}
+		servers = append(servers, s)
+	}
+	if wm.turn == nil || wm.pin == "" {
+		return servers
+	}
+	claims, ok := wm.requestClaims(c)
+	if !ok || claims.ExpiresAt == nil {
+		return servers
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return servers
+	}
+	username, password, err := wm.turn.Credentials(claims.ID, ttl)
+	if err != nil {
+		log.Println("Failed to generate TURN credentials:", err)
+		return servers
+	}
+	return append(servers, sagent.ICEServer{
+		URLs:       []string{wm.turn.URL()},
+		Username:   username,
+		Credential: password,
+	})
+}
+
+func (wm *WebMaster) SetTURNServer(s *turnserver.Server) {
+	log.Printf("Embedded TURN server enabled: %s", s.URL())
+	wm.turn = s
+}
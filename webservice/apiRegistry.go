@@ -0,0 +1,104 @@
+package webservice
+
+import (
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"webscreen/webservice/android"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegistryDeviceInfo 是登记的设备加上当前状态，离线时 status 为 offline
+type RegistryDeviceInfo struct {
+	RegistryEntry
+	Status string `json:"status"`
+}
+
+// handleListRegistry 返回登记过的所有设备，包括离线的
+// GET /api/registry
+func (wm *WebMaster) handleListRegistry(c *gin.Context) {
+	status := make(map[string]string)
+	for _, d := range wm.devices.list() {
+		status[registryKey(d.Type, d.DeviceID)] = d.Status
+	}
+	entries := wm.registry.list()
+	devices := make([]RegistryDeviceInfo, 0, len(entries))
+	for _, e := range entries {
+		s, ok := status[registryKey(e.DeviceType, e.DeviceID)]
+		if !ok {
+			s = "offline"
+		}
+		devices = append(devices, RegistryDeviceInfo{RegistryEntry: e, Status: s})
+	}
+	c.JSON(200, gin.H{"devices": devices})
+}
+
+// handleUpdateRegistry 修改设备的名称、标签、地址或默认驱动参数
+// PUT /api/registry/:type/:id
+func (wm *WebMaster) handleUpdateRegistry(c *gin.Context) {
+	var req RegistryUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	deviceType := c.Param("type")
+	if deviceType != DeviceTypeAndroid && deviceType != DeviceTypeXvfb {
+		c.JSON(400, gin.H{"error": "Unsupported device type"})
+		return
+	}
+	entry := wm.registry.update(deviceType, c.Param("id"), req)
+	c.JSON(200, gin.H{"device": entry})
+}
+
+// handleDeleteRegistry 删除登记，设备再次连接时会重新登记
+// DELETE /api/registry/:type/:id
+func (wm *WebMaster) handleDeleteRegistry(c *gin.Context) {
+	if !wm.registry.remove(c.Param("type"), c.Param("id")) {
+		c.JSON(404, gin.H{"error": "Device not registered"})
+		return
+	}
+	c.JSON(200, gin.H{"status": "removed"})
+}
+
+// handleReconnectDevice 用登记或 mDNS 发现的地址重新连接离线的无线设备
+// POST /api/device/:type/:id/reconnect
+func (wm *WebMaster) handleReconnectDevice(c *gin.Context) {
+	deviceID, ok := androidDeviceID(c)
+	if !ok {
+		return
+	}
+	entry, _ := wm.registry.get(DeviceTypeAndroid, deviceID)
+
+	// 无线调试每次开启都会换端口，优先使用 mDNS 发现的当前地址
+	var addrs []string
+	wm.devicesDiscoveredMu.RLock()
+	for _, d := range wm.devicesDiscovered {
+		if d.Service == "connect" && (d.Serial() == deviceID || (entry.IP != "" && d.IP == entry.IP)) {
+			addrs = append(addrs, net.JoinHostPort(d.IP, strconv.Itoa(d.Port)))
+		}
+	}
+	wm.devicesDiscoveredMu.RUnlock()
+	if entry.IP != "" && entry.Port > 0 {
+		addrs = append(addrs, net.JoinHostPort(entry.IP, strconv.Itoa(entry.Port)))
+	}
+	if _, _, err := net.SplitHostPort(deviceID); err == nil {
+		addrs = append(addrs, deviceID)
+	}
+	addrs = slices.Compact(addrs)
+	if len(addrs) == 0 {
+		c.JSON(400, gin.H{"error": "No network address known for " + deviceID})
+		return
+	}
+
+	var err error
+	for _, addr := range addrs {
+		if err = android.ConnectDevice(addr); err == nil {
+			c.JSON(200, gin.H{"status": "connected", "address": addr})
+			return
+		}
+		log.Printf("Reconnect %s via %s failed: %v", deviceID, addr, err)
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,200 @@
+package webservice
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"webscreen/recorder"
+	sagent "webscreen/streamAgent"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionInfo struct {
+	SessionID string         `json:"session_id"`
+	Viewers   int            `json:"viewers"`
+	Recording bool           `json:"recording"`
+	Record    *recorder.Info `json:"record,omitempty"`
+}
+
+type recordingFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+func (wm *WebMaster) handleListSessions(c *gin.Context) {
+	wm.screenSessionsMu.Lock()
+	sessions := make([]*ScreenSession, 0, len(wm.ScreenSessions))
+	for _, s := range wm.ScreenSessions {
+		sessions = append(sessions, s)
+	}
+	wm.screenSessionsMu.Unlock()
+
+	infos := make([]sessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		info := sessionInfo{SessionID: s.SessionID, Viewers: s.ViewerCount()}
+		if rec, ok := s.Agent.RecordingInfo(); ok {
+			info.Recording = true
+			info.Record = &rec
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].SessionID < infos[j].SessionID })
+	c.JSON(200, gin.H{"sessions": infos})
+}
+
+// POST /api/sessions/:id/record/start
+// session 不存在时可以在请求体中带上连接参数 (与 WebSocket 首条消息相同)，不需要浏览器即可开始录制
+func (wm *WebMaster) handleStartRecording(c *gin.Context) {
+	sessionID := c.Param("id")
+	session, err := wm.sessionForRecording(c, sessionID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	path := filepath.Join(wm.config.RecordDir, recordingFileName(sessionID))
+	err = session.Agent.StartRecording(path)
+	// 开始录制失败时，只为录制创建的 session 随之关闭
+	wm.releaseScreenSession(session)
+	if err != nil {
+		log.Printf("Failed to start recording for session %s: %v", sessionID, err)
+		if errors.Is(err, sagent.ErrAlreadyRecording) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	info, _ := session.Agent.RecordingInfo()
+	c.JSON(200, gin.H{"status": "recording", "record": info})
+}
+
+// sessionForRecording 返回已有的 session，或按请求体中的连接参数创建一个无浏览器的 session
+// 返回 session 时调用方需要调用 releaseScreenSession
+func (wm *WebMaster) sessionForRecording(c *gin.Context, sessionID string) (*ScreenSession, error) {
+	wm.screenSessionsMu.Lock()
+	session, exists := wm.ScreenSessions[sessionID]
+	exists = exists && !session.closed.Load()
+	if exists {
+		session.joining++
+	}
+	wm.screenSessionsMu.Unlock()
+	if exists {
+		<-session.ready
+		if session.initErr != nil {
+			wm.releaseScreenSession(session)
+			return nil, session.initErr
+		}
+		return session, nil
+	}
+
+	config := sagent.AgentConfig{}
+	if err := c.ShouldBindJSON(&config); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("session not found")
+		}
+		return nil, err
+	}
+	if screenSessionID(config) != sessionID {
+		return nil, errors.New("session id does not match connection options")
+	}
+	session, isNew, err := wm.getOrCreateScreenSession(sessionID, config)
+	if err != nil {
+		return nil, err
+	}
+	if !isNew {
+		<-session.ready
+		if session.initErr != nil {
+			wm.releaseScreenSession(session)
+			return nil, session.initErr
+		}
+		return session, nil
+	}
+	session.initErr = session.Agent.InitDriverHeadless()
+	close(session.ready)
+	if session.initErr != nil {
+		log.Println("Failed to initialize driver:", session.initErr)
+		wm.removeScreenSession(session)
+		return nil, session.initErr
+	}
+	go wm.listenEventFeedback(session)
+	session.Agent.StartStreaming()
+	return session, nil
+}
+
+// POST /api/sessions/:id/record/stop
+func (wm *WebMaster) handleStopRecording(c *gin.Context) {
+	sessionID := c.Param("id")
+	wm.screenSessionsMu.Lock()
+	session, exists := wm.ScreenSessions[sessionID]
+	wm.screenSessionsMu.Unlock()
+	if !exists {
+		c.JSON(404, gin.H{"error": "session not found"})
+		return
+	}
+	info, err := session.Agent.StopRecording()
+	// 没有浏览器在看的 session 只为录制而存在，录制结束后一并关闭
+	wm.removeIdleScreenSession(session)
+	if errors.Is(err, sagent.ErrNotRecording) {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error(), "record": info})
+		return
+	}
+	c.JSON(200, gin.H{"status": "stopped", "record": info})
+}
+
+func (wm *WebMaster) handleListRecordings(c *gin.Context) {
+	entries, err := os.ReadDir(wm.config.RecordDir)
+	if err != nil && !os.IsNotExist(err) {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	files := make([]recordingFile, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".mkv" {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, recordingFile{Name: e.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	c.JSON(200, gin.H{"recordings": files})
+}
+
+func (wm *WebMaster) handleDownloadRecording(c *gin.Context) {
+	name := c.Param("name")
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".mkv" {
+		c.JSON(400, gin.H{"error": "Invalid recording name"})
+		return
+	}
+	path := filepath.Join(wm.config.RecordDir, name)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(404, gin.H{"error": "recording not found"})
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// recordingFileName 由 session ID 和开始时间组成，去掉不适合出现在文件名中的字符
+func recordingFileName(sessionID string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, sessionID)
+	return safe + "_" + time.Now().Format("20060102_150405") + ".mkv"
+}
@@ -0,0 +1,184 @@
+package webservice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"webscreen/sdriver/scrcpy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 上传文件推送到设备的目录
+const UPLOAD_REMOTE_DIR = "/sdcard/Download"
+
+// 上传进度每增加多少百分比通知一次浏览器
+const UPLOAD_PROGRESS_STEP = 10
+
+// 单次上传的请求体大小上限
+const UPLOAD_MAX_SIZE int64 = 4 << 30
+
+// handleUpload 接收浏览器上传的文件，APK 直接安装，其他文件推送到 /sdcard/Download
+// 进度和安装结果通过该设备的屏幕 WebSocket 通知浏览器 (stage: file_transfer)
+// POST /api/device/:type/:id/upload (multipart, 字段 size 为文件字节数，须在 file 之前)
+func (wm *WebMaster) handleUpload(c *gin.Context) {
+	deviceType, deviceID := c.Param("type"), c.Param("id")
+	if deviceType != DeviceTypeAndroid {
+		c.JSON(400, gin.H{"error": "Unsupported device type"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, UPLOAD_MAX_SIZE)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	var part io.ReadCloser
+	var name string
+	// 文件大小由浏览器单独给出，Content-Length 包含 multipart 开销，分块上传时为 -1
+	var size int64
+	for {
+		p, err := reader.NextPart()
+		if err != nil {
+			if errors.As(err, new(*http.MaxBytesError)) {
+				c.JSON(413, gin.H{"error": "File too large"})
+				return
+			}
+			c.JSON(400, gin.H{"error": "Missing file"})
+			return
+		}
+		if p.FormName() == "file" {
+			part, name = p, path.Base(strings.ReplaceAll(p.FileName(), "\\", "/"))
+			break
+		}
+		if p.FormName() == "size" {
+			value, _ := io.ReadAll(io.LimitReader(p, 20))
+			size, _ = strconv.ParseInt(strings.TrimSpace(string(value)), 10, 64)
+		}
+		p.Close()
+	}
+	defer part.Close()
+	if name == "" || name == "." || name == "/" || strings.HasPrefix(name, ".") {
+		c.JSON(400, gin.H{"error": "Invalid file name"})
+		return
+	}
+	notify := func(phase string, progress int, message string) {
+		wm.notifyDevice(deviceType, deviceID, gin.H{"status": "ok", "stage": "file_transfer", "name": name, "phase": phase, "progress": progress, "message": message})
+	}
+	fail := func(code int, err error) {
+		log.Printf("Upload %s to %s failed: %v", name, deviceID, err)
+		wm.notifyDevice(deviceType, deviceID, gin.H{"status": "error", "stage": "file_transfer", "name": name, "message": err.Error()})
+		c.JSON(code, gin.H{"error": err.Error()})
+	}
+	if size > UPLOAD_MAX_SIZE {
+		fail(413, fmt.Errorf("file too large: %d bytes, limit %d", size, UPLOAD_MAX_SIZE))
+		return
+	}
+
+	// 先完整保存到本地，adb push 需要文件路径
+	tmp, err := os.CreateTemp("", "webscreen_upload_*")
+	if err != nil {
+		fail(500, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	uploading := &progressTracker{notify: func(p int) { notify("uploading", p, "") }}
+	_, err = io.Copy(&progressWriter{w: tmp, total: size, tracker: uploading}, part)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if errors.As(err, new(*http.MaxBytesError)) {
+			fail(413, fmt.Errorf("file too large, limit %d bytes", UPLOAD_MAX_SIZE))
+			return
+		}
+		fail(500, fmt.Errorf("receive file: %w", err))
+		return
+	}
+
+	// 推送进度来自 sync SEND 阶段已发送的字节数
+	adbClient := scrcpy.NewADBClient(deviceID, "", c.Request.Context())
+	pushing := &progressTracker{notify: func(p int) { notify("pushing", p, "") }}
+	notify("pushing", 0, "")
+	if strings.EqualFold(path.Ext(name), ".apk") {
+		output, err := adbClient.InstallAPK(tmp.Name(), func(sent, total int64) {
+			pushing.update(sent, total)
+			if sent == total {
+				notify("installing", 100, "")
+			}
+		})
+		if err != nil {
+			fail(500, err)
+			return
+		}
+		notify("done", 100, output)
+		c.JSON(200, gin.H{"status": "installed", "output": output})
+		return
+	}
+	remotePath := UPLOAD_REMOTE_DIR + "/" + name
+	if err := adbClient.Push(tmp.Name(), remotePath, pushing.update); err != nil {
+		fail(500, err)
+		return
+	}
+	notify("done", 100, remotePath)
+	c.JSON(200, gin.H{"status": "pushed", "path": remotePath})
+}
+
+// notifyDevice 把 JSON 消息发给正在查看该设备的所有浏览器
+func (wm *WebMaster) notifyDevice(deviceType, deviceID string, msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	wm.screenSessionsMu.Lock()
+	sessions := make([]*ScreenSession, 0, len(wm.ScreenSessions))
+	for _, s := range wm.ScreenSessions {
+		if t, id := s.Agent.Device(); t == deviceType && id == deviceID {
+			sessions = append(sessions, s)
+		}
+	}
+	wm.screenSessionsMu.Unlock()
+	for _, s := range sessions {
+		s.Broadcast(websocket.TextMessage, data)
+	}
+}
+
+// progressTracker 每增加 UPLOAD_PROGRESS_STEP 个百分点回调一次，total 未知时不回调
+type progressTracker struct {
+	last   int
+	notify func(progress int)
+}
+
+func (pt *progressTracker) update(done, total int64) {
+	if total <= 0 {
+		return
+	}
+	progress := int(min(done*100/total, 100))
+	if progress >= pt.last+UPLOAD_PROGRESS_STEP {
+		pt.last = progress
+		pt.notify(progress)
+	}
+}
+
+// progressWriter 统计写入的字节数并交给 progressTracker
+type progressWriter struct {
+	w       io.Writer
+	total   int64
+	written int64
+	tracker *progressTracker
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	pw.tracker.update(pw.written, pw.total)
+	return n, err
+}
@@ -2,6 +2,7 @@ package webservice
 
 const (
 	DeviceTypeAndroid = "android"
+	DeviceTypeXvfb    = "xvfb"
 )
 
 type Device interface {
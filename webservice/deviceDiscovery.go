@@ -1,27 +1,102 @@
 package webservice
 
-// import (
-// 	"fmt"
-// 	"time"
-// 	"webscreen/webservice/android"
-// )
-
-// func (wm *WebMaster) AndroidDevicesDiscovery() {
-// 	for {
-// 		time.Sleep(2 * time.Second)
-// 		if wm.pauseDiscovery {
-// 			continue
-// 		}
-// 		androidDevices := android.FindAndroidDevices()
-
-// 		wm.devicesDiscoveredMu.Lock()
-
-// 		for _, d := range androidDevices {
-// 			id := fmt.Sprintf("%s:%s:%d", d.GetType(), d.GetDeviceID(), d.GetPort())
-// 			wm.devicesDiscovered[id] = d
-// 		}
-// 		// log.Printf("Discovered Android device: %+v\n", androidDevices)
-
-// 		wm.devicesDiscoveredMu.Unlock()
-// 	}
-// }
+import (
+	"cmp"
+	"context"
+	"log"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+	"webscreen/webservice/android"
+)
+
+const (
+	// 每轮 mDNS 浏览的时长
+	DISCOVERY_SCAN_TIME = 3 * time.Second
+	// 两轮浏览之间的间隔
+	DISCOVERY_INTERVAL = 5 * time.Second
+	// 超过这个时间没有再次发现的服务会被移除，mDNS 不保证通知服务下线
+	DISCOVERY_EXPIRE = 20 * time.Second
+)
+
+type discoveredDevice struct {
+	android.DiscoveredDevice
+	lastSeen time.Time
+}
+
+// DiscoveredDeviceInfo 是 /api/device/discovery 返回的一项
+type DiscoveredDeviceInfo struct {
+	android.DiscoveredDevice
+	// connect 服务对应的设备已经连接到 adb
+	Connected bool `json:"connected"`
+}
+
+// AndroidDevicesDiscovery 在后台浏览局域网内的无线调试服务，直到 ctx 取消
+func (wm *WebMaster) AndroidDevicesDiscovery(ctx context.Context) {
+	log.Println("Android mDNS discovery started")
+	for {
+		scanCtx, cancel := context.WithTimeout(ctx, DISCOVERY_SCAN_TIME)
+		devices, err := android.FindAndroidDevices(scanCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Android mDNS discovery failed: %v", err)
+		} else {
+			wm.updateDiscovered(devices)
+		}
+		select {
+		case <-time.After(DISCOVERY_INTERVAL):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (wm *WebMaster) updateDiscovered(devices []android.DiscoveredDevice) {
+	wm.devicesDiscoveredMu.Lock()
+	defer wm.devicesDiscoveredMu.Unlock()
+
+	now := time.Now()
+	for _, d := range devices {
+		key := d.Service + "/" + d.Name
+		if _, ok := wm.devicesDiscovered[key]; !ok {
+			log.Printf("Discovered Android %s service %s at %s:%d", d.Service, d.Name, d.IP, d.Port)
+		}
+		wm.devicesDiscovered[key] = discoveredDevice{DiscoveredDevice: d, lastSeen: now}
+	}
+	for key, d := range wm.devicesDiscovered {
+		if now.Sub(d.lastSeen) > DISCOVERY_EXPIRE {
+			delete(wm.devicesDiscovered, key)
+		}
+	}
+}
+
+// discoveredDevices 返回发现的服务，并标记已经连接到 adb 的设备
+func (wm *WebMaster) discoveredDevices() []DiscoveredDeviceInfo {
+	connected := make(map[string]bool)
+	for _, d := range wm.devices.list() {
+		if d.Type == DeviceTypeAndroid && d.Status == "connected" {
+			connected[d.DeviceID] = true
+		}
+	}
+
+	wm.devicesDiscoveredMu.RLock()
+	defer wm.devicesDiscoveredMu.RUnlock()
+	devices := make([]DiscoveredDeviceInfo, 0, len(wm.devicesDiscovered))
+	for _, d := range wm.devicesDiscovered {
+		info := DiscoveredDeviceInfo{DiscoveredDevice: d.DiscoveredDevice}
+		if d.Service == "connect" {
+			// adb connect 使用 ip:port，adb 自动连接时使用 mDNS 名称
+			addr := net.JoinHostPort(d.IP, strconv.Itoa(d.Port))
+			info.Connected = connected[addr] || connected[d.Serial()]
+		}
+		devices = append(devices, info)
+	}
+	slices.SortFunc(devices, func(a, b DiscoveredDeviceInfo) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Service, b.Service))
+	})
+	return devices
+}
@@ -0,0 +1,246 @@
+package webservice
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	sagent "webscreen/streamAgent"
+)
+
+const REGISTRY_FILE_DEFAULT = "devices.json"
+
+// RegistryEntry 是登记过的设备，设备离线后仍然保留
+type RegistryEntry struct {
+	DeviceType string   `json:"device_type"`
+	DeviceID   string   `json:"device_id"`
+	Name       string   `json:"name"`
+	Tags       []string `json:"tags"`
+	// 网络设备的地址，用于离线后重新连接
+	IP   string `json:"ip"`
+	Port int    `json:"port"`
+	// 浏览器没有指定的驱动参数使用这里的默认值
+	DriverConfig map[string]string `json:"driver_config"`
+	LastSeen     time.Time         `json:"last_seen"`
+}
+
+// RegistryUpdate 是 PUT /api/registry/:type/:id 的请求，省略的字段保持不变
+type RegistryUpdate struct {
+	Name         *string            `json:"name"`
+	Tags         *[]string          `json:"tags"`
+	IP           *string            `json:"ip"`
+	Port         *int               `json:"port"`
+	DriverConfig *map[string]string `json:"driver_config"`
+}
+
+// registryFile 是登记文件的格式
+type registryFile struct {
+	Devices []RegistryEntry `json:"devices"`
+}
+
+// deviceRegistry 把登记的设备保存在 JSON 文件中
+type deviceRegistry struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*RegistryEntry
+}
+
+func registryKey(deviceType, deviceID string) string {
+	return deviceType + "/" + deviceID
+}
+
+// loadDeviceRegistry 读取 path，文件不存在时从空列表开始
+func loadDeviceRegistry(path string) *deviceRegistry {
+	r := &deviceRegistry{path: path, entries: make(map[string]*RegistryEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r
+	}
+	if err != nil {
+		log.Printf("Failed to read device registry %s: %v", path, err)
+		return r
+	}
+	var file registryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		// 保留损坏的文件，避免下次保存时覆盖
+		log.Printf("Failed to parse device registry %s, moving it to %s.bak: %v", path, path, err)
+		os.Rename(path, path+".bak")
+		return r
+	}
+	for _, e := range file.Devices {
+		if e.Tags == nil {
+			e.Tags = []string{}
+		}
+		r.entries[registryKey(e.DeviceType, e.DeviceID)] = &e
+	}
+	log.Printf("Loaded %d devices from %s", len(r.entries), path)
+	return r
+}
+
+// saveLocked 先写临时文件再重命名，避免写到一半时退出损坏文件
+func (r *deviceRegistry) saveLocked() {
+	data, err := json.MarshalIndent(registryFile{Devices: r.listLocked()}, "", "  ")
+	if err != nil {
+		log.Printf("Failed to encode device registry: %v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		log.Printf("Failed to save device registry: %v", err)
+		return
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), r.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Failed to save device registry: %v", err)
+	}
+}
+
+func (r *deviceRegistry) listLocked() []RegistryEntry {
+	entries := make([]RegistryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, *e)
+	}
+	slices.SortFunc(entries, func(a, b RegistryEntry) int {
+		return cmp.Or(cmp.Compare(a.DeviceType, b.DeviceType), cmp.Compare(a.DeviceID, b.DeviceID))
+	})
+	return entries
+}
+
+func (r *deviceRegistry) list() []RegistryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listLocked()
+}
+
+func (r *deviceRegistry) get(deviceType, deviceID string) (RegistryEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[registryKey(deviceType, deviceID)]
+	if !ok {
+		return RegistryEntry{}, false
+	}
+	return *e, true
+}
+
+// seen 登记 adb 或 xvfb 报告的设备，并记录最后在线时间
+func (r *deviceRegistry) seen(devices []DeviceInfo, now time.Time) {
+	if len(devices) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range devices {
+		key := registryKey(d.Type, d.DeviceID)
+		e, ok := r.entries[key]
+		if !ok {
+			e = &RegistryEntry{DeviceType: d.Type, DeviceID: d.DeviceID, Tags: []string{}}
+			r.entries[key] = e
+			log.Printf("Registered new device %s", key)
+		}
+		e.LastSeen = now
+		if d.IP != "" {
+			e.IP, e.Port = d.IP, d.Port
+		} else if host, port, err := net.SplitHostPort(d.DeviceID); err == nil {
+			// adb connect 连接的设备序列号就是 ip:port
+			e.IP = host
+			e.Port, _ = strconv.Atoi(port)
+		}
+	}
+	r.saveLocked()
+}
+
+// update 修改登记信息，设备没有登记过时新建
+func (r *deviceRegistry) update(deviceType, deviceID string, u RegistryUpdate) RegistryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(deviceType, deviceID)
+	e, ok := r.entries[key]
+	if !ok {
+		e = &RegistryEntry{DeviceType: deviceType, DeviceID: deviceID, Tags: []string{}}
+		r.entries[key] = e
+	}
+	if u.Name != nil {
+		e.Name = *u.Name
+	}
+	if u.Tags != nil {
+		e.Tags = slices.DeleteFunc(slices.Clone(*u.Tags), func(t string) bool { return t == "" })
+	}
+	if u.IP != nil {
+		e.IP = *u.IP
+	}
+	if u.Port != nil {
+		e.Port = *u.Port
+	}
+	if u.DriverConfig != nil {
+		e.DriverConfig = maps.Clone(*u.DriverConfig)
+	}
+	r.saveLocked()
+	return *e
+}
+
+func (r *deviceRegistry) remove(deviceType, deviceID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := registryKey(deviceType, deviceID)
+	if _, ok := r.entries[key]; !ok {
+		return false
+	}
+	delete(r.entries, key)
+	r.saveLocked()
+	return true
+}
+
+// applyDefaults 用登记的驱动参数补全浏览器没有指定或留空的参数
+func (r *deviceRegistry) applyDefaults(config *sagent.AgentConfig) {
+	e, ok := r.get(config.DeviceType, config.DeviceID)
+	if !ok || len(e.DriverConfig) == 0 {
+		return
+	}
+	if config.DriverConfig == nil {
+		config.DriverConfig = make(map[string]string)
+	}
+	for k, v := range e.DriverConfig {
+		if config.DriverConfig[k] == "" {
+			config.DriverConfig[k] = v
+		}
+	}
+}
+
+// track 跟随设备列表的变化更新最后在线时间，直到 ctx 取消
+func (r *deviceRegistry) track(ctx context.Context, w *deviceWatcher) {
+	for {
+		snapshot, events, unsubscribe := w.subscribe()
+		r.seen(snapshot, time.Now())
+		for open := true; open; {
+			select {
+			case ev, ok := <-events:
+				// 事件积压被断开时重新订阅
+				open = ok
+				if ok {
+					// removed 事件记录的是离线的时间
+					r.seen([]DeviceInfo{ev.Device}, time.Now())
+				}
+			case <-ctx.Done():
+				unsubscribe()
+				return
+			}
+		}
+		unsubscribe()
+	}
+}
@@ -0,0 +1,215 @@
+package webservice
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+	"webscreen/webservice/android"
+	"webscreen/webservice/xvfb"
+)
+
+const (
+	// adb server 断开后重新订阅的间隔
+	ADB_TRACK_RETRY_INTERVAL = 2 * time.Second
+	// xvfb 没有变化通知，定时检查
+	XVFB_POLL_INTERVAL = 5 * time.Second
+	// 浏览器处理不过来时缓存的事件数，超过后断开让它重连
+	DEVICE_EVENT_BUFFER = 32
+	// 没有事件时定时发送注释，防止代理断开空闲连接
+	DEVICE_EVENT_PING_INTERVAL = 30 * time.Second
+)
+
+// DeviceEvent 是设备列表的一次变化
+type DeviceEvent struct {
+	Event  string     `json:"event"` // added, removed, changed
+	Device DeviceInfo `json:"device"`
+	// changed 事件中变化之前的状态，如 unauthorized -> connected
+	PreviousStatus string `json:"previous_status,omitempty"`
+}
+
+// deviceWatcher 跟踪 adb 和 xvfb 的设备列表，把变化推送给订阅者
+type deviceWatcher struct {
+	mu          sync.Mutex
+	devices     map[string]DeviceInfo
+	subscribers map[chan DeviceEvent]struct{}
+	// 每种设备最近一次获取列表失败的原因，成功后清除
+	errs map[string]string
+}
+
+func newDeviceWatcher() *deviceWatcher {
+	return &deviceWatcher{
+		devices:     make(map[string]DeviceInfo),
+		subscribers: make(map[chan DeviceEvent]struct{}),
+		errs:        make(map[string]string),
+	}
+}
+
+func deviceInfo(d Device) DeviceInfo {
+	return DeviceInfo{
+		Type:     d.GetType(),
+		DeviceID: d.GetDeviceID(),
+		IP:       d.GetIP(),
+		Port:     d.GetPort(),
+		Status:   d.GetStatus(),
+	}
+}
+
+func deviceKey(d DeviceInfo) string {
+	return d.Type + "/" + d.DeviceID
+}
+
+// run 订阅 adb 的 host:track-devices 并定时检查 xvfb，直到 ctx 取消
+func (w *deviceWatcher) run(ctx context.Context) {
+	go func() {
+		for {
+			err := android.WatchDevices(ctx, func(devices []android.AndroidDevice) {
+				infos := make([]DeviceInfo, 0, len(devices))
+				for _, d := range devices {
+					infos = append(infos, deviceInfo(d))
+				}
+				w.setError(DeviceTypeAndroid, nil)
+				w.update(DeviceTypeAndroid, infos)
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Device watcher: lost adb server: %v", err)
+			w.setError(DeviceTypeAndroid, err)
+			// adb server 不可用时视为没有设备
+			w.update(DeviceTypeAndroid, nil)
+			select {
+			case <-time.After(ADB_TRACK_RETRY_INTERVAL):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(XVFB_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		w.pollXvfb()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pollXvfb 检查本机和远程主机，登记或删除远程主机后也会立即调用
+func (w *deviceWatcher) pollXvfb() {
+	devices, err := xvfb.GetDevices()
+	if err != nil {
+		log.Printf("Device watcher: list xvfb devices failed: %v", err)
+		w.setError(DeviceTypeXvfb, err)
+		return
+	}
+	w.setError(DeviceTypeXvfb, nil)
+	infos := make([]DeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		infos = append(infos, deviceInfo(d))
+	}
+	w.update(DeviceTypeXvfb, infos)
+}
+
+// update 用 deviceType 类型设备的最新列表替换旧列表，并通知变化
+func (w *deviceWatcher) update(deviceType string, devices []DeviceInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []DeviceEvent
+	seen := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		key := deviceKey(d)
+		seen[key] = true
+		old, ok := w.devices[key]
+		switch {
+		case !ok:
+			events = append(events, DeviceEvent{Event: "added", Device: d})
+		case old != d:
+			events = append(events, DeviceEvent{Event: "changed", Device: d, PreviousStatus: old.Status})
+		default:
+			continue
+		}
+		w.devices[key] = d
+	}
+	for key, d := range w.devices {
+		if d.Type == deviceType && !seen[key] {
+			delete(w.devices, key)
+			events = append(events, DeviceEvent{Event: "removed", Device: d})
+		}
+	}
+
+	for _, ev := range events {
+		log.Printf("Device %s: %s %s (%s)", ev.Event, ev.Device.Type, ev.Device.DeviceID, ev.Device.Status)
+		for ch := range w.subscribers {
+			select {
+			case ch <- ev:
+			default:
+				// 订阅者跟不上，断开后它会重新获取完整列表
+				delete(w.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// list 返回当前的设备列表
+func (w *deviceWatcher) list() []DeviceInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.snapshotLocked()
+}
+
+// setError 记录 deviceType 类型设备获取列表失败的原因，err 为 nil 时清除
+func (w *deviceWatcher) setError(deviceType string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil {
+		delete(w.errs, deviceType)
+		return
+	}
+	w.errs[deviceType] = err.Error()
+}
+
+// errors 返回获取列表失败的设备类型和原因
+func (w *deviceWatcher) errors() map[string]string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return maps.Clone(w.errs)
+}
+
+func (w *deviceWatcher) snapshotLocked() []DeviceInfo {
+	snapshot := make([]DeviceInfo, 0, len(w.devices))
+	for _, d := range w.devices {
+		snapshot = append(snapshot, d)
+	}
+	slices.SortFunc(snapshot, func(a, b DeviceInfo) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.DeviceID, b.DeviceID))
+	})
+	return snapshot
+}
+
+// subscribe 返回当前的设备列表和之后的变化，调用 unsubscribe 停止接收
+func (w *deviceWatcher) subscribe() ([]DeviceInfo, <-chan DeviceEvent, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	snapshot := w.snapshotLocked()
+	ch := make(chan DeviceEvent, DEVICE_EVENT_BUFFER)
+	w.subscribers[ch] = struct{}{}
+	unsubscribe := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subscribers[ch]; ok {
+			delete(w.subscribers, ch)
+			close(ch)
+		}
+	}
+	return snapshot, ch, unsubscribe
+}
@@ -1,12 +1,18 @@
 package webservice
 
 import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 	sagent "webscreen/streamAgent"
+	"webscreen/webservice/xvfb"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
 )
 
 var upgrader = websocket.Upgrader{
@@ -24,6 +30,8 @@ func (wm *WebMaster) handleScreenWS(c *gin.Context) {
 		log.Println("Failed to upgrade to websocket:", err)
 		return
 	}
+	// ICE 服务器和 TURN 临时凭据随信令下发，浏览器收到后再创建 Offer
+	conn.WriteJSON(map[string]any{"status": "ok", "ice_servers": wm.iceServersFor(c), "stage": "ice_servers"})
 	// deviceType := c.Param("device_type")
 	// deviceID := c.Param("device_id")
 	// deviceIP := c.Param("device_ip")
@@ -42,31 +50,41 @@ func (wm *WebMaster) handleScreenWS(c *gin.Context) {
 	// 	// return
 	// }
 	// Create a unique session ID
-	sessionID := config.DeviceType + "_" + config.DeviceID + "_" + config.DeviceIP + "_" + config.DevicePort
-	if _, exists := wm.ScreenSessions[sessionID]; exists {
-		wm.removeScreenSession(sessionID)
-	}
-	log.Printf("New WebSocket connection for session: %s", sessionID)
-	session := wm.ScreenSessions[sessionID]
-	session.WSConn = conn
-	agent, err := sagent.NewAgent(config)
+	// 同一设备的多个浏览器共享一个 session，不再互相踢掉
+	sessionID := screenSessionID(config)
+	session, isNew, err := wm.getOrCreateScreenSession(sessionID, config)
 	if err != nil {
 		log.Println("Failed to create agent:", err)
 		conn.WriteJSON(map[string]any{"status": "error", "message": err.Error(), "stage": "webrtc_init"})
 		conn.Close()
 		return
 	}
-	session.Agent = agent
-	wm.ScreenSessions[sessionID] = session
-	finalSDP := agent.CreateWebRTCConnection(string(config.SDP))
+	agent := session.Agent
+
+	// 角色由服务端分配，没有控制者时成为控制者，之后的控制权只能由控制者交接
+	viewer := &ScreenViewer{ID: generateViewerID(), WSConn: conn, joinedAt: time.Now()}
+	role := session.claimRole(viewer.ID, config.Role)
+	log.Printf("New WebSocket connection for session: %s, viewer: %s (%s)", sessionID, viewer.ID, role)
+
+	var onCandidate func(*webrtc.ICECandidateInit)
+	if config.TrickleICE {
+		onCandidate = viewer.SendCandidate
+	}
+	finalSDP := agent.CreateWebRTCConnection(viewer.ID, role, string(config.SDP), onCandidate)
+	// Viewer 已经加入 Agent，之后由 removeViewer 关闭空闲的 session
+	wm.releaseScreenSession(session)
 	// log.Println("Final SDP generated", finalSDP)
 	if finalSDP == "" {
 		log.Println("Failed to create WebRTC connection")
 		conn.WriteJSON(map[string]any{"status": "error", "message": "Failed to create WebRTC connection", "stage": "webrtc_init"})
 		conn.Close()
+		wm.removeViewer(session, viewer.ID)
 		return
 	}
-	conn.WriteJSON(map[string]any{"status": "ok", "sdp": finalSDP, "stage": "webrtc_init"})
+	session.AddViewer(viewer)
+	viewer.SendAnswer(finalSDP)
+	// 驱动初始化要等 WebRTC 连接建立，在此之前就需要接收浏览器发来的 ICE 候选
+	go wm.listenScreenWS(session, viewer)
 	// bitrateInt, err := strconv.Atoi(config.DriverConfig["video_bit_rate"])
 	// if err != nil {
 	// 	bitrateInt = 8000000 // default to 8Mbps
@@ -76,24 +94,67 @@ func (wm *WebMaster) handleScreenWS(c *gin.Context) {
 	// }
 	// finalSDP = webrtcHelper.SetSDPBandwidth(finalSDP, 20_000_000)
 	// conn.WriteMessage(websocket.TextMessage, []byte(finalSDP))
-	err = agent.InitDriver()
-	if err != nil {
-		log.Println("Failed to initialize driver:", err)
-		conn.WriteJSON(map[string]any{"status": "error", "message": err.Error(), "stage": "webrtc_init"})
+	if isNew {
+		session.initErr = agent.InitDriver()
+		close(session.ready)
+	} else {
+		<-session.ready
+	}
+	if session.initErr != nil {
+		log.Println("Failed to initialize driver:", session.initErr)
+		viewer.WriteJSON(map[string]any{"status": "error", "message": session.initErr.Error(), "stage": "webrtc_init"})
 		conn.Close()
+		wm.removeScreenSession(session)
 		return
 	}
-	capabilities := agent.Capabilities()
-	log.Printf("Driver Capabilities: %+v", capabilities)
+	capabilities := agent.ViewerCapabilities(viewer.ID)
+	log.Printf("Driver Capabilities for viewer %s: %+v", viewer.ID, capabilities)
 	media_meta := agent.GetMediaMeta()
-	conn.WriteJSON(map[string]interface{}{"status": "ok", "capabilities": capabilities, "media_meta": media_meta, "stage": "webrtc_metainfo"})
-	go wm.listenScreenWS(conn, agent, sessionID)
-	go wm.listenEventFeedback(agent, conn)
+	viewer.WriteJSON(map[string]interface{}{"status": "ok", "capabilities": capabilities, "media_meta": media_meta, "role": role, "stage": "webrtc_metainfo"})
+	if isNew {
+		go wm.listenEventFeedback(session)
+		agent.StartStreaming()
+	}
+}
 
-	agent.StartStreaming()
+// screenSessionID 同一设备的所有连接使用相同的 session ID
+func screenSessionID(config sagent.AgentConfig) string {
+	return config.DeviceType + "_" + config.DeviceID + "_" + config.DeviceIP + "_" + config.DevicePort
 }
 
-func (wm *WebMaster) listenScreenWS(wsConn *websocket.Conn, agent *sagent.Agent, sessionID string) {
+// getOrCreateScreenSession 返回设备对应的 session，不存在或已关闭时创建新的 Agent
+// 调用方加入 Agent 或放弃之后需要调用 releaseScreenSession
+func (wm *WebMaster) getOrCreateScreenSession(sessionID string, config sagent.AgentConfig) (*ScreenSession, bool, error) {
+	wm.screenSessionsMu.Lock()
+	defer wm.screenSessionsMu.Unlock()
+	if session, exists := wm.ScreenSessions[sessionID]; exists && !session.closed.Load() {
+		session.joining++
+		return session, false, nil
+	}
+	config.WebRTC = wm.config.WebRTC
+	wm.registry.applyDefaults(&config)
+	if config.DeviceType == DeviceTypeXvfb {
+		// 远程主机的地址和 ssh 认证只保存在服务端
+		if config.DriverConfig == nil {
+			config.DriverConfig = make(map[string]string)
+		}
+		if err := xvfb.ApplyHost(config.DeviceID, config.DriverConfig); err != nil {
+			return nil, false, err
+		}
+	}
+	agent, err := sagent.NewAgent(config)
+	if err != nil {
+		return nil, false, err
+	}
+	session := newScreenSession(sessionID, agent)
+	session.joining = 1
+	wm.ScreenSessions[sessionID] = session
+	return session, true, nil
+}
+
+func (wm *WebMaster) listenScreenWS(session *ScreenSession, viewer *ScreenViewer) {
+	wsConn := viewer.WSConn
+	agent := session.Agent
 	for {
 		mType, msg, err := wsConn.ReadMessage()
 		if err != nil {
@@ -103,41 +164,148 @@ func (wm *WebMaster) listenScreenWS(wsConn *websocket.Conn, agent *sagent.Agent,
 		switch mType {
 		case websocket.BinaryMessage:
 			// log.Println("Received binary message")
-			err := agent.SendEvent(msg)
+			err := agent.SendEvent(viewer.ID, msg)
 			if err != nil {
 				log.Println("Failed to send event:", err)
 			}
 		case websocket.TextMessage:
-			log.Printf("Received text message: %s", string(msg))
+			wm.handleSignalMessage(session, viewer, msg)
 		default:
 			log.Printf("Received unsupported message type: %d", mType)
 		}
 	}
 	wsConn.Close()
-	agent.Close()
-	wm.removeScreenSession(sessionID)
+	wm.removeViewer(session, viewer.ID)
+}
+
+// removeViewer 移除 Viewer，控制者离开时通知接替的 Viewer
+func (wm *WebMaster) removeViewer(session *ScreenSession, viewerID string) {
+	if next := session.RemoveViewer(viewerID); next != nil {
+		log.Printf("Controller %s left session %s, control passed to %s", viewerID, session.SessionID, next.ID)
+		next.SendRole(session.Agent, sagent.VIEWER_ROLE_CONTROLLER)
+	}
+	session.Agent.RemoveViewer(viewerID)
+	wm.removeIdleScreenSession(session)
 }
 
-func (wm *WebMaster) listenEventFeedback(agent *sagent.Agent, wsConn *websocket.Conn) {
-	agent.EventFeedback(func(msg []byte) bool {
-		err := wsConn.WriteMessage(websocket.BinaryMessage, msg)
+// signalMessage 是浏览器在 WebSocket 上发送的 JSON 信令
+type signalMessage struct {
+	Stage     string                   `json:"stage"`
+	Candidate *webrtc.ICECandidateInit `json:"candidate"`
+	// update_config 时要修改的驱动配置
+	DriverConfig map[string]string `json:"driver_config"`
+	// handover 时接收控制权的 Viewer
+	ViewerID string `json:"viewer_id"`
+}
+
+func (wm *WebMaster) handleSignalMessage(session *ScreenSession, viewer *ScreenViewer, msg []byte) {
+	agent := session.Agent
+	var signal signalMessage
+	if err := json.Unmarshal(msg, &signal); err != nil {
+		log.Printf("Received text message: %s", string(msg))
+		return
+	}
+	switch signal.Stage {
+	case "webrtc_candidate":
+		// candidate 为空表示浏览器端收集结束，pion 不需要额外处理
+		if signal.Candidate == nil || signal.Candidate.Candidate == "" {
+			return
+		}
+		if err := agent.AddICECandidate(viewer.ID, *signal.Candidate); err != nil {
+			log.Printf("Failed to add ICE candidate for viewer %s: %v", viewer.ID, err)
+		}
+	case "pause":
+		// 浏览器标签页被隐藏，所有 Viewer 都暂停时驱动停止编码
+		agent.PauseStreaming(viewer.ID)
+	case "resume":
+		agent.ResumeStreaming(viewer.ID)
+	case "update_config":
+		// 修改画质等参数，驱动重启编码器并恢复 UHID 设备等控制状态，WebRTC 连接保持不变
+		// 重启失败时返回错误，驱动会另外通知所有 Viewer
+		if err := agent.UpdateDriverConfig(viewer.ID, signal.DriverConfig); err != nil {
+			log.Printf("Failed to update driver config for viewer %s: %v", viewer.ID, err)
+			viewer.WriteJSON(map[string]any{"status": "error", "message": err.Error(), "stage": "config_updated"})
+			return
+		}
+		viewer.WriteJSON(map[string]any{"status": "ok", "media_meta": agent.GetMediaMeta(), "stage": "config_updated"})
+	case "request_control":
+		// 只读 Viewer 请求控制权，由当前控制者决定是否交接
+		controller := session.controller()
+		if controller == nil {
+			if _, err := session.handover("", viewer.ID); err == nil {
+				viewer.SendRole(agent, sagent.VIEWER_ROLE_CONTROLLER)
+			}
+			return
+		}
+		if controller.ID != viewer.ID {
+			controller.WriteJSON(map[string]any{"status": "ok", "viewer_id": viewer.ID, "stage": "control_requested"})
+		}
+	case "handover":
+		// 只有当前控制者可以把控制权交给其他 Viewer
+		to, err := session.handover(viewer.ID, signal.ViewerID)
 		if err != nil {
-			log.Println("Failed to send event feedback via WebSocket:", err)
+			log.Printf("Handover from %s rejected: %v", viewer.ID, err)
+			viewer.WriteJSON(map[string]any{"status": "error", "message": err.Error(), "stage": "role_changed"})
+			return
+		}
+		log.Printf("Viewer %s handed control of session %s to %s", viewer.ID, session.SessionID, to.ID)
+		viewer.SendRole(agent, sagent.VIEWER_ROLE_VIEWER)
+		to.SendRole(agent, sagent.VIEWER_ROLE_CONTROLLER)
+	default:
+		log.Printf("Received text message: %s", string(msg))
+	}
+}
+
+func (wm *WebMaster) listenEventFeedback(session *ScreenSession) {
+	session.Agent.EventFeedback(func(msg []byte) bool {
+		if session.closed.Load() {
+			log.Println("Session closed, stop sending event feedback")
 			return false
 		}
+		session.Broadcast(websocket.BinaryMessage, msg)
 		return true
 	})
 }
 
-func (wm *WebMaster) removeScreenSession(sessionID string) {
-	log.Printf("Removing screen session: %s", sessionID)
-	if session, exists := wm.ScreenSessions[sessionID]; exists {
-		if session.WSConn != nil {
-			session.WSConn.Close()
-		}
-		if session.Agent != nil {
-			session.Agent.Close()
-		}
+// removeScreenSession 关闭 session，map 中已经换成新的 session 时只关闭旧的
+func (wm *WebMaster) removeScreenSession(session *ScreenSession) {
+	log.Printf("Removing screen session: %s", session.SessionID)
+	wm.screenSessionsMu.Lock()
+	if wm.ScreenSessions[session.SessionID] == session {
+		delete(wm.ScreenSessions, session.SessionID)
+	}
+	wm.screenSessionsMu.Unlock()
+	session.Close()
+}
+
+// releaseScreenSession 结束 getOrCreateScreenSession 开始的加入，没有加入成功时 session 可能已经空闲
+func (wm *WebMaster) releaseScreenSession(session *ScreenSession) {
+	wm.screenSessionsMu.Lock()
+	session.joining--
+	wm.screenSessionsMu.Unlock()
+	wm.removeIdleScreenSession(session)
+}
+
+// removeIdleScreenSession 关闭没有 Viewer、没有正在加入的请求、也没有在录制的 session
+// 检查和删除在同一次加锁内完成，同时加入的浏览器要么拿到 session 使它不再空闲，要么创建新的 session
+func (wm *WebMaster) removeIdleScreenSession(session *ScreenSession) {
+	wm.screenSessionsMu.Lock()
+	// 正在录制的 session 即使没有浏览器也需要保留
+	idle := session.joining == 0 && session.Agent.ViewerCount() == 0 && !session.Agent.IsRecording()
+	if idle && wm.ScreenSessions[session.SessionID] == session {
+		delete(wm.ScreenSessions, session.SessionID)
+	}
+	wm.screenSessionsMu.Unlock()
+	if idle {
+		log.Printf("Removing idle screen session: %s", session.SessionID)
+		session.Close()
+	}
+}
+
+func generateViewerID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("viewer-%d", time.Now().UnixNano())
 	}
-	delete(wm.ScreenSessions, sessionID)
+	return fmt.Sprintf("viewer-%x", b)
 }
@@ -1,6 +1,8 @@
 package webservice
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
@@ -22,6 +24,8 @@ func (wm *WebMaster) GenerateToken() (string, error) {
 	claims := &CustomClaims{
 		Role: "admin", // 你可以在这里存用户ID或其他信息
 		RegisteredClaims: jwt.RegisteredClaims{
+			// 每个登录会话唯一的 ID，TURN 临时凭据与它绑定
+			ID:        generateTokenID(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			Issuer:    "webscreen",
 		},
@@ -68,6 +72,11 @@ func (wm *WebMaster) HybridAuthMiddleware() gin.HandlerFunc {
 	}
 }
 func (wm *WebMaster) validateToken(tokenString string) bool {
+	_, ok := wm.parseToken(tokenString)
+	return ok
+}
+
+func (wm *WebMaster) parseToken(tokenString string) (*CustomClaims, bool) {
 	claims := &CustomClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -77,9 +86,31 @@ func (wm *WebMaster) validateToken(tokenString string) bool {
 	})
 
 	if err != nil || !token.Valid {
-		return false
+		return nil, false
 	}
 
-	return true
+	return claims, true
+}
 
+// requestClaims 从 Cookie 或 Authorization 头中取出当前请求的 JWT
+func (wm *WebMaster) requestClaims(c *gin.Context) (*CustomClaims, bool) {
+	tokenString, _ := c.Cookie("auth_token")
+	if tokenString == "" {
+		authHeader := c.GetHeader("Authorization")
+		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+			tokenString = authHeader[7:]
+		}
+	}
+	if tokenString == "" {
+		return nil, false
+	}
+	return wm.parseToken(tokenString)
+}
+
+func generateTokenID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
 }
@@ -1,18 +1,201 @@
 package webservice
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 	agent "webscreen/streamAgent"
 
 	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
 )
 
+// ScreenSession 对应一台设备，一个 Agent 向多个浏览器 (ScreenViewer) 分发画面
 type ScreenSession struct {
 	SessionID string
-	WSConn    *websocket.Conn
 	Agent     *agent.Agent
+
+	viewersMu sync.RWMutex
+	Viewers   map[string]*ScreenViewer
+	// 当前控制者，同一时间最多一个，只能由服务端分配或交接
+	controllerID string
+
+	// 驱动初始化完成后关闭，后加入的 Viewer 需要等待它才能拿到 media meta
+	ready   chan struct{}
+	initErr error
+
+	closed atomic.Bool
+	// 已经拿到 session 但还没有加入 Agent 的请求数，由 WebMaster.screenSessionsMu 保护
+	// 不为 0 时 session 不会因为没有 Viewer 而被关闭
+	joining int
+}
+
+// ScreenViewer 是一个浏览器的 WebSocket 连接
+// gorilla/websocket 不支持并发写，所以所有写操作都需要经过 writeMu
+type ScreenViewer struct {
+	ID     string
+	WSConn *websocket.Conn
+	// 控制者离开时把控制权交给最早加入的 Viewer
+	joinedAt time.Time
+
+	writeMu sync.Mutex
+
+	// Trickle ICE: Answer 发出之前产生的本地候选先缓存，保证浏览器先收到 Answer
+	candidateMu       sync.Mutex
+	answerSent        bool
+	pendingCandidates []*webrtc.ICECandidateInit
+}
+
+func newScreenSession(sessionID string, a *agent.Agent) *ScreenSession {
+	return &ScreenSession{
+		SessionID: sessionID,
+		Agent:     a,
+		Viewers:   make(map[string]*ScreenViewer),
+		ready:     make(chan struct{}),
+	}
+}
+
+func (sv *ScreenViewer) WriteJSON(v any) error {
+	sv.writeMu.Lock()
+	defer sv.writeMu.Unlock()
+	return sv.WSConn.WriteJSON(v)
+}
+
+func (sv *ScreenViewer) WriteMessage(messageType int, data []byte) error {
+	sv.writeMu.Lock()
+	defer sv.writeMu.Unlock()
+	return sv.WSConn.WriteMessage(messageType, data)
+}
+
+// SendCandidate 把一个本地 ICE 候选发给浏览器，candidate 为 nil 表示收集结束
+func (sv *ScreenViewer) SendCandidate(candidate *webrtc.ICECandidateInit) {
+	sv.candidateMu.Lock()
+	defer sv.candidateMu.Unlock()
+	if !sv.answerSent {
+		sv.pendingCandidates = append(sv.pendingCandidates, candidate)
+		return
+	}
+	sv.writeCandidate(candidate)
+}
+
+// SendAnswer 发送 Answer，并补发之前缓存的候选
+func (sv *ScreenViewer) SendAnswer(sdp string) {
+	sv.candidateMu.Lock()
+	defer sv.candidateMu.Unlock()
+	sv.WriteJSON(map[string]any{"status": "ok", "sdp": sdp, "stage": "webrtc_init"})
+	sv.answerSent = true
+	for _, c := range sv.pendingCandidates {
+		sv.writeCandidate(c)
+	}
+	sv.pendingCandidates = nil
+}
+
+// SendRole 通知浏览器角色变化，并附上新的能力集
+func (sv *ScreenViewer) SendRole(a *agent.Agent, role string) {
+	sv.WriteJSON(map[string]any{"status": "ok", "role": role, "capabilities": a.ViewerCapabilities(sv.ID), "stage": "role_changed"})
+}
+
+func (sv *ScreenViewer) writeCandidate(candidate *webrtc.ICECandidateInit) {
+	sv.WriteJSON(map[string]any{"status": "ok", "candidate": candidate, "stage": "webrtc_candidate"})
+}
+
+func (sc *ScreenSession) AddViewer(v *ScreenViewer) {
+	sc.viewersMu.Lock()
+	defer sc.viewersMu.Unlock()
+	sc.Viewers[v.ID] = v
+}
+
+// claimRole 为新 Viewer 分配角色，浏览器发来的 role 只是请求
+// 没有控制者时成为控制者，否则只读；请求 viewer 时总是只读
+func (sc *ScreenSession) claimRole(viewerID string, requested string) string {
+	sc.viewersMu.Lock()
+	defer sc.viewersMu.Unlock()
+	if requested != agent.VIEWER_ROLE_VIEWER && sc.controllerID == "" {
+		sc.controllerID = viewerID
+		return agent.VIEWER_ROLE_CONTROLLER
+	}
+	return agent.VIEWER_ROLE_VIEWER
+}
+
+// RemoveViewer 移除 Viewer，离开的是控制者时把控制权交给最早加入的 Viewer 并返回它
+func (sc *ScreenSession) RemoveViewer(viewerID string) *ScreenViewer {
+	sc.viewersMu.Lock()
+	defer sc.viewersMu.Unlock()
+	delete(sc.Viewers, viewerID)
+	if sc.controllerID != viewerID {
+		return nil
+	}
+	sc.controllerID = ""
+	var next *ScreenViewer
+	for _, v := range sc.Viewers {
+		if next == nil || v.joinedAt.Before(next.joinedAt) {
+			next = v
+		}
+	}
+	if next == nil || sc.Agent.SetViewerRole(next.ID, agent.VIEWER_ROLE_CONTROLLER) != nil {
+		return nil
+	}
+	sc.controllerID = next.ID
+	return next
+}
+
+// controller 返回当前控制者，没有时返回 nil
+func (sc *ScreenSession) controller() *ScreenViewer {
+	sc.viewersMu.RLock()
+	defer sc.viewersMu.RUnlock()
+	return sc.Viewers[sc.controllerID]
+}
+
+// handover 由当前控制者把控制权交给另一个 Viewer，fromID 为空表示没有控制者时直接授予
+func (sc *ScreenSession) handover(fromID string, toID string) (*ScreenViewer, error) {
+	sc.viewersMu.Lock()
+	defer sc.viewersMu.Unlock()
+	if sc.controllerID != fromID {
+		return nil, fmt.Errorf("viewer %s is not the controller", fromID)
+	}
+	to, ok := sc.Viewers[toID]
+	if !ok || toID == fromID {
+		return nil, fmt.Errorf("unknown viewer: %s", toID)
+	}
+	if err := sc.Agent.SetViewerRole(toID, agent.VIEWER_ROLE_CONTROLLER); err != nil {
+		return nil, err
+	}
+	if fromID != "" {
+		sc.Agent.SetViewerRole(fromID, agent.VIEWER_ROLE_VIEWER)
+	}
+	sc.controllerID = toID
+	return to, nil
+}
+
+func (sc *ScreenSession) ViewerCount() int {
+	sc.viewersMu.RLock()
+	defer sc.viewersMu.RUnlock()
+	return len(sc.Viewers)
+}
+
+// Broadcast 向所有 Viewer 发送同一条消息，返回是否仍有 Viewer 在线
+func (sc *ScreenSession) Broadcast(messageType int, data []byte) bool {
+	sc.viewersMu.RLock()
+	viewers := make([]*ScreenViewer, 0, len(sc.Viewers))
+	for _, v := range sc.Viewers {
+		viewers = append(viewers, v)
+	}
+	sc.viewersMu.RUnlock()
+	for _, v := range viewers {
+		v.WriteMessage(messageType, data)
+	}
+	return len(viewers) > 0
 }
 
 func (sc *ScreenSession) Close() {
+	if sc.closed.Swap(true) {
+		return
+	}
 	sc.Agent.Close()
-	sc.WSConn.Close()
+	sc.viewersMu.RLock()
+	defer sc.viewersMu.RUnlock()
+	for _, v := range sc.Viewers {
+		v.WSConn.Close()
+	}
 }
@@ -1,24 +1,41 @@
 package webservice
 
 import (
+	"cmp"
+	"context"
 	"io/fs"
 	"log"
 	"maps"
 	"net/http"
 	"sync"
 	"time"
+	sagent "webscreen/streamAgent"
+	"webscreen/turnserver"
+	"webscreen/webservice/xvfb"
 
 	"github.com/gin-gonic/gin"
 )
 
 type WebMasterConfig struct {
 	EnableAndroidDiscover bool
+	// 服务端录制文件的保存目录
+	RecordDir string
+	// 设备登记文件，保存设备名称、标签和默认驱动参数
+	RegistryFile string
+	// 远程 Linux 主机列表，包含 ssh 密码
+	HostsFile string
+	// ICE 服务器、NAT 映射等 WebRTC 网络设置
+	WebRTC sagent.WebRTCConfig
 }
 
 type WebMaster struct {
 	// WSConns []*websocket.Conn
 
-	ScreenSessions map[string]ScreenSession
+	ScreenSessions   map[string]*ScreenSession
+	screenSessionsMu sync.Mutex
+
+	// 内置 TURN 服务器，为 nil 时不启用
+	turn *turnserver.Server
 
 	pin                  string
 	UnlockAttemptRecords map[string]UnlockAttemptRecord
@@ -27,19 +44,26 @@ type WebMaster struct {
 	config              WebMasterConfig
 	router              *gin.Engine
 	devicesConnected    map[string]Device
-	devicesDiscovered   map[string]Device
+	devicesDiscovered   map[string]discoveredDevice
 	devicesDiscoveredMu sync.RWMutex
 	pauseDiscovery      bool
 	staticFS            fs.FS
+
+	// 设备插拔监听，推送给 /api/device/events
+	devices  *deviceWatcher
+	registry *deviceRegistry
+	// 停止设备监听和 mDNS 发现
+	stopBackground context.CancelFunc
 }
 
 func New(config WebMasterConfig, staticFS fs.FS) *WebMaster {
 	wm := &WebMaster{
-		ScreenSessions:       make(map[string]ScreenSession),
+		ScreenSessions:       make(map[string]*ScreenSession),
 		config:               config,
-		devicesDiscovered:    make(map[string]Device),
+		devicesDiscovered:    make(map[string]discoveredDevice),
 		staticFS:             staticFS,
 		UnlockAttemptRecords: make(map[string]UnlockAttemptRecord),
+		devices:              newDeviceWatcher(),
 	}
 	wm.jwtSecret = []byte(time.Now().String())
 	wm.setRouter()
@@ -48,13 +72,18 @@ func New(config WebMasterConfig, staticFS fs.FS) *WebMaster {
 
 func Default(staticFS fs.FS) *WebMaster {
 	wm := &WebMaster{
-		ScreenSessions: make(map[string]ScreenSession),
+		ScreenSessions: make(map[string]*ScreenSession),
 		config: WebMasterConfig{
 			EnableAndroidDiscover: true,
+			RecordDir:             "recordings",
+			RegistryFile:          REGISTRY_FILE_DEFAULT,
+			HostsFile:             xvfb.HOSTS_FILE_DEFAULT,
+			WebRTC:                sagent.DefaultWebRTCConfig(),
 		},
-		devicesDiscovered:    make(map[string]Device),
+		devicesDiscovered:    make(map[string]discoveredDevice),
 		UnlockAttemptRecords: make(map[string]UnlockAttemptRecord),
 		staticFS:             staticFS,
+		devices:              newDeviceWatcher(),
 	}
 	wm.jwtSecret = []byte(time.Now().String())
 	return wm
@@ -92,10 +121,32 @@ func (wm *WebMaster) setRouter() {
 	api := r.Group("/api")
 	{
 		api.GET("/device/list", wm.handleListDevices)
+		api.GET("/device/events", wm.handleDeviceEvents)
 		api.POST("/device/connect", wm.handleConnectDevice)
 		api.POST("/device/pair", wm.handlePairDevice)
-		// api.POST("/device/discovery", wm.handleListDevicesDiscoveried)
+		api.GET("/device/:type/:id/screenshot", wm.handleScreenshot)
+		api.GET("/device/:type/:id/cameras", wm.handleListCameras)
+		api.GET("/device/:type/:id/displays", wm.handleListDisplays)
+		api.GET("/device/:type/:id/apps", wm.handleListApps)
+		api.POST("/device/:type/:id/apps/:package/:action", wm.handleAppAction)
+		api.POST("/device/:type/:id/open", wm.handleOpenIntent)
+		api.POST("/device/:type/:id/upload", wm.handleUpload)
+		api.POST("/device/:type/:id/reconnect", wm.handleReconnectDevice)
+
+		api.GET("/hosts", wm.handleListHosts)
+		api.DELETE("/hosts/:id", wm.handleDeleteHost)
+
+		api.GET("/registry", wm.handleListRegistry)
+		api.PUT("/registry/:type/:id", wm.handleUpdateRegistry)
+		api.DELETE("/registry/:type/:id", wm.handleDeleteRegistry)
+		api.GET("/device/discovery", wm.handleListDevicesDiscoveried)
 		// api.POST("/setPIN", wm.handleSetPIN)
+
+		api.GET("/sessions", wm.handleListSessions)
+		api.POST("/sessions/:id/record/start", wm.handleStartRecording)
+		api.POST("/sessions/:id/record/stop", wm.handleStopRecording)
+		api.GET("/recordings", wm.handleListRecordings)
+		api.GET("/recordings/:name", wm.handleDownloadRecording)
 	}
 
 	wm.router = r
@@ -108,17 +159,61 @@ func (wm *WebMaster) SetPIN(pin string) {
 	wm.pin = pin
 }
 
+func (wm *WebMaster) SetRecordDir(dir string) {
+	log.Printf("Recordings will be saved to: %s", dir)
+	wm.config.RecordDir = dir
+}
+
+func (wm *WebMaster) SetRegistryFile(path string) {
+	log.Printf("Device registry: %s", path)
+	wm.config.RegistryFile = path
+}
+
+func (wm *WebMaster) SetHostsFile(path string) {
+	log.Printf("Xvfb hosts: %s", path)
+	wm.config.HostsFile = path
+}
+
+func (wm *WebMaster) SetAndroidDiscover(enable bool) {
+	log.Printf("Android mDNS discovery enabled: %v", enable)
+	wm.config.EnableAndroidDiscover = enable
+}
+
+func (wm *WebMaster) SetWebRTCConfig(config sagent.WebRTCConfig) {
+	// 不打印 TURN 密码
+	log.Printf("WebRTC config: %d ICE servers, NAT 1:1 IPs: %v, UDP ports: %d-%d, UDP mux: %d, ICE-TCP: %d",
+		len(config.ICEServers), config.NAT1To1IPs, config.UDPPortMin, config.UDPPortMax, config.UDPMuxPort, config.TCPMuxPort)
+	wm.config.WebRTC = config
+}
+
 func (wm *WebMaster) Serve(port string) {
-	// if wm.config.EnableAndroidDiscover {
-	// 	go wm.AndroidDevicesDiscovery()
-	// }
+	var ctx context.Context
+	ctx, wm.stopBackground = context.WithCancel(context.Background())
+	wm.registry = loadDeviceRegistry(cmp.Or(wm.config.RegistryFile, REGISTRY_FILE_DEFAULT))
+	if err := xvfb.LoadHosts(cmp.Or(wm.config.HostsFile, xvfb.HOSTS_FILE_DEFAULT)); err != nil {
+		log.Printf("Failed to load xvfb hosts: %v", err)
+	}
+	go wm.devices.run(ctx)
+	go wm.registry.track(ctx, wm.devices)
+	if wm.config.EnableAndroidDiscover {
+		go wm.AndroidDevicesDiscovery(ctx)
+	}
 	wm.setRouter()
 	wm.router.Run(":" + port)
 }
 
 func (wm *WebMaster) Close() {
+	if wm.stopBackground != nil {
+		wm.stopBackground()
+	}
+	wm.screenSessionsMu.Lock()
+	defer wm.screenSessionsMu.Unlock()
 	for k, v := range maps.All(wm.ScreenSessions) {
 		log.Printf("closing session %v", k)
 		v.Close()
 	}
+	wm.config.WebRTC.Close()
+	if wm.turn != nil {
+		wm.turn.Close()
+	}
 }
@@ -1,17 +1,40 @@
 package xvfb
 
-import "os/exec"
+import (
+	"fmt"
+	"os/exec"
+	linuxXvfbDriver "webscreen/sdriver/xvfb"
+)
 
+const LOCAL_DEVICE_ID = "local_xvfb"
+
+// GetDevices 返回本机的虚拟显示器（安装了 Xvfb 时）和登记的远程主机
 func GetDevices() ([]XvfbDevice, error) {
+	var devices []XvfbDevice
 	if _, err := exec.LookPath("Xvfb"); err == nil {
-		return []XvfbDevice{
-			{
-				DeviceID: "local_xvfb",
-				IP:       "127.0.0.1",
-				Port:     0,
-				Status:   "active",
-			},
-		}, nil
+		devices = append(devices, XvfbDevice{
+			DeviceID: LOCAL_DEVICE_ID,
+			IP:       "127.0.0.1",
+			Port:     0,
+			Status:   "active",
+		})
+	}
+	return append(devices, remoteDevices()...), nil
+}
+
+// ErrDisplayNotRunning 表示要截图的显示器不存在
+var ErrDisplayNotRunning = linuxXvfbDriver.ErrDisplayNotRunning
+
+// Screenshot 返回 display 的 PNG 截图，display 为空时截取推流的虚拟显示器，它只在推流时存在
+func Screenshot(deviceID string, display string) ([]byte, error) {
+	if deviceID == LOCAL_DEVICE_ID {
+		return linuxXvfbDriver.Screenshot(linuxXvfbDriver.SSHTarget{}, display)
+	}
+	if h, ok := getHost(deviceID); ok {
+		if h.needsPassword() {
+			return nil, fmt.Errorf("%s: %w", deviceID, ErrPasswordRequired)
+		}
+		return linuxXvfbDriver.Screenshot(h.target(), display)
 	}
-	return nil, nil
+	return nil, fmt.Errorf("xvfb device not found: %s", deviceID)
 }
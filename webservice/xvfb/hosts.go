@@ -0,0 +1,246 @@
+package xvfb
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	linuxXvfbDriver "webscreen/sdriver/xvfb"
+)
+
+const (
+	HOSTS_FILE_DEFAULT = "hosts.json"
+	// 检查 ssh 端口是否可达的超时
+	HOST_CHECK_TIMEOUT = 2 * time.Second
+)
+
+var (
+	ErrHostNotFound = errors.New("xvfb host not found")
+	// ErrInvalidHost 表示登记请求缺少字段或认证方式不支持
+	ErrInvalidHost = errors.New("invalid xvfb host")
+	// ErrPasswordRequired 表示使用密码认证的主机在服务重启后还没有重新输入密码
+	ErrPasswordRequired = errors.New("ssh password required, connect the host again")
+)
+
+// RemoteHost 是通过 ssh 推流的远程 Linux 主机
+type RemoteHost struct {
+	ID      string `json:"id"`
+	IP      string `json:"ip"`
+	SSHPort int    `json:"ssh_port"`
+	User    string `json:"user"`
+	Auth    string `json:"auth"` // agent, key, password
+	KeyFile string `json:"key_file,omitempty"`
+	// 密码只保存在内存中，不写入文件，服务重启后需要重新登记
+	Password string `json:"-"`
+}
+
+func (h RemoteHost) target() linuxXvfbDriver.SSHTarget {
+	return linuxXvfbDriver.SSHTarget{
+		User:     h.User,
+		Host:     h.IP,
+		Port:     h.SSHPort,
+		Auth:     h.Auth,
+		KeyFile:  h.KeyFile,
+		Password: h.Password,
+	}
+}
+
+// Public 返回不含密码的副本，用于 API 响应
+func (h RemoteHost) Public() RemoteHost {
+	h.Password = ""
+	return h
+}
+
+// needsPassword 表示密码认证的主机还没有密码
+func (h RemoteHost) needsPassword() bool {
+	return h.Auth == linuxXvfbDriver.SSH_AUTH_PASSWORD && h.Password == ""
+}
+
+// hostStore 保存在 JSON 文件中，文件不含密码
+var hostStore = struct {
+	mu    sync.Mutex
+	path  string
+	hosts map[string]RemoteHost
+}{hosts: make(map[string]RemoteHost)}
+
+// LoadHosts 读取远程主机列表，文件不存在时从空列表开始
+func LoadHosts(path string) error {
+	hostStore.mu.Lock()
+	defer hostStore.mu.Unlock()
+	hostStore.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var hosts []RemoteHost
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	// 旧版本会把密码写进文件，读到内存后重新保存一次去掉它们
+	var legacy []struct {
+		Password string `json:"password"`
+	}
+	json.Unmarshal(data, &legacy)
+	hasPassword := false
+	for i, h := range hosts {
+		if i < len(legacy) && legacy[i].Password != "" {
+			h.Password = legacy[i].Password
+			hasPassword = true
+		}
+		hostStore.hosts[h.ID] = h
+	}
+	log.Printf("Loaded %d xvfb hosts from %s", len(hosts), path)
+	if hasPassword {
+		log.Printf("Removing ssh passwords from %s", path)
+		return saveHostsLocked()
+	}
+	return nil
+}
+
+func saveHostsLocked() error {
+	if hostStore.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(sortedHostsLocked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(hostStore.path, append(data, '\n'), 0600)
+}
+
+func sortedHostsLocked() []RemoteHost {
+	hosts := make([]RemoteHost, 0, len(hostStore.hosts))
+	for _, h := range hostStore.hosts {
+		hosts = append(hosts, h)
+	}
+	slices.SortFunc(hosts, func(a, b RemoteHost) int { return cmp.Compare(a.ID, b.ID) })
+	return hosts
+}
+
+// Hosts 返回所有远程主机，不含密码
+func Hosts() []RemoteHost {
+	hostStore.mu.Lock()
+	defer hostStore.mu.Unlock()
+	hosts := sortedHostsLocked()
+	for i := range hosts {
+		hosts[i] = hosts[i].Public()
+	}
+	return hosts
+}
+
+func getHost(id string) (RemoteHost, bool) {
+	hostStore.mu.Lock()
+	defer hostStore.mu.Unlock()
+	h, ok := hostStore.hosts[id]
+	return h, ok
+}
+
+// AddHost 登录远程主机确认可用后保存，ID 为 user@ip，非 22 端口时为 user@ip:port
+func AddHost(ctx context.Context, h RemoteHost) (RemoteHost, error) {
+	if h.IP == "" || h.User == "" {
+		return RemoteHost{}, fmt.Errorf("%w: ip and user are required", ErrInvalidHost)
+	}
+	switch h.Auth {
+	case "":
+		h.Auth = linuxXvfbDriver.SSH_AUTH_AGENT
+	case linuxXvfbDriver.SSH_AUTH_AGENT:
+	case linuxXvfbDriver.SSH_AUTH_KEY:
+		if h.KeyFile == "" {
+			return RemoteHost{}, fmt.Errorf("%w: key_file is required for key authentication", ErrInvalidHost)
+		}
+	case linuxXvfbDriver.SSH_AUTH_PASSWORD:
+		if h.Password == "" {
+			return RemoteHost{}, fmt.Errorf("%w: password is required for password authentication", ErrInvalidHost)
+		}
+	default:
+		return RemoteHost{}, fmt.Errorf("%w: unsupported auth method %q", ErrInvalidHost, h.Auth)
+	}
+	if h.SSHPort == 22 {
+		h.SSHPort = 0
+	}
+	h.ID = h.User + "@" + h.IP
+	if h.SSHPort > 0 {
+		h.ID += ":" + strconv.Itoa(h.SSHPort)
+	}
+
+	if err := h.target().Check(ctx); err != nil {
+		return RemoteHost{}, err
+	}
+
+	hostStore.mu.Lock()
+	defer hostStore.mu.Unlock()
+	hostStore.hosts[h.ID] = h
+	if err := saveHostsLocked(); err != nil {
+		log.Printf("Failed to save xvfb hosts: %v", err)
+	}
+	return h.Public(), nil
+}
+
+// RemoveHost 删除远程主机
+func RemoveHost(id string) error {
+	hostStore.mu.Lock()
+	defer hostStore.mu.Unlock()
+	if _, ok := hostStore.hosts[id]; !ok {
+		return ErrHostNotFound
+	}
+	delete(hostStore.hosts, id)
+	return saveHostsLocked()
+}
+
+// ApplyHost 把远程主机的地址和 ssh 认证写入驱动参数，id 不是远程主机时不做修改
+func ApplyHost(id string, cfg map[string]string) error {
+	h, ok := getHost(id)
+	if !ok {
+		return nil
+	}
+	if h.needsPassword() {
+		return fmt.Errorf("%s: %w", id, ErrPasswordRequired)
+	}
+	cfg["ip"] = h.IP
+	cfg["user"] = h.User
+	cfg["ssh_port"] = strconv.Itoa(h.SSHPort)
+	cfg["ssh_auth"] = h.Auth
+	cfg["ssh_key"] = h.KeyFile
+	cfg["ssh_password"] = h.Password
+	return nil
+}
+
+// remoteDevices 并发检查每台主机的 ssh 端口，不可达的主机状态为 unreachable
+func remoteDevices() []XvfbDevice {
+	hostStore.mu.Lock()
+	hosts := sortedHostsLocked()
+	hostStore.mu.Unlock()
+
+	devices := make([]XvfbDevice, len(hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Go(func() {
+			port := cmp.Or(h.SSHPort, 22)
+			status := "active"
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(h.IP, strconv.Itoa(port)), HOST_CHECK_TIMEOUT)
+			switch {
+			case err != nil:
+				status = "unreachable"
+			case h.needsPassword():
+				conn.Close()
+				status = "password_required"
+			default:
+				conn.Close()
+			}
+			devices[i] = XvfbDevice{DeviceID: h.ID, IP: h.IP, Port: port, Status: status}
+		})
+	}
+	wg.Wait()
+	return devices
+}